@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/xxxsen/atlas/internal/action"
+	"github.com/xxxsen/atlas/internal/action/fakeip"
+	"github.com/xxxsen/atlas/internal/admin"
+	"go.uber.org/zap"
+)
+
+const defaultAdminBind = "127.0.0.1:6061"
+
+// registerAdminHandlers exposes the admin endpoints of the built actions.
+func registerAdminHandlers(as map[string]action.IDNSAction) {
+	if h := fakeip.LookupHandler(as); h != nil {
+		admin.Handle(fakeip.LookupPattern, h)
+	}
+}
+
+func startAdminServer(ctx context.Context, bind string, logkit *zap.Logger) {
+	addr := strings.TrimSpace(bind)
+	if addr == "" {
+		addr = defaultAdminBind
+	}
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           admin.Handler(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logkit.Error("admin server exit", zap.Error(err))
+		}
+	}()
+	logkit.Info("start admin server", zap.String("bind", addr))
+}
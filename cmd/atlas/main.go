@@ -5,6 +5,7 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
@@ -15,12 +16,14 @@ import (
 	"github.com/xxxsen/atlas/internal/action"
 	_ "github.com/xxxsen/atlas/internal/action/register"
 	"github.com/xxxsen/atlas/internal/config"
+	"github.com/xxxsen/atlas/internal/data/psl"
 	"github.com/xxxsen/atlas/internal/hosts"
 	"github.com/xxxsen/atlas/internal/matcher"
 	_ "github.com/xxxsen/atlas/internal/matcher/register"
 	"github.com/xxxsen/atlas/internal/resolver"
 	"github.com/xxxsen/atlas/internal/rule"
 	"github.com/xxxsen/atlas/internal/server"
+	"github.com/xxxsen/atlas/internal/watcher"
 	"github.com/xxxsen/common/logger"
 	"go.uber.org/zap"
 )
@@ -46,6 +49,18 @@ func main() {
 		Interval: time.Duration(cfg.Cache.Interval) * time.Second,
 	})
 
+	if err := watcher.Configure(watcher.Options{
+		Enable:   cfg.Watch.Enable,
+		Debounce: time.Duration(cfg.Watch.Debounce) * time.Millisecond,
+	}); err != nil {
+		logkit.Fatal("init file watcher failed", zap.Error(err))
+	}
+	defer watcher.Close() //nolint:errcheck
+
+	if err := psl.Configure(cfg.PSL.File); err != nil {
+		logkit.Fatal("init public suffix list failed", zap.Error(err))
+	}
+
 	ms, err := buildMatcherMap(cfg.Resource.Matcher)
 	if err != nil {
 		logkit.Fatal("build matcher map failed", zap.Error(err))
@@ -80,29 +95,54 @@ func main() {
 	if cfg.Pprof.Enable {
 		startPprofServer(ctx, cfg.Pprof.Bind, logkit)
 	}
+	if cfg.Admin.Enable {
+		registerAdminHandlers(as)
+		startAdminServer(ctx, cfg.Admin.Bind, logkit)
+	}
 
 	logkit.Info("start dns forwarder server", zap.String("addr", cfg.Bind))
-	if err := forwarder.Start(ctx); err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, syscall.EINTR) {
+	err = forwarder.Start(ctx)
+	closeActions(as, logkit)
+	if err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, syscall.EINTR) {
 		logkit.Fatal("server error", zap.Error(err))
 	}
 	logkit.Info("shutdown complete")
 }
 
 func buildActionMap(ats []config.ActionConfig) (map[string]action.IDNSAction, error) {
-	m := make(map[string]action.IDNSAction, len(ats))
+	defs := make([]action.Def, 0, len(ats))
 	for _, at := range ats {
-		inst, err := action.MakeAction(at.Type, at.Name, at.Data)
-		if err != nil {
-			return nil, fmt.Errorf("make action failed, name:%s, type:%s, err:%w", at.Name, at.Type, err)
+		defs = append(defs, action.Def{Name: at.Name, Type: at.Type, Args: at.Data})
+	}
+	return action.BuildActions(defs)
+}
+
+// closeActions releases the actions holding state, such as fakeip writing its
+// pending mapping changes.
+func closeActions(as map[string]action.IDNSAction, logkit *zap.Logger) {
+	for name, a := range as {
+		c, ok := a.(io.Closer)
+		if !ok {
+			continue
+		}
+		if err := c.Close(); err != nil {
+			logkit.Error("close action failed", zap.String("action", name), zap.Error(err))
 		}
-		m[at.Name] = inst
 	}
-	return m, nil
 }
 
 func buildMatcherMap(ms []config.MatcherConfig) (map[string]matcher.IDNSMatcher, error) {
 	rs := make(map[string]matcher.IDNSMatcher, len(ms))
+	var composites []matcher.CompositeDef
 	for _, m := range ms {
+		if m.Type == matcher.CompositeType {
+			def, err := matcher.ParseCompositeDef(m.Name, m.Data)
+			if err != nil {
+				return nil, err
+			}
+			composites = append(composites, def)
+			continue
+		}
 		inst, err := matcher.MakeMatcher(m.Type, m.Name, m.Data)
 		if err != nil {
 			return nil, err
@@ -116,6 +156,9 @@ func buildMatcherMap(ms []config.MatcherConfig) (map[string]matcher.IDNSMatcher,
 		}
 		rs["any"] = anyMatcher
 	}
+	if err := matcher.BuildComposites(composites, rs); err != nil {
+		return nil, err
+	}
 	return rs, nil
 }
 
@@ -148,9 +191,5 @@ func buildHostStore(cfg config.HostConfig) (hosts.IHostResolver, error) {
 	if len(cfg.Records) == 0 && len(cfg.Files) == 0 {
 		return nil, nil
 	}
-	recs, err := hosts.LoadRecordsFromFiles(cfg.Files)
-	if err != nil {
-		return nil, err
-	}
-	return hosts.New(append(recs, cfg.Records)...)
+	return hosts.NewFromFiles(cfg.Records, cfg.Files)
 }
@@ -3,6 +3,7 @@ package action
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/miekg/dns"
 )
@@ -13,7 +14,11 @@ type IDNSAction interface {
 	Perform(ctx context.Context, req *dns.Msg) (*dns.Msg, error)
 }
 
-type Factory func(name string, args interface{}) (IDNSAction, error)
+// Lookup resolves an action referred to by name, so that an action can hand a
+// request over to another named action.
+type Lookup func(name string) (IDNSAction, error)
+
+type Factory func(name string, args interface{}, lookup Lookup) (IDNSAction, error)
 
 var m = make(map[string]Factory)
 
@@ -21,10 +26,73 @@ func Register(typ string, fac Factory) {
 	m[typ] = fac
 }
 
-func MakeAction(typ string, name string, args interface{}) (IDNSAction, error) {
+func MakeAction(typ string, name string, args interface{}, lookup Lookup) (IDNSAction, error) {
 	cr, ok := m[typ]
 	if !ok {
 		return nil, fmt.Errorf("action type:%s not found", typ)
 	}
-	return cr(name, args)
+	return cr(name, args, lookup)
+}
+
+// MapLookup returns a Lookup resolving names from a map of built actions.
+func MapLookup(registry map[string]IDNSAction) Lookup {
+	return func(name string) (IDNSAction, error) {
+		inst, ok := registry[name]
+		if !ok {
+			return nil, fmt.Errorf("action:%s not found", name)
+		}
+		return inst, nil
+	}
+}
+
+// Def describes an action to be built by BuildActions.
+type Def struct {
+	Name string
+	Type string
+	Args interface{}
+}
+
+// BuildActions builds defs into a map keyed by action name. Actions referring
+// to other actions are built after the actions they refer to, regardless of
+// the order of defs.
+func BuildActions(defs []Def) (map[string]IDNSAction, error) {
+	pending := make(map[string]Def, len(defs))
+	for _, def := range defs {
+		if _, ok := pending[def.Name]; ok {
+			return nil, fmt.Errorf("action %s defined more than once", def.Name)
+		}
+		pending[def.Name] = def
+	}
+	registry := make(map[string]IDNSAction, len(defs))
+	visiting := make(map[string]bool, len(defs))
+	var build func(name string, chain []string) (IDNSAction, error)
+	build = func(name string, chain []string) (IDNSAction, error) {
+		if inst, ok := registry[name]; ok {
+			return inst, nil
+		}
+		def, ok := pending[name]
+		if !ok {
+			return nil, fmt.Errorf("action:%s not found", name)
+		}
+		chain = append(chain, name)
+		if visiting[name] {
+			return nil, fmt.Errorf("action cycle: %s", strings.Join(chain, " -> "))
+		}
+		visiting[name] = true
+		defer delete(visiting, name)
+		inst, err := MakeAction(def.Type, def.Name, def.Args, func(ref string) (IDNSAction, error) {
+			return build(ref, chain)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("make action failed, name:%s, type:%s, err:%w", def.Name, def.Type, err)
+		}
+		registry[name] = inst
+		return inst, nil
+	}
+	for _, def := range defs {
+		if _, err := build(def.Name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
 }
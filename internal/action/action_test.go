@@ -0,0 +1,70 @@
+package action
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+type testAction struct {
+	name string
+	next IDNSAction
+}
+
+func (t *testAction) Name() string { return t.name }
+
+func (t *testAction) Type() string { return "action-test" }
+
+func (t *testAction) Perform(ctx context.Context, req *dns.Msg) (*dns.Msg, error) {
+	return nil, nil
+}
+
+func init() {
+	Register("action-test", func(name string, args interface{}, lookup Lookup) (IDNSAction, error) {
+		a := &testAction{name: name}
+		if next, ok := args.(string); ok {
+			inst, err := lookup(next)
+			if err != nil {
+				return nil, err
+			}
+			a.next = inst
+		}
+		return a, nil
+	})
+}
+
+func TestBuildActionsOutOfOrder(t *testing.T) {
+	as, err := BuildActions([]Def{
+		{Name: "wrapper", Type: "action-test", Args: "inner"},
+		{Name: "inner", Type: "action-test"},
+	})
+	if err != nil {
+		t.Fatalf("BuildActions error: %v", err)
+	}
+	if len(as) != 2 || as["wrapper"].(*testAction).next != as["inner"] {
+		t.Fatalf("unexpected actions %v", as)
+	}
+}
+
+func TestBuildActionsInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		defs []Def
+		want string
+	}{
+		{"duplicate", []Def{{Name: "a", Type: "action-test"}, {Name: "a", Type: "action-test"}}, "defined more than once"},
+		{"cycle", []Def{{Name: "a", Type: "action-test", Args: "b"}, {Name: "b", Type: "action-test", Args: "a"}}, "action cycle: a -> b -> a"},
+		{"unknown", []Def{{Name: "a", Type: "action-test", Args: "missing"}}, "action:missing not found"},
+		{"type", []Def{{Name: "a", Type: "missing"}}, "action type:missing not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := BuildActions(tt.defs)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package answer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/xxxsen/atlas/internal/action"
+	"github.com/xxxsen/common/utils"
+)
+
+const (
+	defaultAnswerTTL = 60
+	qnamePlaceholder = "{{qname}}"
+	// sampleQName is only used to validate templated records at load time.
+	sampleQName = "example.com."
+)
+
+type answerRecord struct {
+	text string // set when the record contains {{qname}} and has to be parsed per request
+	rr   dns.RR
+}
+
+type answerAction struct {
+	name    string
+	ttl     uint32
+	records []answerRecord
+}
+
+func (a *answerAction) Name() string {
+	return a.name
+}
+
+func (a *answerAction) Type() string {
+	return "answer"
+}
+
+// Perform answers with the configured records owned by the question name.
+// When the name owns a CNAME the chain is followed through the configured
+// records, so "{{qname}} CNAME svc." plus "svc. A 10.0.0.1" works for any
+// qtype. A question without records gets an empty NOERROR reply.
+func (a *answerAction) Perform(ctx context.Context, req *dns.Msg) (*dns.Msg, error) {
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	resp.Authoritative = true
+	for _, q := range req.Question {
+		rrs, err := a.renderAll(q.Name)
+		if err != nil {
+			return nil, err
+		}
+		resp.Answer = append(resp.Answer, a.lookup(rrs, q.Name, q.Qtype)...)
+	}
+	return resp, nil
+}
+
+// lookup collects the records of qtype owned by name out of rrs, following
+// CNAME records as long as their target is owned by one of rrs.
+func (a *answerAction) lookup(rrs []dns.RR, name string, qtype uint16) []dns.RR {
+	var answer []dns.RR
+	seen := make(map[string]bool)
+	for !seen[dns.CanonicalName(name)] {
+		seen[dns.CanonicalName(name)] = true
+		var next string
+		for _, rr := range rrs {
+			if !strings.EqualFold(rr.Header().Name, name) {
+				continue
+			}
+			typ := rr.Header().Rrtype
+			if typ == dns.TypeCNAME && qtype != dns.TypeCNAME {
+				answer = append(answer, rr)
+				next = rr.(*dns.CNAME).Target
+				break
+			}
+			if qtype == dns.TypeANY || typ == qtype {
+				answer = append(answer, rr)
+			}
+		}
+		if next == "" {
+			break
+		}
+		name = next
+	}
+	return answer
+}
+
+func (a *answerAction) renderAll(qname string) ([]dns.RR, error) {
+	rrs := make([]dns.RR, 0, len(a.records))
+	for _, rec := range a.records {
+		rr, err := a.render(rec, qname)
+		if err != nil {
+			return nil, err
+		}
+		rrs = append(rrs, rr)
+	}
+	return rrs, nil
+}
+
+func (a *answerAction) render(rec answerRecord, qname string) (dns.RR, error) {
+	if rec.text == "" {
+		return dns.Copy(rec.rr), nil
+	}
+	rr, err := parseRecord(strings.ReplaceAll(rec.text, qnamePlaceholder, dns.Fqdn(qname)), a.ttl)
+	if err != nil {
+		return nil, fmt.Errorf("render answer record failed, qname:%s, record:%s, err:%w", qname, rec.text, err)
+	}
+	return rr, nil
+}
+
+// parseRecord parses a single record in zone-file syntax like dns.NewRR does,
+// records without an explicit TTL get ttl instead of the zone default.
+func parseRecord(text string, ttl uint32) (dns.RR, error) {
+	zp := dns.NewZoneParser(strings.NewReader(text), ".", "")
+	zp.SetDefaultTTL(ttl)
+	rr, ok := zp.Next()
+	if err := zp.Err(); err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("empty record")
+	}
+	if _, more := zp.Next(); more {
+		return nil, fmt.Errorf("only one record is allowed per entry")
+	}
+	return rr, nil
+}
+
+func newAnswerAction(name string, records []string, ttl uint32) (action.IDNSAction, error) {
+	if len(records) == 0 {
+		return nil, fmt.Errorf("answer action requires at least one record")
+	}
+	if ttl == 0 {
+		ttl = defaultAnswerTTL
+	}
+	act := &answerAction{name: name, ttl: ttl}
+	for _, text := range records {
+		text = strings.TrimSpace(text)
+		rec := answerRecord{}
+		sample := text
+		if strings.Contains(text, qnamePlaceholder) {
+			rec.text = text
+			sample = strings.ReplaceAll(text, qnamePlaceholder, sampleQName)
+		}
+		rr, err := parseRecord(sample, ttl)
+		if err != nil {
+			return nil, fmt.Errorf("parse answer record failed, record:%s, err:%w", text, err)
+		}
+		rec.rr = rr
+		act.records = append(act.records, rec)
+	}
+	if err := checkCNAMEOwners(records, act.records); err != nil {
+		return nil, err
+	}
+	return act, nil
+}
+
+// checkCNAMEOwners rejects a CNAME sharing its owner with any other record,
+// templated owners are compared as written.
+func checkCNAMEOwners(texts []string, records []answerRecord) error {
+	owners := make(map[string][]uint16, len(records))
+	for i, rec := range records {
+		owner := dns.CanonicalName(rec.rr.Header().Name)
+		if rec.text != "" {
+			if fields := strings.Fields(rec.text); strings.Contains(fields[0], qnamePlaceholder) {
+				owner = strings.ToLower(dns.Fqdn(fields[0]))
+			}
+		}
+		owners[owner] = append(owners[owner], rec.rr.Header().Rrtype)
+		types := owners[owner]
+		if len(types) < 2 {
+			continue
+		}
+		for _, typ := range types {
+			if typ == dns.TypeCNAME {
+				return fmt.Errorf("cname can not coexist with other records at the same owner, record:%s", strings.TrimSpace(texts[i]))
+			}
+		}
+	}
+	return nil
+}
+
+func createAnswerAction(name string, args interface{}, _ action.Lookup) (action.IDNSAction, error) {
+	cfg := &config{}
+	if err := utils.ConvStructJson(args, cfg); err != nil {
+		return nil, err
+	}
+	return newAnswerAction(name, cfg.Records, cfg.TTL)
+}
+
+func init() {
+	action.Register("answer", createAnswerAction)
+}
@@ -0,0 +1,156 @@
+package answer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func performAnswer(t *testing.T, records []string, qname string, qtype uint16) *dns.Msg {
+	t.Helper()
+	act, err := createAnswerAction("answer", &config{Records: records}, nil)
+	if err != nil {
+		t.Fatalf("createAnswerAction error: %v", err)
+	}
+	req := new(dns.Msg)
+	req.SetQuestion(qname, qtype)
+	resp, err := act.Perform(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Perform error: %v", err)
+	}
+	if resp.Rcode != dns.RcodeSuccess || !resp.Authoritative {
+		t.Fatalf("unexpected reply header: %+v", resp.MsgHdr)
+	}
+	return resp
+}
+
+func TestAnswerActionRecordTypes(t *testing.T) {
+	records := []string{
+		`svc.internal. 300 IN MX 10 mail.internal.`,
+		`svc.internal. TXT "v=spf1 -all"`,
+		`_http._tcp.svc.internal. 30 SRV 10 5 8080 node1.internal.`,
+		`svc.internal. HTTPS 1 . alpn="h2,h3"`,
+		`svc.internal. CAA 0 issue "letsencrypt.org"`,
+		`1.0.0.10.in-addr.arpa. PTR svc.internal.`,
+	}
+	resp := performAnswer(t, records, "svc.internal.", dns.TypeMX)
+	if len(resp.Answer) != 1 {
+		t.Fatalf("expected 1 MX answer, got %v", resp.Answer)
+	}
+	mx := resp.Answer[0].(*dns.MX)
+	if mx.Mx != "mail.internal." || mx.Hdr.Ttl != 300 {
+		t.Fatalf("unexpected MX record: %v", mx)
+	}
+	resp = performAnswer(t, records, "svc.internal.", dns.TypeTXT)
+	if len(resp.Answer) != 1 || resp.Answer[0].Header().Ttl != defaultAnswerTTL {
+		t.Fatalf("expected TXT answer with default ttl, got %v", resp.Answer)
+	}
+	resp = performAnswer(t, records, "svc.internal.", dns.TypeANY)
+	if len(resp.Answer) != 4 {
+		t.Fatalf("expected all records of svc.internal. for ANY, got %v", resp.Answer)
+	}
+	resp = performAnswer(t, records, "1.0.0.10.in-addr.arpa.", dns.TypePTR)
+	if len(resp.Answer) != 1 || resp.Answer[0].(*dns.PTR).Ptr != "svc.internal." {
+		t.Fatalf("expected PTR answer, got %v", resp.Answer)
+	}
+	resp = performAnswer(t, records, "svc.internal.", dns.TypeA)
+	if len(resp.Answer) != 0 {
+		t.Fatalf("expected NODATA for A, got %v", resp.Answer)
+	}
+}
+
+func TestAnswerActionQNameTemplate(t *testing.T) {
+	records := []string{
+		`{{qname}} CNAME gateway.internal.`,
+		`gateway.internal. 120 A 10.0.0.1`,
+		`gateway.internal. TXT "served for {{qname}}"`,
+	}
+	resp := performAnswer(t, records, "Api.Example.com.", dns.TypeA)
+	if len(resp.Answer) != 2 {
+		t.Fatalf("expected CNAME and A answers, got %v", resp.Answer)
+	}
+	cname := resp.Answer[0].(*dns.CNAME)
+	if cname.Hdr.Name != "Api.Example.com." || cname.Target != "gateway.internal." {
+		t.Fatalf("unexpected CNAME record: %v", cname)
+	}
+	if resp.Answer[1].Header().Ttl != 120 {
+		t.Fatalf("unexpected A record: %v", resp.Answer[1])
+	}
+	resp = performAnswer(t, records, "a.example.com.", dns.TypeTXT)
+	if len(resp.Answer) != 2 {
+		t.Fatalf("expected CNAME and TXT answers, got %v", resp.Answer)
+	}
+	txt := resp.Answer[1].(*dns.TXT)
+	if txt.Hdr.Name != "gateway.internal." || txt.Txt[0] != "served for a.example.com." {
+		t.Fatalf("unexpected TXT record: %v", txt)
+	}
+}
+
+func TestAnswerActionOwnerMatch(t *testing.T) {
+	records := []string{
+		`a.internal. A 10.0.0.1`,
+		`b.internal. A 10.0.0.2`,
+		`alias.internal. CNAME b.internal.`,
+		`outside.internal. CNAME www.example.com.`,
+	}
+	resp := performAnswer(t, records, "A.Internal.", dns.TypeA)
+	if len(resp.Answer) != 1 || resp.Answer[0].(*dns.A).A.String() != "10.0.0.1" {
+		t.Fatalf("expected only the record of a.internal., got %v", resp.Answer)
+	}
+	resp = performAnswer(t, records, "c.internal.", dns.TypeA)
+	if len(resp.Answer) != 0 {
+		t.Fatalf("expected no records of other names, got %v", resp.Answer)
+	}
+	resp = performAnswer(t, records, "alias.internal.", dns.TypeA)
+	if len(resp.Answer) != 2 || resp.Answer[1].(*dns.A).A.String() != "10.0.0.2" {
+		t.Fatalf("expected CNAME followed to b.internal., got %v", resp.Answer)
+	}
+	resp = performAnswer(t, records, "alias.internal.", dns.TypeCNAME)
+	if len(resp.Answer) != 1 {
+		t.Fatalf("expected only the CNAME record, got %v", resp.Answer)
+	}
+	resp = performAnswer(t, records, "outside.internal.", dns.TypeA)
+	if len(resp.Answer) != 1 || resp.Answer[0].Header().Rrtype != dns.TypeCNAME {
+		t.Fatalf("expected chain to stop at an unknown target, got %v", resp.Answer)
+	}
+	loop := []string{
+		`x.internal. CNAME y.internal.`,
+		`y.internal. CNAME x.internal.`,
+	}
+	resp = performAnswer(t, loop, "x.internal.", dns.TypeA)
+	if len(resp.Answer) != 2 {
+		t.Fatalf("expected CNAME loop to stop, got %v", resp.Answer)
+	}
+}
+
+func TestAnswerActionConfig(t *testing.T) {
+	act, err := createAnswerAction("answer", map[string]interface{}{
+		"records": []interface{}{"svc.internal. A 10.0.0.1"},
+		"ttl":     30,
+	}, nil)
+	if err != nil {
+		t.Fatalf("createAnswerAction error: %v", err)
+	}
+	req := new(dns.Msg)
+	req.SetQuestion("svc.internal.", dns.TypeA)
+	resp, err := act.Perform(context.Background(), req)
+	if err != nil || len(resp.Answer) != 1 || resp.Answer[0].Header().Ttl != 30 {
+		t.Fatalf("unexpected reply %v, err:%v", resp, err)
+	}
+
+	invalid := [][]string{
+		nil,
+		{"svc.internal. A not-an-ip"},
+		{"{{qname}} MX mail.internal."},
+		{"svc.internal. A 10.0.0.1\nsvc.internal. A 10.0.0.2"},
+		{""},
+		{"svc.internal. CNAME gateway.internal.", "svc.internal. TXT \"x\""},
+		{"{{qname}} A 10.0.0.1", "{{qname}} CNAME gateway.internal."},
+	}
+	for _, records := range invalid {
+		if _, err := createAnswerAction("answer", &config{Records: records}, nil); err == nil {
+			t.Fatalf("expected error for records %q", records)
+		}
+	}
+}
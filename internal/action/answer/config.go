@@ -0,0 +1,6 @@
+package answer
+
+type config struct {
+	Records []string `json:"records"`
+	TTL     uint32   `json:"ttl"`
+}
@@ -0,0 +1,161 @@
+package block
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/xxxsen/atlas/internal/action"
+	"github.com/xxxsen/common/utils"
+)
+
+const (
+	styleNXDomain = "nxdomain"
+	styleNoData   = "nodata"
+	styleZero     = "zero"
+	styleSinkhole = "sinkhole"
+)
+
+const (
+	defaultBlockTTL = 60
+	soaMName        = "ns.atlas.invalid."
+	soaRName        = "hostmaster.atlas.invalid."
+)
+
+type blockAction struct {
+	name  string
+	style string
+	ttl   uint32
+	ipv4  []net.IP
+	ipv6  []net.IP
+}
+
+func (a *blockAction) Name() string {
+	return a.name
+}
+
+func (a *blockAction) Type() string {
+	return "block"
+}
+
+// Perform answers with the configured style. Address styles answer A and AAAA
+// questions they have addresses for, everything else gets NODATA. Negative
+// replies carry a SOA whose minimum is the configured ttl, so that resolvers
+// cache them as described in RFC 2308.
+func (a *blockAction) Perform(ctx context.Context, req *dns.Msg) (*dns.Msg, error) {
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	resp.Authoritative = true
+	if a.style == styleNXDomain {
+		resp.Rcode = dns.RcodeNameError
+	}
+	for _, q := range req.Question {
+		if a.style == styleZero || a.style == styleSinkhole {
+			resp.Answer = append(resp.Answer, a.addressAnswers(q)...)
+		}
+	}
+	if len(resp.Answer) == 0 && len(req.Question) > 0 {
+		resp.Ns = append(resp.Ns, a.soa(req.Question[0]))
+	}
+	return resp, nil
+}
+
+func (a *blockAction) addressAnswers(q dns.Question) []dns.RR {
+	var rs []dns.RR
+	hdr := dns.RR_Header{Name: q.Name, Rrtype: q.Qtype, Class: q.Qclass, Ttl: a.ttl}
+	switch q.Qtype {
+	case dns.TypeA:
+		for _, ip := range a.ipv4 {
+			rs = append(rs, &dns.A{Hdr: hdr, A: ip})
+		}
+	case dns.TypeAAAA:
+		for _, ip := range a.ipv6 {
+			rs = append(rs, &dns.AAAA{Hdr: hdr, AAAA: ip})
+		}
+	}
+	return rs
+}
+
+func (a *blockAction) soa(q dns.Question) dns.RR {
+	return &dns.SOA{
+		Hdr: dns.RR_Header{
+			Name:   q.Name,
+			Rrtype: dns.TypeSOA,
+			Class:  dns.ClassINET,
+			Ttl:    a.ttl,
+		},
+		Ns:      soaMName,
+		Mbox:    soaRName,
+		Serial:  1,
+		Refresh: 1800,
+		Retry:   900,
+		Expire:  604800,
+		Minttl:  a.ttl,
+	}
+}
+
+func parseIPs(ips []string, v4 bool) ([]net.IP, error) {
+	rs := make([]net.IP, 0, len(ips))
+	for _, item := range ips {
+		ip := net.ParseIP(strings.TrimSpace(item))
+		if ip == nil || (ip.To4() != nil) != v4 {
+			return nil, fmt.Errorf("invalid sinkhole ip:%s", item)
+		}
+		if v4 {
+			ip = ip.To4()
+		}
+		rs = append(rs, ip)
+	}
+	return rs, nil
+}
+
+func newBlockAction(name string, c *config) (action.IDNSAction, error) {
+	act := &blockAction{
+		name:  name,
+		style: strings.ToLower(strings.TrimSpace(c.Style)),
+		ttl:   c.TTL,
+	}
+	if act.style == "" {
+		act.style = styleNXDomain
+	}
+	if act.ttl == 0 {
+		act.ttl = defaultBlockTTL
+	}
+	switch act.style {
+	case styleNXDomain, styleNoData:
+		if len(c.IPv4) != 0 || len(c.IPv6) != 0 {
+			return nil, fmt.Errorf("block style %s does not take sinkhole ips", act.style)
+		}
+	case styleZero:
+		act.ipv4 = []net.IP{net.IPv4zero.To4()}
+		act.ipv6 = []net.IP{net.IPv6zero}
+	case styleSinkhole:
+		var err error
+		if act.ipv4, err = parseIPs(c.IPv4, true); err != nil {
+			return nil, err
+		}
+		if act.ipv6, err = parseIPs(c.IPv6, false); err != nil {
+			return nil, err
+		}
+		if len(act.ipv4) == 0 && len(act.ipv6) == 0 {
+			return nil, fmt.Errorf("block style sinkhole requires ipv4 or ipv6")
+		}
+	default:
+		return nil, fmt.Errorf("unknown block style:%s", c.Style)
+	}
+	return act, nil
+}
+
+func createBlockAction(name string, args interface{}, _ action.Lookup) (action.IDNSAction, error) {
+	c := &config{}
+	if err := utils.ConvStructJson(args, c); err != nil {
+		return nil, err
+	}
+	return newBlockAction(name, c)
+}
+
+func init() {
+	action.Register("block", createBlockAction)
+}
@@ -0,0 +1,97 @@
+package block
+
+import (
+	"context"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func performBlock(t *testing.T, c *config, qtype uint16) *dns.Msg {
+	t.Helper()
+	act, err := createBlockAction("block", c, nil)
+	if err != nil {
+		t.Fatalf("createBlockAction error: %v", err)
+	}
+	req := new(dns.Msg)
+	req.SetQuestion("ads.example.com.", qtype)
+	resp, err := act.Perform(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Perform error: %v", err)
+	}
+	return resp
+}
+
+func checkSOA(t *testing.T, resp *dns.Msg, ttl uint32) {
+	t.Helper()
+	if len(resp.Ns) != 1 {
+		t.Fatalf("expected SOA in authority section, got %v", resp.Ns)
+	}
+	soa, ok := resp.Ns[0].(*dns.SOA)
+	if !ok || soa.Hdr.Ttl != ttl || soa.Minttl != ttl {
+		t.Fatalf("unexpected SOA: %v", resp.Ns[0])
+	}
+}
+
+func TestBlockActionNXDomain(t *testing.T) {
+	resp := performBlock(t, &config{}, dns.TypeA)
+	if resp.Rcode != dns.RcodeNameError || len(resp.Answer) != 0 {
+		t.Fatalf("expected NXDOMAIN, got %v", resp)
+	}
+	checkSOA(t, resp, defaultBlockTTL)
+}
+
+func TestBlockActionNoData(t *testing.T) {
+	resp := performBlock(t, &config{Style: "nodata", TTL: 300}, dns.TypeAAAA)
+	if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 0 {
+		t.Fatalf("expected NODATA, got %v", resp)
+	}
+	checkSOA(t, resp, 300)
+}
+
+func TestBlockActionZero(t *testing.T) {
+	resp := performBlock(t, &config{Style: "zero", TTL: 10}, dns.TypeA)
+	if len(resp.Answer) != 1 || resp.Answer[0].(*dns.A).A.String() != "0.0.0.0" || resp.Answer[0].Header().Ttl != 10 {
+		t.Fatalf("unexpected answer: %v", resp.Answer)
+	}
+	if len(resp.Ns) != 0 {
+		t.Fatalf("unexpected authority section: %v", resp.Ns)
+	}
+	resp = performBlock(t, &config{Style: "zero"}, dns.TypeAAAA)
+	if len(resp.Answer) != 1 || resp.Answer[0].(*dns.AAAA).AAAA.String() != "::" {
+		t.Fatalf("unexpected answer: %v", resp.Answer)
+	}
+	resp = performBlock(t, &config{Style: "zero"}, dns.TypeMX)
+	if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 0 {
+		t.Fatalf("expected NODATA for MX, got %v", resp)
+	}
+	checkSOA(t, resp, defaultBlockTTL)
+}
+
+func TestBlockActionSinkhole(t *testing.T) {
+	c := &config{Style: "sinkhole", IPv4: []string{"10.0.0.1", "10.0.0.2"}}
+	resp := performBlock(t, c, dns.TypeA)
+	if len(resp.Answer) != 2 || resp.Answer[1].(*dns.A).A.String() != "10.0.0.2" {
+		t.Fatalf("unexpected answer: %v", resp.Answer)
+	}
+	resp = performBlock(t, c, dns.TypeAAAA)
+	if len(resp.Answer) != 0 {
+		t.Fatalf("expected NODATA without ipv6 sinkhole, got %v", resp.Answer)
+	}
+	checkSOA(t, resp, defaultBlockTTL)
+}
+
+func TestBlockActionInvalidConfig(t *testing.T) {
+	invalid := []*config{
+		{Style: "refused"},
+		{Style: "sinkhole"},
+		{Style: "sinkhole", IPv4: []string{"fd00::1"}},
+		{Style: "sinkhole", IPv6: []string{"10.0.0.1"}},
+		{Style: "nxdomain", IPv4: []string{"10.0.0.1"}},
+	}
+	for _, c := range invalid {
+		if _, err := createBlockAction("block", c, nil); err == nil {
+			t.Fatalf("expected error for %+v", c)
+		}
+	}
+}
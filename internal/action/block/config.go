@@ -0,0 +1,8 @@
+package block
+
+type config struct {
+	Style string   `json:"style"`
+	TTL   uint32   `json:"ttl"`
+	IPv4  []string `json:"ipv4"`
+	IPv6  []string `json:"ipv6"`
+}
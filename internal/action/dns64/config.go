@@ -0,0 +1,7 @@
+package dns64
+
+type config struct {
+	Action  string   `json:"action"`
+	Prefix  string   `json:"prefix"`
+	Exclude []string `json:"exclude"`
+}
@@ -0,0 +1,245 @@
+package dns64
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/xxxsen/atlas/internal/action"
+	"github.com/xxxsen/atlas/internal/data/ipset"
+	"github.com/xxxsen/atlas/internal/dnsutil"
+	"github.com/xxxsen/common/utils"
+)
+
+const defaultPrefix = "64:ff9b::/96"
+
+type dns64Action struct {
+	name    string
+	next    action.IDNSAction
+	prefix  netip.Prefix
+	exclude *ipset.Set
+}
+
+func (a *dns64Action) Name() string {
+	return a.name
+}
+
+func (a *dns64Action) Type() string {
+	return "dns64"
+}
+
+// Perform synthesizes AAAA records from A records when a name has no usable
+// AAAA record, and answers PTR queries under the prefix with a CNAME to the
+// in-addr.arpa name of the embedded IPv4 address (RFC 6147 5.3.1).
+func (a *dns64Action) Perform(ctx context.Context, req *dns.Msg) (*dns.Msg, error) {
+	if len(req.Question) != 1 {
+		return a.next.Perform(ctx, req)
+	}
+	switch req.Question[0].Qtype {
+	case dns.TypeAAAA:
+		return a.performAAAA(ctx, req)
+	case dns.TypePTR:
+		return a.performPTR(ctx, req)
+	}
+	return a.next.Perform(ctx, req)
+}
+
+func (a *dns64Action) performAAAA(ctx context.Context, req *dns.Msg) (*dns.Msg, error) {
+	resp, err := a.next.Perform(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp != nil && resp.Rcode == dns.RcodeNameError {
+		return resp, nil
+	}
+	// other rcodes are treated like an empty answer (RFC 6147 5.1.2)
+	if resp != nil && resp.Rcode == dns.RcodeSuccess && a.stripExcluded(resp) {
+		return resp, nil
+	}
+	aReq := req.Copy()
+	aReq.Question[0].Qtype = dns.TypeA
+	aResp, err := a.next.Perform(ctx, aReq)
+	if err != nil || aResp == nil || aResp.Rcode != dns.RcodeSuccess {
+		return resp, nil
+	}
+	ttlLimit := negativeTTL(resp)
+	out := new(dns.Msg)
+	out.SetReply(req)
+	out.RecursionAvailable = aResp.RecursionAvailable
+	synthesized := false
+	for _, rr := range aResp.Answer {
+		switch v := rr.(type) {
+		case *dns.CNAME:
+			out.Answer = append(out.Answer, dns.Copy(v))
+		case *dns.A:
+			addr, ok := netip.AddrFromSlice(v.A)
+			if !ok || a.exclude.Contains(addr) {
+				continue
+			}
+			hdr := v.Hdr
+			hdr.Rrtype = dns.TypeAAAA
+			hdr.Ttl = min(hdr.Ttl, ttlLimit)
+			out.Answer = append(out.Answer, &dns.AAAA{Hdr: hdr, AAAA: a.embed(addr.Unmap()).AsSlice()})
+			synthesized = true
+		}
+	}
+	if !synthesized {
+		return resp, nil
+	}
+	return out, nil
+}
+
+// stripExcluded drops excluded AAAA records and reports whether a usable one is left.
+func (a *dns64Action) stripExcluded(resp *dns.Msg) bool {
+	found := false
+	answers := resp.Answer[:0]
+	for _, rr := range resp.Answer {
+		if v, ok := rr.(*dns.AAAA); ok {
+			// IPv4-mapped addresses (::ffff:0:0/96) are always excluded, they are
+			// not reachable on an IPv6-only network
+			addr, _ := netip.AddrFromSlice(v.AAAA)
+			if addr.Is4In6() || a.exclude.Contains(addr) {
+				continue
+			}
+			found = true
+		}
+		answers = append(answers, rr)
+	}
+	resp.Answer = answers
+	return found
+}
+
+// negativeTTL returns the negative caching ttl of a response without AAAA,
+// synthesized records must not outlive it (RFC 6147 5.1.7).
+func negativeTTL(resp *dns.Msg) uint32 {
+	ttl := ^uint32(0)
+	if resp == nil {
+		return ttl
+	}
+	for _, rr := range resp.Ns {
+		if soa, ok := rr.(*dns.SOA); ok {
+			ttl = min(ttl, soa.Hdr.Ttl, soa.Minttl)
+		}
+	}
+	return ttl
+}
+
+func (a *dns64Action) performPTR(ctx context.Context, req *dns.Msg) (*dns.Msg, error) {
+	q := req.Question[0]
+	addr, ok := dnsutil.ParseReverseAddr(q.Name)
+	if !ok || !addr.Is6() || !a.prefix.Contains(addr) {
+		return a.next.Perform(ctx, req)
+	}
+	target, err := dns.ReverseAddr(a.extract(addr).String())
+	if err != nil {
+		return nil, err
+	}
+	sub := req.Copy()
+	sub.Question[0].Name = target
+	subResp, err := a.next.Perform(ctx, sub)
+	if err != nil {
+		return nil, err
+	}
+	out := new(dns.Msg)
+	out.SetReply(req)
+	var ttl uint32 = 300
+	if subResp != nil {
+		out.Rcode = subResp.Rcode
+		out.RecursionAvailable = subResp.RecursionAvailable
+		out.Ns = subResp.Ns
+		for _, rr := range subResp.Answer {
+			ttl = min(ttl, rr.Header().Ttl)
+		}
+	}
+	out.Answer = append(out.Answer, &dns.CNAME{
+		Hdr:    dns.RR_Header{Name: q.Name, Rrtype: dns.TypeCNAME, Class: q.Qclass, Ttl: ttl},
+		Target: target,
+	})
+	if subResp != nil {
+		out.Answer = append(out.Answer, subResp.Answer...)
+	}
+	return out, nil
+}
+
+// embed places v4 into the prefix as described in RFC 6052 2.2, bits 64-71
+// (byte 8) are reserved and stay zero.
+func (a *dns64Action) embed(v4 netip.Addr) netip.Addr {
+	b := a.prefix.Addr().As16()
+	src := v4.As4()
+	pos := a.prefix.Bits() / 8
+	for _, octet := range src {
+		if pos == 8 {
+			pos++
+		}
+		b[pos] = octet
+		pos++
+	}
+	return netip.AddrFrom16(b)
+}
+
+func (a *dns64Action) extract(v6 netip.Addr) netip.Addr {
+	b := v6.As16()
+	var dst [4]byte
+	pos := a.prefix.Bits() / 8
+	for i := range dst {
+		if pos == 8 {
+			pos++
+		}
+		dst[i] = b[pos]
+		pos++
+	}
+	return netip.AddrFrom4(dst)
+}
+
+func newDNS64Action(name string, c *config, next action.IDNSAction) (action.IDNSAction, error) {
+	text := strings.TrimSpace(c.Prefix)
+	if text == "" {
+		text = defaultPrefix
+	}
+	prefix, err := netip.ParsePrefix(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dns64 prefix:%s, err:%w", text, err)
+	}
+	switch prefix.Bits() {
+	case 32, 40, 48, 56, 64, 96:
+	default:
+		return nil, fmt.Errorf("dns64 prefix length should be one of 32, 40, 48, 56, 64, 96, got:%d", prefix.Bits())
+	}
+	if !prefix.Addr().Is6() || prefix.Addr().Is4In6() {
+		return nil, fmt.Errorf("dns64 prefix should be an ipv6 prefix:%s", text)
+	}
+	if prefix.Bits() == 96 && prefix.Masked().Addr().As16()[8] != 0 {
+		return nil, fmt.Errorf("dns64 prefix:%s should keep bits 64-71 zero", text)
+	}
+	exclude, err := ipset.Parse(c.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	return &dns64Action{
+		name:    name,
+		next:    next,
+		prefix:  prefix.Masked(),
+		exclude: exclude,
+	}, nil
+}
+
+func createDNS64Action(name string, args interface{}, lookup action.Lookup) (action.IDNSAction, error) {
+	c := &config{}
+	if err := utils.ConvStructJson(args, c); err != nil {
+		return nil, err
+	}
+	if c.Action == "" {
+		return nil, fmt.Errorf("dns64 action requires action to wrap")
+	}
+	next, err := lookup(c.Action)
+	if err != nil {
+		return nil, err
+	}
+	return newDNS64Action(name, c, next)
+}
+
+func init() {
+	action.Register("dns64", createDNS64Action)
+}
@@ -0,0 +1,155 @@
+package dns64
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+type stubAction struct {
+	records map[string][]string // "name qtype" => records
+	rcode   map[string]int
+	soa     bool
+}
+
+func (s *stubAction) Name() string { return "stub" }
+
+func (s *stubAction) Type() string { return "stub" }
+
+func (s *stubAction) Perform(ctx context.Context, req *dns.Msg) (*dns.Msg, error) {
+	q := req.Question[0]
+	key := q.Name + " " + dns.TypeToString[q.Qtype]
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	resp.RecursionAvailable = true
+	resp.Rcode = s.rcode[key]
+	for _, text := range s.records[key] {
+		rr, err := dns.NewRR(text)
+		if err != nil {
+			return nil, err
+		}
+		resp.Answer = append(resp.Answer, rr)
+	}
+	if len(resp.Answer) == 0 && s.soa {
+		soa, _ := dns.NewRR("example.com. 3600 IN SOA ns.example.com. admin.example.com. 1 7200 900 1209600 60")
+		resp.Ns = append(resp.Ns, soa)
+	}
+	return resp, nil
+}
+
+func perform(t *testing.T, c *config, next *stubAction, name string, qtype uint16) *dns.Msg {
+	t.Helper()
+	act, err := newDNS64Action("dns64", c, next)
+	if err != nil {
+		t.Fatalf("newDNS64Action error: %v", err)
+	}
+	req := new(dns.Msg)
+	req.SetQuestion(name, qtype)
+	resp, err := act.Perform(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Perform error: %v", err)
+	}
+	return resp
+}
+
+func TestDNS64Synthesize(t *testing.T) {
+	next := &stubAction{soa: true, records: map[string][]string{
+		"v4only.example.com. A": {
+			"v4only.example.com. 300 IN CNAME edge.example.com.",
+			"edge.example.com. 300 IN A 192.0.2.33",
+			"edge.example.com. 300 IN A 10.0.0.1",
+		},
+	}}
+	resp := perform(t, &config{Exclude: []string{"10.0.0.0/8"}}, next, "v4only.example.com.", dns.TypeAAAA)
+	if resp.Question[0].Qtype != dns.TypeAAAA || len(resp.Answer) != 2 {
+		t.Fatalf("expected CNAME and one synthesized AAAA, got %v", resp.Answer)
+	}
+	aaaa := resp.Answer[1].(*dns.AAAA)
+	if aaaa.Hdr.Name != "edge.example.com." || aaaa.AAAA.String() != "64:ff9b::c000:221" {
+		t.Fatalf("unexpected synthesized record: %v", aaaa)
+	}
+	if aaaa.Hdr.Ttl != 60 {
+		t.Fatalf("ttl should be limited by the negative ttl, got %d", aaaa.Hdr.Ttl)
+	}
+}
+
+func TestDNS64KeepsNativeAAAA(t *testing.T) {
+	next := &stubAction{records: map[string][]string{
+		"dual.example.com. AAAA":   {"dual.example.com. 300 IN AAAA 2001:db8::1"},
+		"dual.example.com. A":      {"dual.example.com. 300 IN A 192.0.2.1"},
+		"mapped.example.com. AAAA": {"mapped.example.com. 300 IN AAAA ::ffff:192.0.2.2"},
+		"mapped.example.com. A":    {"mapped.example.com. 300 IN A 192.0.2.2"},
+	}}
+	resp := perform(t, &config{}, next, "dual.example.com.", dns.TypeAAAA)
+	if len(resp.Answer) != 1 || resp.Answer[0].(*dns.AAAA).AAAA.String() != "2001:db8::1" {
+		t.Fatalf("expected native AAAA, got %v", resp.Answer)
+	}
+	// an IPv4-mapped AAAA does not count as usable
+	resp = perform(t, &config{}, next, "mapped.example.com.", dns.TypeAAAA)
+	if len(resp.Answer) != 1 || resp.Answer[0].(*dns.AAAA).AAAA.String() != "64:ff9b::c000:202" {
+		t.Fatalf("expected synthesized AAAA, got %v", resp.Answer)
+	}
+}
+
+func TestDNS64NXDomainAndNoA(t *testing.T) {
+	next := &stubAction{rcode: map[string]int{"missing.example.com. AAAA": dns.RcodeNameError}}
+	resp := perform(t, &config{}, next, "missing.example.com.", dns.TypeAAAA)
+	if resp.Rcode != dns.RcodeNameError {
+		t.Fatalf("expected NXDOMAIN, got %v", resp)
+	}
+	resp = perform(t, &config{}, next, "empty.example.com.", dns.TypeAAAA)
+	if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 0 {
+		t.Fatalf("expected NODATA, got %v", resp)
+	}
+}
+
+func TestDNS64PTR(t *testing.T) {
+	next := &stubAction{records: map[string][]string{
+		"33.2.0.192.in-addr.arpa. PTR": {"33.2.0.192.in-addr.arpa. 600 IN PTR host.example.com."},
+	}}
+	name, _ := dns.ReverseAddr("64:ff9b::192.0.2.33")
+	resp := perform(t, &config{}, next, name, dns.TypePTR)
+	if len(resp.Answer) != 2 {
+		t.Fatalf("expected CNAME and PTR, got %v", resp.Answer)
+	}
+	cname := resp.Answer[0].(*dns.CNAME)
+	if cname.Hdr.Name != name || cname.Target != "33.2.0.192.in-addr.arpa." {
+		t.Fatalf("unexpected CNAME: %v", cname)
+	}
+	if ptr := resp.Answer[1].(*dns.PTR); ptr.Ptr != "host.example.com." {
+		t.Fatalf("unexpected PTR: %v", ptr)
+	}
+}
+
+func TestDNS64Embed(t *testing.T) {
+	// examples from RFC 6052 2.4
+	tests := map[string]string{
+		"2001:db8::/32":         "2001:db8:c000:221::",
+		"2001:db8:100::/40":     "2001:db8:1c0:2:21::",
+		"2001:db8:122::/48":     "2001:db8:122:c000:2:2100::",
+		"2001:db8:122:300::/56": "2001:db8:122:3c0:0:221::",
+		"2001:db8:122:344::/64": "2001:db8:122:344:c0:2:2100:0",
+		"2001:db8:122:344::/96": "2001:db8:122:344::c000:221",
+	}
+	for prefix, want := range tests {
+		act, err := newDNS64Action("dns64", &config{Prefix: prefix}, &stubAction{})
+		if err != nil {
+			t.Fatalf("newDNS64Action error: %v", err)
+		}
+		a := act.(*dns64Action)
+		got := a.embed(netip.MustParseAddr("192.0.2.33"))
+		if got.String() != want {
+			t.Fatalf("%s: expected %s, got %s", prefix, want, got)
+		}
+		if back := a.extract(got); back.String() != "192.0.2.33" {
+			t.Fatalf("%s: extract returned %s", prefix, back)
+		}
+	}
+	for _, prefix := range []string{"2001:db8::/33", "192.0.2.0/24", "2001:db8:0:0:ff00::/96"} {
+		if _, err := newDNS64Action("dns64", &config{Prefix: prefix}, &stubAction{}); err == nil {
+			t.Fatalf("expected error for prefix %s", prefix)
+		}
+	}
+}
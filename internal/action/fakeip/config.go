@@ -0,0 +1,11 @@
+package fakeip
+
+type config struct {
+	IPv4     string `json:"ipv4"`
+	IPv6     string `json:"ipv6"`
+	TTL      uint32 `json:"ttl"`
+	Size     int    `json:"size"`
+	File     string `json:"file"`
+	Interval int64  `json:"interval"` // seconds
+	Action   string `json:"action"`
+}
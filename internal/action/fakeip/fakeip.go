@@ -0,0 +1,305 @@
+package fakeip
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/xxxsen/atlas/internal/action"
+	"github.com/xxxsen/atlas/internal/dnsutil"
+	"github.com/xxxsen/common/logutil"
+	"github.com/xxxsen/common/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultFakeIPTTL = 1
+	defaultPoolSize  = 65535
+	defaultInterval  = 10 * time.Minute
+	// LookupPattern is the admin path LookupHandler is meant to be served at.
+	LookupPattern = "/fakeip"
+)
+
+type fakeipAction struct {
+	name string
+	pool *pool
+	ttl  uint32
+	next action.IDNSAction // serves other query types, nil for NODATA
+
+	file      string // empty when the mapping is not persisted
+	ch        chan bool
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+func (a *fakeipAction) Name() string {
+	return a.name
+}
+
+func (a *fakeipAction) Type() string {
+	return "fakeip"
+}
+
+// Perform answers A and AAAA with the fake address of the name and PTR for
+// addresses of the pool. Other query types go to the next action when one is
+// configured, or get an empty NOERROR reply.
+func (a *fakeipAction) Perform(ctx context.Context, req *dns.Msg) (*dns.Msg, error) {
+	if len(req.Question) != 1 {
+		return a.passthrough(ctx, req)
+	}
+	q := req.Question[0]
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	resp.Authoritative = true
+	hdr := dns.RR_Header{Name: q.Name, Rrtype: q.Qtype, Class: q.Qclass, Ttl: a.ttl}
+	switch q.Qtype {
+	case dns.TypeA:
+		if !a.pool.v4.IsValid() {
+			return resp, nil
+		}
+		idx := a.pool.assign(normalizeDomain(q.Name))
+		resp.Answer = append(resp.Answer, &dns.A{Hdr: hdr, A: a.pool.addr4(idx).AsSlice()})
+	case dns.TypeAAAA:
+		if !a.pool.v6.IsValid() {
+			return resp, nil
+		}
+		idx := a.pool.assign(normalizeDomain(q.Name))
+		resp.Answer = append(resp.Answer, &dns.AAAA{Hdr: hdr, AAAA: a.pool.addr6(idx).AsSlice()})
+	case dns.TypePTR:
+		addr, ok := dnsutil.ParseReverseAddr(q.Name)
+		if !ok {
+			return a.passthrough(ctx, req)
+		}
+		if _, ok := a.pool.indexOf(addr); !ok {
+			return a.passthrough(ctx, req)
+		}
+		domain, ok := a.pool.domainOf(addr)
+		if !ok {
+			resp.Rcode = dns.RcodeNameError
+			return resp, nil
+		}
+		resp.Answer = append(resp.Answer, &dns.PTR{Hdr: hdr, Ptr: domain})
+	default:
+		return a.passthrough(ctx, req)
+	}
+	return resp, nil
+}
+
+func (a *fakeipAction) passthrough(ctx context.Context, req *dns.Msg) (*dns.Msg, error) {
+	if a.next != nil {
+		return a.next.Perform(ctx, req)
+	}
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	resp.Authoritative = true
+	return resp, nil
+}
+
+func normalizeDomain(name string) string {
+	return dns.Fqdn(strings.ToLower(name))
+}
+
+func (a *fakeipAction) persistLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if a.pool.isDirty() {
+				a.save()
+			}
+		case <-a.ch:
+			// save what changed since the last tick, so a restart keeps the mapping
+			if a.pool.isDirty() {
+				a.save()
+			}
+			return
+		}
+	}
+}
+
+// Close stops the persist loop and writes the pending mapping changes.
+func (a *fakeipAction) Close() error {
+	if a.ch == nil {
+		return nil
+	}
+	a.closeOnce.Do(func() {
+		close(a.ch)
+		a.wg.Wait()
+	})
+	return nil
+}
+
+func (a *fakeipAction) save() {
+	logger := logutil.GetLogger(context.Background()).With(zap.String("action", a.name), zap.String("file", a.file))
+	count, err := a.pool.save(a.file)
+	if err != nil {
+		logger.Error("save fakeip mapping failed", zap.Error(err))
+		return
+	}
+	logger.Debug("save fakeip mapping succ", zap.Int("record_count", count))
+}
+
+type lookupResult struct {
+	Action string `json:"action"`
+	Domain string `json:"domain"`
+	IPv4   string `json:"ipv4,omitempty"`
+	IPv6   string `json:"ipv6,omitempty"`
+}
+
+func (a *fakeipAction) lookupResult(domain string, idx uint64) lookupResult {
+	rs := lookupResult{Action: a.name, Domain: domain}
+	if a.pool.v4.IsValid() {
+		rs.IPv4 = a.pool.addr4(idx).String()
+	}
+	if a.pool.v6.IsValid() {
+		rs.IPv6 = a.pool.addr6(idx).String()
+	}
+	return rs
+}
+
+type lookupHandler struct {
+	actions []*fakeipAction
+}
+
+// LookupHandler returns the admin lookup over the fakeip actions among as, nil
+// when there is none so the endpoint is only exposed when fakeip is configured.
+func LookupHandler(as map[string]action.IDNSAction) http.Handler {
+	h := &lookupHandler{}
+	for _, a := range as {
+		if fa, ok := a.(*fakeipAction); ok {
+			h.actions = append(h.actions, fa)
+		}
+	}
+	if len(h.actions) == 0 {
+		return nil
+	}
+	sort.Slice(h.actions, func(i, j int) bool { return h.actions[i].name < h.actions[j].name })
+	return h
+}
+
+// ServeHTTP handles GET /fakeip?ip=198.18.0.3 and GET /fakeip?domain=example.com,
+// lookups never allocate addresses.
+func (h *lookupHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ipText, domain := r.URL.Query().Get("ip"), r.URL.Query().Get("domain")
+	var addr netip.Addr
+	switch {
+	case ipText != "":
+		var err error
+		if addr, err = netip.ParseAddr(ipText); err != nil {
+			http.Error(w, "invalid ip", http.StatusBadRequest)
+			return
+		}
+	case domain != "":
+		domain = normalizeDomain(domain)
+	default:
+		http.Error(w, "ip or domain is required", http.StatusBadRequest)
+		return
+	}
+	rs := make([]lookupResult, 0, 1)
+	for _, a := range h.actions {
+		if addr.IsValid() {
+			idx, ok := a.pool.indexOf(addr)
+			if !ok {
+				continue
+			}
+			if name, ok := a.pool.domainOf(addr); ok {
+				rs = append(rs, a.lookupResult(name, idx))
+			}
+			continue
+		}
+		if idx, ok := a.pool.peek(domain); ok {
+			rs = append(rs, a.lookupResult(domain, idx))
+		}
+	}
+	if len(rs) == 0 {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rs)
+}
+
+func parsePrefix(text string) (netip.Prefix, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return netip.Prefix{}, nil
+	}
+	p, err := netip.ParsePrefix(text)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid fakeip prefix:%s, err:%w", text, err)
+	}
+	return p, nil
+}
+
+func newFakeIPAction(name string, c *config, next action.IDNSAction) (*fakeipAction, error) {
+	v4, err := parsePrefix(c.IPv4)
+	if err != nil {
+		return nil, err
+	}
+	v6, err := parsePrefix(c.IPv6)
+	if err != nil {
+		return nil, err
+	}
+	size := c.Size
+	if size <= 0 {
+		size = defaultPoolSize
+	}
+	p, err := newPool(v4, v6, size)
+	if err != nil {
+		return nil, err
+	}
+	ttl := c.TTL
+	if ttl == 0 {
+		ttl = defaultFakeIPTTL
+	}
+	return &fakeipAction{name: name, pool: p, ttl: ttl, next: next}, nil
+}
+
+func createFakeIPAction(name string, args interface{}, lookup action.Lookup) (action.IDNSAction, error) {
+	c := &config{}
+	if err := utils.ConvStructJson(args, c); err != nil {
+		return nil, err
+	}
+	var next action.IDNSAction
+	if c.Action != "" {
+		var err error
+		if next, err = lookup(c.Action); err != nil {
+			return nil, err
+		}
+	}
+	act, err := newFakeIPAction(name, c, next)
+	if err != nil {
+		return nil, err
+	}
+	if path := strings.TrimSpace(c.File); path != "" {
+		count, err := act.pool.load(path)
+		if err != nil {
+			return nil, fmt.Errorf("load fakeip mapping failed, file:%s, err:%w", path, err)
+		}
+		logutil.GetLogger(context.Background()).Info("load fakeip mapping succ", zap.String("action", name), zap.Int("record_count", count))
+		interval := time.Duration(c.Interval) * time.Second
+		if interval <= 0 {
+			interval = defaultInterval
+		}
+		act.file = path
+		act.ch = make(chan bool)
+		act.wg.Add(1)
+		go func() {
+			defer act.wg.Done()
+			act.persistLoop(interval)
+		}()
+	}
+	return act, nil
+}
+
+func init() {
+	action.Register("fakeip", createFakeIPAction)
+}
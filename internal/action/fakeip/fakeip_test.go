@@ -0,0 +1,202 @@
+package fakeip
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"path/filepath"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/xxxsen/atlas/internal/action"
+)
+
+func newTestAction(t *testing.T, c *config) *fakeipAction {
+	t.Helper()
+	act, err := newFakeIPAction("fakeip", c, nil)
+	if err != nil {
+		t.Fatalf("newFakeIPAction error: %v", err)
+	}
+	return act
+}
+
+func query(t *testing.T, act *fakeipAction, name string, qtype uint16) *dns.Msg {
+	t.Helper()
+	req := new(dns.Msg)
+	req.SetQuestion(name, qtype)
+	resp, err := act.Perform(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Perform error: %v", err)
+	}
+	return resp
+}
+
+func TestFakeIPActionAnswers(t *testing.T) {
+	act := newTestAction(t, &config{IPv4: "198.18.0.0/15", IPv6: "fc00::/64"})
+	resp := query(t, act, "Example.com.", dns.TypeA)
+	a := resp.Answer[0].(*dns.A)
+	if a.A.String() != "198.18.0.1" || a.Hdr.Ttl != defaultFakeIPTTL {
+		t.Fatalf("unexpected A record: %v", a)
+	}
+	resp = query(t, act, "example.com.", dns.TypeAAAA)
+	if aaaa := resp.Answer[0].(*dns.AAAA); aaaa.AAAA.String() != "fc00::1" {
+		t.Fatalf("unexpected AAAA record: %v", aaaa)
+	}
+	resp = query(t, act, "other.com.", dns.TypeA)
+	if a := resp.Answer[0].(*dns.A); a.A.String() != "198.18.0.2" {
+		t.Fatalf("unexpected A record: %v", a)
+	}
+	// the mapping is stable
+	resp = query(t, act, "example.com.", dns.TypeA)
+	if a := resp.Answer[0].(*dns.A); a.A.String() != "198.18.0.1" {
+		t.Fatalf("unexpected A record: %v", a)
+	}
+
+	resp = query(t, act, "1.0.18.198.in-addr.arpa.", dns.TypePTR)
+	if ptr := resp.Answer[0].(*dns.PTR); ptr.Ptr != "example.com." {
+		t.Fatalf("unexpected PTR record: %v", ptr)
+	}
+	name, _ := dns.ReverseAddr("fc00::2")
+	resp = query(t, act, name, dns.TypePTR)
+	if ptr := resp.Answer[0].(*dns.PTR); ptr.Ptr != "other.com." {
+		t.Fatalf("unexpected PTR record: %v", ptr)
+	}
+	resp = query(t, act, "9.0.18.198.in-addr.arpa.", dns.TypePTR)
+	if resp.Rcode != dns.RcodeNameError {
+		t.Fatalf("expected NXDOMAIN for unassigned address, got %v", resp)
+	}
+	resp = query(t, act, "1.1.168.192.in-addr.arpa.", dns.TypePTR)
+	if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 0 {
+		t.Fatalf("expected NODATA for address outside the pool, got %v", resp)
+	}
+	resp = query(t, act, "example.com.", dns.TypeMX)
+	if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 0 {
+		t.Fatalf("expected NODATA for MX, got %v", resp)
+	}
+}
+
+func TestFakeIPPoolRecycle(t *testing.T) {
+	act := newTestAction(t, &config{IPv4: "10.0.0.0/30"})
+	if act.pool.capacity != 2 {
+		t.Fatalf("expected capacity 2, got %d", act.pool.capacity)
+	}
+	a := act.pool.assign("a.")
+	b := act.pool.assign("b.")
+	act.pool.assign("a.") // b becomes the least recently used
+	c := act.pool.assign("c.")
+	if c != b || act.pool.assign("a.") != a {
+		t.Fatalf("expected c to reuse the index of b, a:%d b:%d c:%d", a, b, c)
+	}
+	if _, ok := act.pool.peek("b."); ok {
+		t.Fatalf("b should have been recycled")
+	}
+	if domain, ok := act.pool.domainOf(netip.MustParseAddr("10.0.0.2")); !ok || domain != "c." {
+		t.Fatalf("unexpected reverse mapping %s", domain)
+	}
+}
+
+func TestFakeIPPoolPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fakeip.json")
+	act := newTestAction(t, &config{IPv4: "198.18.0.0/15", IPv6: "fc00::/64"})
+	for _, domain := range []string{"a.", "b.", "c."} {
+		act.pool.assign(domain)
+	}
+	if _, err := act.pool.save(path); err != nil {
+		t.Fatalf("save error: %v", err)
+	}
+
+	restored := newTestAction(t, &config{IPv4: "198.18.0.0/15", IPv6: "fc00::/64"})
+	if n, err := restored.pool.load(path); err != nil || n != 3 {
+		t.Fatalf("load error: %v, count:%d", err, n)
+	}
+	if idx, ok := restored.pool.peek("b."); !ok || idx != 2 {
+		t.Fatalf("unexpected restored index %d", idx)
+	}
+	if idx := restored.pool.assign("d."); idx != 4 {
+		t.Fatalf("new domain should get a fresh index, got %d", idx)
+	}
+
+	// records outside a changed pool are dropped
+	changed := newTestAction(t, &config{IPv4: "100.64.0.0/10"})
+	if n, err := changed.pool.load(path); err != nil || n != 0 {
+		t.Fatalf("expected no record for a changed pool, err:%v count:%d", err, n)
+	}
+}
+
+func TestFakeIPActionCloseSaves(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fakeip.json")
+	c := map[string]interface{}{"ipv4": "198.18.0.0/15", "file": path, "interval": 3600}
+	act, err := createFakeIPAction("fakeip-close-test", c, nil)
+	if err != nil {
+		t.Fatalf("createFakeIPAction error: %v", err)
+	}
+	query(t, act.(*fakeipAction), "example.com.", dns.TypeA)
+	// the mapping is written on close although the interval has not passed
+	if err := act.(*fakeipAction).Close(); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+	restored := newTestAction(t, &config{IPv4: "198.18.0.0/15"})
+	if n, err := restored.pool.load(path); err != nil || n != 1 {
+		t.Fatalf("load error: %v, count:%d", err, n)
+	}
+	if idx, ok := restored.pool.peek("example.com."); !ok || idx != 1 {
+		t.Fatalf("unexpected restored index %d", idx)
+	}
+}
+
+// otherAction stands for any action that is not a fakeip one.
+type otherAction struct {
+	action.IDNSAction
+}
+
+func TestFakeIPAdminLookup(t *testing.T) {
+	act := newTestAction(t, &config{IPv4: "198.18.0.0/15"})
+	act.pool.assign("example.com.")
+	other := newTestAction(t, &config{IPv4: "100.64.0.0/10"})
+	h := LookupHandler(map[string]action.IDNSAction{"fakeip": act, "other": other, "stub": otherAction{}})
+	if h == nil {
+		t.Fatalf("expected lookup handler")
+	}
+	if LookupHandler(map[string]action.IDNSAction{"stub": otherAction{}}) != nil {
+		t.Fatalf("expected no lookup handler without fakeip actions")
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fakeip?ip=198.18.0.1", nil))
+	var rs []lookupResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &rs); err != nil || len(rs) != 1 || rs[0].Domain != "example.com." {
+		t.Fatalf("unexpected lookup result %s, err:%v", rec.Body.String(), err)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fakeip?domain=Example.com", nil))
+	if err := json.Unmarshal(rec.Body.Bytes(), &rs); err != nil || len(rs) != 1 || rs[0].IPv4 != "198.18.0.1" {
+		t.Fatalf("unexpected lookup result %s, err:%v", rec.Body.String(), err)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fakeip?ip=198.18.0.9", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fakeip", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+}
+
+func TestFakeIPInvalidConfig(t *testing.T) {
+	invalid := []*config{
+		{},
+		{IPv4: "fc00::/64"},
+		{IPv4: "10.0.0.1/32"},
+		{IPv6: "10.0.0.0/8"},
+		{IPv4: "bad"},
+	}
+	for _, c := range invalid {
+		if _, err := newFakeIPAction("fakeip", c, nil); err == nil {
+			t.Fatalf("expected error for %+v", c)
+		}
+	}
+}
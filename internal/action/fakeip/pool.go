@@ -0,0 +1,287 @@
+package fakeip
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/golang-lru/v2/simplelru"
+)
+
+// pool hands out addresses by index, a domain gets index i and with it the
+// i-th address of both prefixes. Index 0 and the ipv4 broadcast address are
+// never used. When all indexes are taken the least recently used domain
+// gives up its index.
+type pool struct {
+	v4       netip.Prefix
+	v6       netip.Prefix
+	capacity uint64 // usable indexes are 1..capacity
+
+	mu       sync.Mutex
+	byDomain *simplelru.LRU[string, uint64]
+	byIndex  map[uint64]string
+	free     []uint64
+	next     uint64
+	dirty    bool
+}
+
+type persistRecord struct {
+	Domain string `json:"domain"`
+	IPv4   string `json:"ipv4,omitempty"`
+	IPv6   string `json:"ipv6,omitempty"`
+}
+
+func newPool(v4, v6 netip.Prefix, size int) (*pool, error) {
+	if !v4.IsValid() && !v6.IsValid() {
+		return nil, fmt.Errorf("fakeip pool requires ipv4 or ipv6 prefix")
+	}
+	capacity := uint64(math.MaxUint32)
+	if v4.IsValid() {
+		if !v4.Addr().Is4() || v4.Bits() > 30 {
+			return nil, fmt.Errorf("invalid fakeip ipv4 prefix:%s", v4)
+		}
+		capacity = min(capacity, uint64(1)<<(32-v4.Bits())-2)
+	}
+	if v6.IsValid() {
+		if !v6.Addr().Is6() || v6.Addr().Is4In6() || v6.Bits() > 126 {
+			return nil, fmt.Errorf("invalid fakeip ipv6 prefix:%s", v6)
+		}
+		if bits := 128 - v6.Bits(); bits < 32 {
+			capacity = min(capacity, uint64(1)<<bits-1)
+		}
+	}
+	if size > 0 {
+		capacity = min(capacity, uint64(size))
+	}
+	lru, err := simplelru.NewLRU[string, uint64](int(capacity), nil)
+	if err != nil {
+		return nil, err
+	}
+	return &pool{
+		v4:       v4.Masked(),
+		v6:       v6.Masked(),
+		capacity: capacity,
+		byDomain: lru,
+		byIndex:  make(map[uint64]string),
+		next:     1,
+	}, nil
+}
+
+// assign returns the index of domain, allocating one when needed.
+func (p *pool) assign(domain string) uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if idx, ok := p.byDomain.Get(domain); ok {
+		return idx
+	}
+	if uint64(p.byDomain.Len()) >= p.capacity {
+		if _, idx, ok := p.byDomain.RemoveOldest(); ok {
+			delete(p.byIndex, idx)
+			p.free = append(p.free, idx)
+		}
+	}
+	var idx uint64
+	if n := len(p.free); n > 0 {
+		idx = p.free[n-1]
+		p.free = p.free[:n-1]
+	} else {
+		idx = p.next
+		p.next++
+	}
+	p.put(domain, idx)
+	return idx
+}
+
+func (p *pool) put(domain string, idx uint64) {
+	p.byDomain.Add(domain, idx)
+	p.byIndex[idx] = domain
+	p.dirty = true
+}
+
+// domainOf returns the domain holding addr, it does not change the recency.
+func (p *pool) domainOf(addr netip.Addr) (string, bool) {
+	idx, ok := p.indexOf(addr)
+	if !ok {
+		return "", false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	domain, ok := p.byIndex[idx]
+	return domain, ok
+}
+
+// peek returns the index of domain without allocating or touching it.
+func (p *pool) peek(domain string) (uint64, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.byDomain.Peek(domain)
+}
+
+func (p *pool) addr4(idx uint64) netip.Addr {
+	b := p.v4.Addr().As4()
+	binary.BigEndian.PutUint32(b[:], binary.BigEndian.Uint32(b[:])+uint32(idx))
+	return netip.AddrFrom4(b)
+}
+
+func (p *pool) addr6(idx uint64) netip.Addr {
+	b := p.v6.Addr().As16()
+	hi, lo := binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])
+	if lo+idx < lo {
+		hi++
+	}
+	lo += idx
+	binary.BigEndian.PutUint64(b[:8], hi)
+	binary.BigEndian.PutUint64(b[8:], lo)
+	return netip.AddrFrom16(b)
+}
+
+func (p *pool) indexOf(addr netip.Addr) (uint64, bool) {
+	addr = addr.Unmap()
+	var idx uint64
+	switch {
+	case addr.Is4() && p.v4.IsValid() && p.v4.Contains(addr):
+		a, base := addr.As4(), p.v4.Addr().As4()
+		idx = uint64(binary.BigEndian.Uint32(a[:]) - binary.BigEndian.Uint32(base[:]))
+	case addr.Is6() && p.v6.IsValid() && p.v6.Contains(addr):
+		a, base := addr.As16(), p.v6.Addr().As16()
+		ahi, alo := binary.BigEndian.Uint64(a[:8]), binary.BigEndian.Uint64(a[8:])
+		bhi, blo := binary.BigEndian.Uint64(base[:8]), binary.BigEndian.Uint64(base[8:])
+		if alo < blo {
+			ahi--
+		}
+		if ahi != bhi {
+			return 0, false
+		}
+		idx = alo - blo
+	default:
+		return 0, false
+	}
+	if idx == 0 || idx > p.capacity {
+		return 0, false
+	}
+	return idx, true
+}
+
+func (p *pool) snapshot() []persistRecord {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	records := make([]persistRecord, 0, p.byDomain.Len())
+	for _, domain := range p.byDomain.Keys() { // oldest first, so loading keeps the order
+		idx, _ := p.byDomain.Peek(domain)
+		rec := persistRecord{Domain: domain}
+		if p.v4.IsValid() {
+			rec.IPv4 = p.addr4(idx).String()
+		}
+		if p.v6.IsValid() {
+			rec.IPv6 = p.addr6(idx).String()
+		}
+		records = append(records, rec)
+	}
+	p.dirty = false
+	return records
+}
+
+// save writes the mapping as json lines through a temp file and a rename.
+func (p *pool) save(path string) (int, error) {
+	records := p.snapshot()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return 0, err
+	}
+	tmpPath := path + ".temp"
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return 0, err
+	}
+	enc := json.NewEncoder(f)
+	for _, rec := range records {
+		if err := enc.Encode(rec); err != nil {
+			f.Close()
+			_ = os.Remove(tmpPath)
+			return 0, err
+		}
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return 0, err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return 0, err
+	}
+	return len(records), nil
+}
+
+// load restores a saved mapping, records whose address is outside the
+// current prefixes are dropped so that a changed pool starts over for them.
+func (p *pool) load(path string) (int, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer f.Close()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	scanner := bufio.NewScanner(f)
+	loaded := 0
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var rec persistRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil || rec.Domain == "" {
+			continue
+		}
+		idx, ok := p.recordIndex(rec)
+		if !ok {
+			continue
+		}
+		if old, ok := p.byIndex[idx]; ok {
+			p.byDomain.Remove(old)
+		}
+		if oldIdx, ok := p.byDomain.Peek(rec.Domain); ok {
+			delete(p.byIndex, oldIdx)
+		}
+		p.put(rec.Domain, idx)
+		p.next = max(p.next, idx+1)
+		loaded++
+	}
+	if err := scanner.Err(); err != nil {
+		return loaded, err
+	}
+	for idx := uint64(1); idx < p.next; idx++ {
+		if _, ok := p.byIndex[idx]; !ok {
+			p.free = append(p.free, idx)
+		}
+	}
+	p.dirty = false
+	return loaded, nil
+}
+
+func (p *pool) recordIndex(rec persistRecord) (uint64, bool) {
+	text := rec.IPv4
+	if !p.v4.IsValid() {
+		text = rec.IPv6
+	}
+	addr, err := netip.ParseAddr(text)
+	if err != nil {
+		return 0, false
+	}
+	return p.indexOf(addr)
+}
+
+func (p *pool) isDirty() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.dirty
+}
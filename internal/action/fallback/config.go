@@ -0,0 +1,11 @@
+package fallback
+
+type config struct {
+	Primary   string   `json:"primary"`
+	Secondary string   `json:"secondary"`
+	Timeout   int64    `json:"timeout"`    // milliseconds
+	RaceDelay int64    `json:"race_delay"` // milliseconds
+	CIDRs     []string `json:"cidrs"`
+	Files     []string `json:"files"`
+	RuleSets  []string `json:"rulesets"`
+}
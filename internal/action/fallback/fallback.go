@@ -0,0 +1,217 @@
+package fallback
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/xxxsen/atlas/internal/action"
+	"github.com/xxxsen/atlas/internal/data/ipset"
+	"github.com/xxxsen/atlas/internal/data/ruleset"
+	"github.com/xxxsen/common/logutil"
+	"github.com/xxxsen/common/utils"
+	"go.uber.org/zap"
+)
+
+type result struct {
+	resp *dns.Msg
+	err  error
+}
+
+type fallbackAction struct {
+	name      string
+	primary   action.IDNSAction
+	secondary action.IDNSAction
+	timeout   time.Duration
+	raceDelay time.Duration
+	accepted  *ipset.Set // nil when answers are not checked
+}
+
+func (a *fallbackAction) Name() string {
+	return a.name
+}
+
+func (a *fallbackAction) Type() string {
+	return "fallback"
+}
+
+// Perform calls the primary action and switches to the secondary when the
+// primary fails, times out or returns an unacceptable answer. With a race
+// delay the secondary also starts once the primary had that head start, and
+// the first usable response wins. When the secondary fails as well, the
+// primary response is returned if there is one.
+func (a *fallbackAction) Perform(ctx context.Context, req *dns.Msg) (*dns.Msg, error) {
+	logger := logutil.GetLogger(ctx).With(zap.String("action", a.name))
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	primaryCtx, cancelPrimary := context.WithCancel(ctx)
+	defer cancelPrimary()
+
+	primaryCh := a.run(primaryCtx, a.primary, req)
+	var secondaryCh chan result
+	secondaryStarted := false
+	startSecondary := func(reason string) {
+		if secondaryStarted {
+			return
+		}
+		secondaryStarted = true
+		logger.Debug("fallback action start secondary", zap.String("reason", reason))
+		secondaryCh = a.run(ctx, a.secondary, req)
+	}
+
+	var raceC, timeoutC <-chan time.Time
+	if a.raceDelay > 0 {
+		t := time.NewTimer(a.raceDelay)
+		defer t.Stop()
+		raceC = t.C
+	}
+	if a.timeout > 0 {
+		t := time.NewTimer(a.timeout)
+		defer t.Stop()
+		timeoutC = t.C
+	}
+
+	var primaryResp *dns.Msg
+	var primaryErr, secondaryErr error
+	for {
+		select {
+		case r := <-primaryCh:
+			primaryCh = nil
+			reason, ok := a.accept(r)
+			if ok {
+				return r.resp, nil
+			}
+			primaryResp, primaryErr = r.resp, r.err
+			startSecondary(reason)
+		case <-raceC:
+			raceC = nil
+			startSecondary("race")
+		case <-timeoutC:
+			timeoutC = nil
+			if primaryCh != nil {
+				primaryCh = nil
+				cancelPrimary()
+				primaryErr = fmt.Errorf("primary action:%s timeout", a.primary.Name())
+				startSecondary("timeout")
+			}
+		case r := <-secondaryCh:
+			secondaryCh = nil
+			if r.err == nil && r.resp != nil {
+				return r.resp, nil
+			}
+			secondaryErr = r.err
+			if secondaryErr == nil {
+				secondaryErr = fmt.Errorf("secondary action:%s returned no response", a.secondary.Name())
+			}
+			logger.Error("fallback action secondary failed", zap.Error(secondaryErr))
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if primaryCh == nil && secondaryStarted && secondaryCh == nil {
+			if primaryResp != nil {
+				return primaryResp, nil
+			}
+			return nil, fmt.Errorf("fallback action:%s failed, primary err:%v, secondary err:%w", a.name, primaryErr, secondaryErr)
+		}
+	}
+}
+
+func (a *fallbackAction) run(ctx context.Context, act action.IDNSAction, req *dns.Msg) chan result {
+	ch := make(chan result, 1)
+	go func() {
+		resp, err := act.Perform(ctx, req.Copy())
+		ch <- result{resp: resp, err: err}
+	}()
+	return ch
+}
+
+// accept reports whether the primary result can be used, the reason explains a rejection.
+func (a *fallbackAction) accept(r result) (string, bool) {
+	if r.err != nil {
+		return "error", false
+	}
+	if r.resp == nil {
+		return "empty response", false
+	}
+	if r.resp.Rcode == dns.RcodeServerFailure || r.resp.Rcode == dns.RcodeRefused {
+		return dns.RcodeToString[r.resp.Rcode], false
+	}
+	if a.accepted == nil {
+		return "", true
+	}
+	for _, rr := range r.resp.Answer {
+		var addr netip.Addr
+		switch v := rr.(type) {
+		case *dns.A:
+			addr, _ = netip.AddrFromSlice(v.A)
+		case *dns.AAAA:
+			addr, _ = netip.AddrFromSlice(v.AAAA)
+		default:
+			continue
+		}
+		if !a.accepted.Contains(addr) {
+			return "answer ip " + addr.Unmap().String() + " not accepted", false
+		}
+	}
+	return "", true
+}
+
+func newFallbackAction(name string, c *config, primary, secondary action.IDNSAction) (action.IDNSAction, error) {
+	if c.Timeout < 0 || c.RaceDelay < 0 {
+		return nil, fmt.Errorf("fallback action timeout and race_delay should not be negative")
+	}
+	act := &fallbackAction{
+		name:      name,
+		primary:   primary,
+		secondary: secondary,
+		timeout:   time.Duration(c.Timeout) * time.Millisecond,
+		raceDelay: time.Duration(c.RaceDelay) * time.Millisecond,
+	}
+	if len(c.CIDRs) > 0 || len(c.Files) > 0 || len(c.RuleSets) > 0 {
+		cidrs := append([]string(nil), c.CIDRs...)
+		fileCIDRs, err := ipset.LoadFiles(c.Files)
+		if err != nil {
+			return nil, err
+		}
+		cidrs = append(cidrs, fileCIDRs...)
+		if len(c.RuleSets) > 0 {
+			rs, err := ruleset.LoadFiles(c.RuleSets)
+			if err != nil {
+				return nil, err
+			}
+			// answers are destination addresses, so only IP-CIDR / ip_cidr entries apply
+			cidrs = append(cidrs, rs.CIDRs...)
+		}
+		set, err := ipset.Parse(cidrs)
+		if err != nil {
+			return nil, err
+		}
+		act.accepted = set
+	}
+	return act, nil
+}
+
+func createFallbackAction(name string, args interface{}, lookup action.Lookup) (action.IDNSAction, error) {
+	c := &config{}
+	if err := utils.ConvStructJson(args, c); err != nil {
+		return nil, err
+	}
+	if c.Primary == "" || c.Secondary == "" {
+		return nil, fmt.Errorf("fallback action requires primary and secondary")
+	}
+	primary, err := lookup(c.Primary)
+	if err != nil {
+		return nil, err
+	}
+	secondary, err := lookup(c.Secondary)
+	if err != nil {
+		return nil, err
+	}
+	return newFallbackAction(name, c, primary, secondary)
+}
+
+func init() {
+	action.Register("fallback", createFallbackAction)
+}
@@ -0,0 +1,249 @@
+package fallback
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/xxxsen/atlas/internal/action"
+	_ "github.com/xxxsen/atlas/internal/action/forward"
+	"github.com/xxxsen/atlas/internal/resolver"
+)
+
+type stubAction struct {
+	name  string
+	delay time.Duration
+	rcode int
+	ip    string
+	err   error
+	calls atomic.Int32
+}
+
+func (s *stubAction) Name() string { return s.name }
+
+func (s *stubAction) Type() string { return "stub" }
+
+func (s *stubAction) Perform(ctx context.Context, req *dns.Msg) (*dns.Msg, error) {
+	s.calls.Add(1)
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	resp.Rcode = s.rcode
+	if s.ip != "" {
+		resp.Answer = append(resp.Answer, &dns.A{
+			Hdr: dns.RR_Header{Name: req.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+			A:   net.ParseIP(s.ip),
+		})
+	}
+	return resp, nil
+}
+
+func performFallback(t *testing.T, c *config, primary, secondary *stubAction) (*dns.Msg, error) {
+	t.Helper()
+	act, err := newFallbackAction("fallback", c, primary, secondary)
+	if err != nil {
+		t.Fatalf("newFallbackAction error: %v", err)
+	}
+	req := new(dns.Msg)
+	req.SetQuestion("example.com.", dns.TypeA)
+	return act.Perform(context.Background(), req)
+}
+
+func answerIP(t *testing.T, resp *dns.Msg) string {
+	t.Helper()
+	if resp == nil || len(resp.Answer) != 1 {
+		t.Fatalf("unexpected response %v", resp)
+	}
+	return resp.Answer[0].(*dns.A).A.String()
+}
+
+func TestFallbackActionPrimaryOK(t *testing.T) {
+	primary := &stubAction{name: "primary", ip: "1.1.1.1"}
+	secondary := &stubAction{name: "secondary", ip: "8.8.8.8"}
+	resp, err := performFallback(t, &config{}, primary, secondary)
+	if err != nil || answerIP(t, resp) != "1.1.1.1" {
+		t.Fatalf("expected primary answer, err:%v", err)
+	}
+	if secondary.calls.Load() != 0 {
+		t.Fatalf("secondary should not be called")
+	}
+}
+
+func TestFallbackActionPrimaryFailures(t *testing.T) {
+	tests := []struct {
+		name    string
+		primary *stubAction
+		c       *config
+	}{
+		{"error", &stubAction{err: errors.New("boom")}, &config{}},
+		{"servfail", &stubAction{rcode: dns.RcodeServerFailure}, &config{}},
+		{"refused", &stubAction{rcode: dns.RcodeRefused}, &config{}},
+		{"timeout", &stubAction{delay: time.Second, ip: "1.1.1.1"}, &config{Timeout: 20}},
+		{"ip not accepted", &stubAction{ip: "203.0.113.1"}, &config{CIDRs: []string{"1.0.0.0/8"}}},
+	}
+	for _, tt := range tests {
+		tt.primary.name = "primary"
+		secondary := &stubAction{name: "secondary", ip: "8.8.8.8"}
+		resp, err := performFallback(t, tt.c, tt.primary, secondary)
+		if err != nil {
+			t.Fatalf("%s: Perform error: %v", tt.name, err)
+		}
+		if answerIP(t, resp) != "8.8.8.8" {
+			t.Fatalf("%s: expected secondary answer", tt.name)
+		}
+	}
+}
+
+func TestFallbackActionAcceptedIP(t *testing.T) {
+	primary := &stubAction{name: "primary", ip: "1.2.3.4"}
+	secondary := &stubAction{name: "secondary", ip: "8.8.8.8"}
+	resp, err := performFallback(t, &config{CIDRs: []string{"1.0.0.0/8"}}, primary, secondary)
+	if err != nil || answerIP(t, resp) != "1.2.3.4" {
+		t.Fatalf("expected primary answer, err:%v", err)
+	}
+}
+
+func TestFallbackActionAcceptedRuleSet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cn.txt")
+	if err := os.WriteFile(path, []byte("IP-CIDR,1.0.0.0/8\nSRC-IP-CIDR,203.0.113.0/24\n"), 0o644); err != nil {
+		t.Fatalf("write rule-set: %v", err)
+	}
+	c := &config{RuleSets: []string{path}}
+	primary := &stubAction{name: "primary", ip: "1.2.3.4"}
+	secondary := &stubAction{name: "secondary", ip: "8.8.8.8"}
+	resp, err := performFallback(t, c, primary, secondary)
+	if err != nil || answerIP(t, resp) != "1.2.3.4" {
+		t.Fatalf("expected primary answer, err:%v", err)
+	}
+	// source entries are not answer addresses
+	primary = &stubAction{name: "primary", ip: "203.0.113.1"}
+	resp, err = performFallback(t, c, primary, secondary)
+	if err != nil || answerIP(t, resp) != "8.8.8.8" {
+		t.Fatalf("expected secondary answer, err:%v", err)
+	}
+}
+
+func TestFallbackActionRace(t *testing.T) {
+	primary := &stubAction{name: "primary", delay: 500 * time.Millisecond, ip: "1.1.1.1"}
+	secondary := &stubAction{name: "secondary", ip: "8.8.8.8"}
+	start := time.Now()
+	resp, err := performFallback(t, &config{RaceDelay: 20}, primary, secondary)
+	if err != nil || answerIP(t, resp) != "8.8.8.8" {
+		t.Fatalf("expected secondary to win the race, err:%v", err)
+	}
+	if cost := time.Since(start); cost > 300*time.Millisecond {
+		t.Fatalf("race should not wait for primary, cost:%v", cost)
+	}
+
+	// the primary wins when it answers within the head start
+	primary = &stubAction{name: "primary", ip: "1.1.1.1"}
+	secondary = &stubAction{name: "secondary", ip: "8.8.8.8"}
+	resp, err = performFallback(t, &config{RaceDelay: 200}, primary, secondary)
+	if err != nil || answerIP(t, resp) != "1.1.1.1" || secondary.calls.Load() != 0 {
+		t.Fatalf("expected primary answer without secondary, err:%v", err)
+	}
+}
+
+func TestFallbackActionBothFail(t *testing.T) {
+	primary := &stubAction{name: "primary", rcode: dns.RcodeServerFailure}
+	secondary := &stubAction{name: "secondary", err: errors.New("boom")}
+	resp, err := performFallback(t, &config{}, primary, secondary)
+	if err != nil || resp.Rcode != dns.RcodeServerFailure {
+		t.Fatalf("expected primary SERVFAIL response, resp:%v err:%v", resp, err)
+	}
+
+	primary = &stubAction{name: "primary", err: errors.New("boom")}
+	if _, err := performFallback(t, &config{}, primary, secondary); err == nil {
+		t.Fatalf("expected error when both actions fail")
+	}
+}
+
+func TestFallbackActionInvalidConfig(t *testing.T) {
+	if _, err := createFallbackAction("fallback", map[string]interface{}{"primary": "a"}, action.MapLookup(nil)); err == nil {
+		t.Fatalf("expected error without secondary")
+	}
+	if _, err := createFallbackAction("fallback", map[string]interface{}{"primary": "missing-a", "secondary": "missing-b"}, action.MapLookup(nil)); err == nil {
+		t.Fatalf("expected error for unknown actions")
+	}
+	if _, err := newFallbackAction("fallback", &config{CIDRs: []string{"bad"}}, &stubAction{}, &stubAction{}); err == nil {
+		t.Fatalf("expected error for invalid cidr")
+	}
+}
+
+// startUpstream runs a udp dns server answering every A query with ip.
+func startUpstream(t *testing.T, ip string) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen error: %v", err)
+	}
+	server := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetReply(req)
+		resp.Answer = append(resp.Answer, &dns.A{
+			Hdr: dns.RR_Header{Name: req.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+			A:   net.ParseIP(ip),
+		})
+		_ = w.WriteMsg(resp)
+	})}
+	go func() {
+		_ = server.ActivateAndServe()
+	}()
+	t.Cleanup(func() {
+		_ = server.Shutdown()
+	})
+	return "udp://" + pc.LocalAddr().String()
+}
+
+func TestFallbackActionForwardCache(t *testing.T) {
+	resolver.ConfigureCache(resolver.CacheOptions{Size: 10})
+	defer resolver.ConfigureCache(resolver.CacheOptions{})
+
+	primary, err := action.MakeAction("forward", "fallback-primary", map[string]interface{}{
+		"server_list": []string{startUpstream(t, "203.0.113.1")},
+		"parallel":    1,
+	}, nil)
+	if err != nil {
+		t.Fatalf("create primary error: %v", err)
+	}
+	secondary, err := action.MakeAction("forward", "fallback-secondary", map[string]interface{}{
+		"server_list": []string{startUpstream(t, "1.1.1.1")},
+		"parallel":    1,
+	}, nil)
+	if err != nil {
+		t.Fatalf("create secondary error: %v", err)
+	}
+	act, err := newFallbackAction("fallback", &config{CIDRs: []string{"1.0.0.0/8"}}, primary, secondary)
+	if err != nil {
+		t.Fatalf("newFallbackAction error: %v", err)
+	}
+	// the rejected primary answer is cached, it must not be served as the
+	// secondary answer on this or any later query
+	for i := 0; i < 2; i++ {
+		req := new(dns.Msg)
+		req.SetQuestion("example.com.", dns.TypeA)
+		resp, err := act.Perform(context.Background(), req)
+		if err != nil || answerIP(t, resp) != "1.1.1.1" {
+			t.Fatalf("query %d: expected secondary answer, err:%v", i, err)
+		}
+	}
+	req := new(dns.Msg)
+	req.SetQuestion("example.com.", dns.TypeA)
+	resp, err := primary.Perform(context.Background(), req)
+	if err != nil || answerIP(t, resp) != "203.0.113.1" {
+		t.Fatalf("expected primary to keep its own answer, err:%v", err)
+	}
+}
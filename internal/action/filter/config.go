@@ -0,0 +1,8 @@
+package filter
+
+type config struct {
+	Action      string   `json:"action"`
+	Types       []string `json:"types"`
+	HTTPSParams []string `json:"https_params"`
+	Prefer      string   `json:"prefer"`
+}
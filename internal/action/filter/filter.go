@@ -0,0 +1,238 @@
+package filter
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+	"github.com/xxxsen/atlas/internal/action"
+	"github.com/xxxsen/common/logutil"
+	"github.com/xxxsen/common/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	preferIPv4 = "ipv4"
+	preferIPv6 = "ipv6"
+)
+
+type filterAction struct {
+	name   string
+	next   action.IDNSAction
+	types  map[uint16]struct{}
+	params map[dns.SVCBKey]struct{}
+	prefer string
+}
+
+func (a *filterAction) Name() string {
+	return a.name
+}
+
+func (a *filterAction) Type() string {
+	return "filter"
+}
+
+func (a *filterAction) Perform(ctx context.Context, req *dns.Msg) (*dns.Msg, error) {
+	resp, err := a.query(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp != nil {
+		a.strip(resp)
+	}
+	return resp, nil
+}
+
+// query resolves req through the next action. With a preference, a query for
+// the other family is answered with an empty NOERROR when the name has
+// records of the preferred family, the lookup for them runs in parallel.
+func (a *filterAction) query(ctx context.Context, req *dns.Msg) (*dns.Msg, error) {
+	if a.prefer == "" || len(req.Question) != 1 {
+		return a.next.Perform(ctx, req)
+	}
+	preferred, other := dns.TypeA, dns.TypeAAAA
+	if a.prefer == preferIPv6 {
+		preferred, other = dns.TypeAAAA, dns.TypeA
+	}
+	if req.Question[0].Qtype != other {
+		return a.next.Perform(ctx, req)
+	}
+	probe := req.Copy()
+	probe.Question[0].Qtype = preferred
+	var (
+		wg       sync.WaitGroup
+		probeRsp *dns.Msg
+		probeErr error
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		probeRsp, probeErr = a.next.Perform(ctx, probe)
+	}()
+	resp, err := a.next.Perform(ctx, req)
+	wg.Wait()
+	if err != nil {
+		return nil, err
+	}
+	if probeErr != nil {
+		logutil.GetLogger(ctx).Debug("filter action probe preferred family failed", zap.String("action", a.name), zap.Error(probeErr))
+		return resp, nil
+	}
+	if !hasType(probeRsp, preferred) {
+		return resp, nil
+	}
+	empty := new(dns.Msg)
+	empty.SetReply(req)
+	if resp != nil {
+		empty.RecursionAvailable = resp.RecursionAvailable
+		empty.Ns = resp.Ns
+	}
+	return empty, nil
+}
+
+func hasType(resp *dns.Msg, typ uint16) bool {
+	if resp == nil || resp.Rcode != dns.RcodeSuccess {
+		return false
+	}
+	for _, rr := range resp.Answer {
+		if rr.Header().Rrtype == typ {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *filterAction) strip(resp *dns.Msg) {
+	resp.Answer = a.stripSection(resp.Answer)
+	resp.Ns = a.stripSection(resp.Ns)
+	resp.Extra = a.stripSection(resp.Extra)
+}
+
+func (a *filterAction) stripSection(rrs []dns.RR) []dns.RR {
+	if len(a.types) == 0 && len(a.params) == 0 {
+		return rrs
+	}
+	out := rrs[:0]
+	for _, rr := range rrs {
+		if _, ok := a.types[rr.Header().Rrtype]; ok {
+			continue
+		}
+		switch v := rr.(type) {
+		case *dns.HTTPS:
+			v.Value = a.stripParams(v.Value)
+		case *dns.SVCB:
+			v.Value = a.stripParams(v.Value)
+		}
+		out = append(out, rr)
+	}
+	return out
+}
+
+func (a *filterAction) stripParams(kvs []dns.SVCBKeyValue) []dns.SVCBKeyValue {
+	if len(a.params) == 0 {
+		return kvs
+	}
+	out := kvs[:0]
+	for _, kv := range kvs {
+		if _, ok := a.params[kv.Key()]; ok {
+			continue
+		}
+		if m, ok := kv.(*dns.SVCBMandatory); ok {
+			// mandatory must not list keys that are no longer present (RFC 9460 8)
+			codes := make([]dns.SVCBKey, 0, len(m.Code))
+			for _, code := range m.Code {
+				if _, ok := a.params[code]; !ok {
+					codes = append(codes, code)
+				}
+			}
+			if len(codes) == 0 {
+				continue
+			}
+			kv = &dns.SVCBMandatory{Code: codes}
+		}
+		out = append(out, kv)
+	}
+	return out
+}
+
+func parseTypes(names []string) (map[uint16]struct{}, error) {
+	rs := make(map[uint16]struct{}, len(names))
+	for _, name := range names {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		typ, ok := dns.StringToType[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown rr type:%s", name)
+		}
+		if typ == dns.TypeOPT {
+			return nil, fmt.Errorf("rr type OPT can not be filtered")
+		}
+		rs[typ] = struct{}{}
+	}
+	return rs, nil
+}
+
+func parseSVCBKey(name string) (dns.SVCBKey, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for key := dns.SVCB_MANDATORY; key <= dns.SVCB_DOHPATH; key++ {
+		if key.String() == name {
+			return key, nil
+		}
+	}
+	if strings.HasPrefix(name, "key") {
+		if v, err := strconv.ParseUint(name[3:], 10, 16); err == nil && v != 65535 {
+			return dns.SVCBKey(v), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown https param:%s", name)
+}
+
+func newFilterAction(name string, c *config, next action.IDNSAction) (action.IDNSAction, error) {
+	types, err := parseTypes(c.Types)
+	if err != nil {
+		return nil, err
+	}
+	params := make(map[dns.SVCBKey]struct{}, len(c.HTTPSParams))
+	for _, item := range c.HTTPSParams {
+		key, err := parseSVCBKey(item)
+		if err != nil {
+			return nil, err
+		}
+		params[key] = struct{}{}
+	}
+	prefer := strings.ToLower(strings.TrimSpace(c.Prefer))
+	if prefer != "" && prefer != preferIPv4 && prefer != preferIPv6 {
+		return nil, fmt.Errorf("unknown prefer:%s, should be ipv4 or ipv6", c.Prefer)
+	}
+	if len(types) == 0 && len(params) == 0 && prefer == "" {
+		return nil, fmt.Errorf("filter action requires one of types, https_params or prefer")
+	}
+	return &filterAction{
+		name:   name,
+		next:   next,
+		types:  types,
+		params: params,
+		prefer: prefer,
+	}, nil
+}
+
+func createFilterAction(name string, args interface{}, lookup action.Lookup) (action.IDNSAction, error) {
+	c := &config{}
+	if err := utils.ConvStructJson(args, c); err != nil {
+		return nil, err
+	}
+	if c.Action == "" {
+		return nil, fmt.Errorf("filter action requires action to wrap")
+	}
+	next, err := lookup(c.Action)
+	if err != nil {
+		return nil, err
+	}
+	return newFilterAction(name, c, next)
+}
+
+func init() {
+	action.Register("filter", createFilterAction)
+}
@@ -0,0 +1,176 @@
+package filter
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+type stubAction struct {
+	mu      sync.Mutex
+	records map[uint16][]dns.RR
+	errs    map[uint16]error
+	queries []uint16
+}
+
+func (s *stubAction) Name() string { return "stub" }
+
+func (s *stubAction) Type() string { return "stub" }
+
+func (s *stubAction) Perform(ctx context.Context, req *dns.Msg) (*dns.Msg, error) {
+	qtype := req.Question[0].Qtype
+	s.mu.Lock()
+	s.queries = append(s.queries, qtype)
+	s.mu.Unlock()
+	if err := s.errs[qtype]; err != nil {
+		return nil, err
+	}
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	for _, rr := range s.records[qtype] {
+		resp.Answer = append(resp.Answer, dns.Copy(rr))
+	}
+	resp.SetEdns0(1232, false)
+	return resp, nil
+}
+
+func mustRR(t *testing.T, s string) dns.RR {
+	t.Helper()
+	rr, err := dns.NewRR(s)
+	if err != nil {
+		t.Fatalf("NewRR error: %v", err)
+	}
+	return rr
+}
+
+func perform(t *testing.T, c *config, next *stubAction, qtype uint16) *dns.Msg {
+	t.Helper()
+	act, err := newFilterAction("filter", c, next)
+	if err != nil {
+		t.Fatalf("newFilterAction error: %v", err)
+	}
+	req := new(dns.Msg)
+	req.SetQuestion("example.com.", qtype)
+	resp, err := act.Perform(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Perform error: %v", err)
+	}
+	return resp
+}
+
+func TestFilterActionStripTypes(t *testing.T) {
+	next := &stubAction{records: map[uint16][]dns.RR{
+		dns.TypeAAAA: {
+			mustRR(t, "example.com. 60 IN CNAME edge.example.net."),
+			mustRR(t, "edge.example.net. 60 IN AAAA 2001:db8::1"),
+		},
+	}}
+	resp := perform(t, &config{Types: []string{"aaaa"}}, next, dns.TypeAAAA)
+	if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 1 || resp.Answer[0].Header().Rrtype != dns.TypeCNAME {
+		t.Fatalf("expected only CNAME left, got %v", resp.Answer)
+	}
+	if resp.IsEdns0() == nil {
+		t.Fatalf("OPT record should be kept")
+	}
+}
+
+func TestFilterActionStripHTTPSParams(t *testing.T) {
+	next := &stubAction{records: map[uint16][]dns.RR{
+		dns.TypeHTTPS: {
+			mustRR(t, `example.com. 60 IN HTTPS 1 . alpn="h2,h3" ipv4hint="192.0.2.1" ipv6hint="2001:db8::1" ech="AEX+DQBBpQAgACDhkR3x"`),
+		},
+	}}
+	resp := perform(t, &config{HTTPSParams: []string{"ipv6hint", "ech"}}, next, dns.TypeHTTPS)
+	if len(resp.Answer) != 1 {
+		t.Fatalf("unexpected answer %v", resp.Answer)
+	}
+	rr := resp.Answer[0].(*dns.HTTPS)
+	if len(rr.Value) != 2 || rr.Value[0].Key() != dns.SVCB_ALPN || rr.Value[1].Key() != dns.SVCB_IPV4HINT {
+		t.Fatalf("unexpected https params: %v", rr)
+	}
+}
+
+func TestFilterActionStripMandatoryParams(t *testing.T) {
+	next := &stubAction{records: map[uint16][]dns.RR{
+		dns.TypeHTTPS: {
+			mustRR(t, `example.com. 60 IN HTTPS 1 . mandatory=alpn,ech alpn="h2" ech="AEX+DQBBpQAgACDhkR3x"`),
+			mustRR(t, `example.com. 60 IN HTTPS 2 . mandatory=ipv6hint ipv6hint="2001:db8::1" port=8443`),
+		},
+	}}
+	resp := perform(t, &config{HTTPSParams: []string{"ipv6hint", "ech"}}, next, dns.TypeHTTPS)
+	if len(resp.Answer) != 2 {
+		t.Fatalf("unexpected answer %v", resp.Answer)
+	}
+	rr := resp.Answer[0].(*dns.HTTPS)
+	if len(rr.Value) != 2 || rr.Value[0].String() != "alpn" || rr.Value[1].Key() != dns.SVCB_ALPN {
+		t.Fatalf("expected stripped keys removed from mandatory: %v", rr)
+	}
+	rr = resp.Answer[1].(*dns.HTTPS)
+	if len(rr.Value) != 1 || rr.Value[0].Key() != dns.SVCB_PORT {
+		t.Fatalf("expected empty mandatory removed: %v", rr)
+	}
+	if _, err := resp.Pack(); err != nil {
+		t.Fatalf("pack stripped response error: %v", err)
+	}
+}
+
+func TestFilterActionPreferIPv4(t *testing.T) {
+	aaaa := mustRR(t, "example.com. 60 IN AAAA 2001:db8::1")
+	a := &dns.A{Hdr: dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60}, A: net.IPv4(192, 0, 2, 1)}
+
+	next := &stubAction{records: map[uint16][]dns.RR{dns.TypeA: {a}, dns.TypeAAAA: {aaaa}}}
+	resp := perform(t, &config{Prefer: "ipv4"}, next, dns.TypeAAAA)
+	if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 0 {
+		t.Fatalf("expected empty NOERROR, got %v", resp)
+	}
+	if len(next.queries) != 2 {
+		t.Fatalf("expected AAAA and A lookups, got %v", next.queries)
+	}
+
+	resp = perform(t, &config{Prefer: "ipv4"}, next, dns.TypeA)
+	if len(resp.Answer) != 1 {
+		t.Fatalf("A query should pass through, got %v", resp.Answer)
+	}
+
+	// ipv6 only names keep their AAAA records
+	next = &stubAction{records: map[uint16][]dns.RR{dns.TypeAAAA: {aaaa}}}
+	resp = perform(t, &config{Prefer: "ipv4"}, next, dns.TypeAAAA)
+	if len(resp.Answer) != 1 {
+		t.Fatalf("expected AAAA answer, got %v", resp.Answer)
+	}
+
+	// a failed probe keeps the AAAA answer
+	next = &stubAction{records: map[uint16][]dns.RR{dns.TypeAAAA: {aaaa}}, errs: map[uint16]error{dns.TypeA: errors.New("timeout")}}
+	resp = perform(t, &config{Prefer: "ipv4"}, next, dns.TypeAAAA)
+	if len(resp.Answer) != 1 {
+		t.Fatalf("expected AAAA answer, got %v", resp.Answer)
+	}
+
+	next = &stubAction{records: map[uint16][]dns.RR{dns.TypeA: {a}, dns.TypeAAAA: {aaaa}}}
+	resp = perform(t, &config{Prefer: "ipv6"}, next, dns.TypeA)
+	if len(resp.Answer) != 0 {
+		t.Fatalf("expected empty answer for A when preferring ipv6, got %v", resp.Answer)
+	}
+}
+
+func TestFilterActionInvalidConfig(t *testing.T) {
+	invalid := []*config{
+		{},
+		{Types: []string{"NOPE"}},
+		{Types: []string{"OPT"}},
+		{HTTPSParams: []string{"nope"}},
+		{Prefer: "ipv5"},
+	}
+	for _, c := range invalid {
+		if _, err := newFilterAction("filter", c, &stubAction{}); err == nil {
+			t.Fatalf("expected error for %+v", c)
+		}
+	}
+	if _, err := parseSVCBKey("key65280"); err != nil {
+		t.Fatalf("private svcb keys should be accepted: %v", err)
+	}
+}
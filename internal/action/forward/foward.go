@@ -40,7 +40,7 @@ func (h *forwardAction) Type() string {
 	return "forward"
 }
 
-func createForwardAction(name string, args interface{}) (action.IDNSAction, error) {
+func createForwardAction(name string, args interface{}, _ action.Lookup) (action.IDNSAction, error) {
 	c := &config{}
 	if err := utils.ConvStructJson(args, c); err != nil {
 		return nil, err
@@ -45,7 +45,7 @@ func TestForwardActionPerformSuccess(t *testing.T) {
 	act, err := createForwardAction("test", map[string]interface{}{
 		"server_list": []string{"mockforward://ok"},
 		"parallel":    1,
-	})
+	}, nil)
 	if err != nil {
 		t.Fatalf("createForwardAction error: %v", err)
 	}
@@ -70,7 +70,7 @@ func TestForwardActionPerformError(t *testing.T) {
 	act, err := createForwardAction("fail", map[string]interface{}{
 		"server_list": []string{"mockforwarderr://fail"},
 		"parallel":    1,
-	})
+	}, nil)
 	if err != nil {
 		t.Fatalf("createForwardAction error: %v", err)
 	}
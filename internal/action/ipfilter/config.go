@@ -0,0 +1,8 @@
+package ipfilter
+
+type config struct {
+	Action   string   `json:"action"`
+	CIDRs    []string `json:"cidrs"`
+	Files    []string `json:"files"`
+	RuleSets []string `json:"rulesets"`
+}
@@ -0,0 +1,117 @@
+package ipfilter
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+
+	"github.com/miekg/dns"
+	"github.com/xxxsen/atlas/internal/action"
+	"github.com/xxxsen/atlas/internal/data/ipset"
+	"github.com/xxxsen/atlas/internal/data/ruleset"
+	"github.com/xxxsen/common/logutil"
+	"github.com/xxxsen/common/utils"
+	"go.uber.org/zap"
+)
+
+type ipfilterAction struct {
+	name string
+	next action.IDNSAction
+	set  *ipset.Set
+}
+
+func (a *ipfilterAction) Name() string {
+	return a.name
+}
+
+func (a *ipfilterAction) Type() string {
+	return "ipfilter"
+}
+
+// Perform removes A/AAAA answers inside the configured ranges. A response
+// that had addresses but has none left is turned into NXDOMAIN, like the
+// bogus-nxdomain option of dnsmasq.
+func (a *ipfilterAction) Perform(ctx context.Context, req *dns.Msg) (*dns.Msg, error) {
+	resp, err := a.next.Perform(ctx, req)
+	if err != nil || resp == nil || resp.Rcode != dns.RcodeSuccess {
+		return resp, err
+	}
+	var removed []string
+	kept := 0
+	answers := resp.Answer[:0]
+	for _, rr := range resp.Answer {
+		var addr netip.Addr
+		switch v := rr.(type) {
+		case *dns.A:
+			addr, _ = netip.AddrFromSlice(v.A)
+		case *dns.AAAA:
+			addr, _ = netip.AddrFromSlice(v.AAAA)
+		default:
+			answers = append(answers, rr)
+			continue
+		}
+		if a.set.Contains(addr) {
+			removed = append(removed, addr.Unmap().String())
+			continue
+		}
+		kept++
+		answers = append(answers, rr)
+	}
+	resp.Answer = answers
+	if len(removed) == 0 {
+		return resp, nil
+	}
+	logger := logutil.GetLogger(ctx).With(zap.String("action", a.name), zap.Strings("removed_ips", removed))
+	if kept > 0 {
+		logger.Debug("ipfilter action removed answers")
+		return resp, nil
+	}
+	logger.Debug("ipfilter action removed all answers, reply NXDOMAIN")
+	resp.Rcode = dns.RcodeNameError
+	resp.Answer = nil
+	return resp, nil
+}
+
+func newIPFilterAction(name string, cidrs []string, next action.IDNSAction) (action.IDNSAction, error) {
+	if len(cidrs) == 0 {
+		return nil, fmt.Errorf("ipfilter action requires cidrs, files or rulesets")
+	}
+	set, err := ipset.Parse(cidrs)
+	if err != nil {
+		return nil, err
+	}
+	return &ipfilterAction{name: name, next: next, set: set}, nil
+}
+
+func createIPFilterAction(name string, args interface{}, lookup action.Lookup) (action.IDNSAction, error) {
+	c := &config{}
+	if err := utils.ConvStructJson(args, c); err != nil {
+		return nil, err
+	}
+	if c.Action == "" {
+		return nil, fmt.Errorf("ipfilter action requires action to wrap")
+	}
+	next, err := lookup(c.Action)
+	if err != nil {
+		return nil, err
+	}
+	cidrs := append([]string(nil), c.CIDRs...)
+	fileCIDRs, err := ipset.LoadFiles(c.Files)
+	if err != nil {
+		return nil, err
+	}
+	cidrs = append(cidrs, fileCIDRs...)
+	if len(c.RuleSets) > 0 {
+		rs, err := ruleset.LoadFiles(c.RuleSets)
+		if err != nil {
+			return nil, err
+		}
+		// answers are destination addresses, so only IP-CIDR / ip_cidr entries apply
+		cidrs = append(cidrs, rs.CIDRs...)
+	}
+	return newIPFilterAction(name, cidrs, next)
+}
+
+func init() {
+	action.Register("ipfilter", createIPFilterAction)
+}
@@ -0,0 +1,122 @@
+package ipfilter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/xxxsen/atlas/internal/action"
+)
+
+type stubAction struct {
+	records []string
+	rcode   int
+}
+
+func (s *stubAction) Name() string { return "stub" }
+
+func (s *stubAction) Type() string { return "stub" }
+
+func (s *stubAction) Perform(ctx context.Context, req *dns.Msg) (*dns.Msg, error) {
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	resp.Rcode = s.rcode
+	for _, text := range s.records {
+		rr, err := dns.NewRR(text)
+		if err != nil {
+			return nil, err
+		}
+		resp.Answer = append(resp.Answer, rr)
+	}
+	return resp, nil
+}
+
+func perform(t *testing.T, next *stubAction) *dns.Msg {
+	t.Helper()
+	act, err := newIPFilterAction("ipfilter", []string{"198.51.100.0/24", "2001:db8:bad::/48"}, next)
+	if err != nil {
+		t.Fatalf("newIPFilterAction error: %v", err)
+	}
+	req := new(dns.Msg)
+	req.SetQuestion("www.example.com.", dns.TypeA)
+	resp, err := act.Perform(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Perform error: %v", err)
+	}
+	return resp
+}
+
+func TestIPFilterActionPartial(t *testing.T) {
+	resp := perform(t, &stubAction{records: []string{
+		"www.example.com. 60 IN CNAME edge.example.net.",
+		"edge.example.net. 60 IN A 198.51.100.7",
+		"edge.example.net. 60 IN A 192.0.2.1",
+	}})
+	if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 2 {
+		t.Fatalf("expected CNAME and one A left, got %v", resp)
+	}
+	if a := resp.Answer[1].(*dns.A); a.A.String() != "192.0.2.1" {
+		t.Fatalf("unexpected answer %v", a)
+	}
+}
+
+func TestIPFilterActionBogusNXDomain(t *testing.T) {
+	resp := perform(t, &stubAction{records: []string{
+		"www.example.com. 60 IN CNAME search.isp.example.",
+		"search.isp.example. 60 IN A 198.51.100.7",
+		"search.isp.example. 60 IN AAAA 2001:db8:bad::1",
+	}})
+	if resp.Rcode != dns.RcodeNameError || len(resp.Answer) != 0 {
+		t.Fatalf("expected NXDOMAIN, got %v", resp)
+	}
+}
+
+func TestIPFilterActionUntouched(t *testing.T) {
+	// responses without addresses are not converted
+	resp := perform(t, &stubAction{records: []string{"www.example.com. 60 IN CNAME edge.example.net."}})
+	if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 1 {
+		t.Fatalf("expected response untouched, got %v", resp)
+	}
+	resp = perform(t, &stubAction{rcode: dns.RcodeServerFailure})
+	if resp.Rcode != dns.RcodeServerFailure {
+		t.Fatalf("expected SERVFAIL untouched, got %v", resp)
+	}
+}
+
+func TestCreateIPFilterAction(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "bogus.txt")
+	if err := os.WriteFile(file, []byte("# isp redirect\n198.51.100.7\n"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	rules := filepath.Join(dir, "rules.yaml")
+	if err := os.WriteFile(rules, []byte("payload:\n  - IP-CIDR,203.0.113.0/24\n  - SRC-IP-CIDR,10.0.0.0/8\n"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	lookup := action.MapLookup(map[string]action.IDNSAction{
+		"ipfilter-upstream": &stubAction{records: []string{"www.example.com. 60 IN A 203.0.113.9", "www.example.com. 60 IN A 10.0.0.1"}},
+	})
+	act, err := createIPFilterAction("ipfilter", map[string]interface{}{
+		"action":   "ipfilter-upstream",
+		"files":    []interface{}{file},
+		"rulesets": []interface{}{rules},
+	}, lookup)
+	if err != nil {
+		t.Fatalf("createIPFilterAction error: %v", err)
+	}
+	req := new(dns.Msg)
+	req.SetQuestion("www.example.com.", dns.TypeA)
+	resp, err := act.Perform(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Perform error: %v", err)
+	}
+	// SRC-IP-CIDR entries describe clients and are not used for answers
+	if len(resp.Answer) != 1 || resp.Answer[0].(*dns.A).A.String() != "10.0.0.1" {
+		t.Fatalf("unexpected answer %v", resp.Answer)
+	}
+	if _, err := createIPFilterAction("ipfilter", map[string]interface{}{"action": "ipfilter-upstream"}, lookup); err == nil {
+		t.Fatalf("expected error without cidrs")
+	}
+}
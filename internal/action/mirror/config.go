@@ -0,0 +1,9 @@
+package mirror
+
+type config struct {
+	Primary      string   `json:"primary"`
+	Shadows      []string `json:"shadows"`
+	Concurrency  int      `json:"concurrency"`
+	Timeout      int64    `json:"timeout"`       // milliseconds
+	TTLTolerance uint32   `json:"ttl_tolerance"` // seconds
+}
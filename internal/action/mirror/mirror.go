@@ -0,0 +1,210 @@
+package mirror
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/xxxsen/atlas/internal/action"
+	"github.com/xxxsen/atlas/internal/resolver"
+	"github.com/xxxsen/common/logutil"
+	"github.com/xxxsen/common/trace"
+	"github.com/xxxsen/common/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultConcurrency = 16
+	defaultTimeout     = 5 * time.Second
+)
+
+type mirrorAction struct {
+	name         string
+	primary      action.IDNSAction
+	shadows      []action.IDNSAction
+	sem          chan struct{}
+	timeout      time.Duration
+	ttlTolerance uint32
+	done         func(diffs []string) // called when a shadow query finishes, for tests
+}
+
+func (a *mirrorAction) Name() string {
+	return a.name
+}
+
+func (a *mirrorAction) Type() string {
+	return "mirror"
+}
+
+// Perform answers from the primary action and replays the query against the
+// shadow actions in the background. Shadow queries bypass the cache so that
+// they reach their upstream and never change what clients get, they are
+// dropped when the concurrency limit is reached. TTLs are only compared when
+// the primary answer did not come from the cache, cached ttls have decayed.
+func (a *mirrorAction) Perform(ctx context.Context, req *dns.Msg) (*dns.Msg, error) {
+	shadowReq := req.Copy()
+	ctx, cacheStatus := resolver.WithCacheStatus(ctx)
+	resp, err := a.primary.Perform(ctx, req)
+	if err != nil || resp == nil {
+		return resp, err
+	}
+	primary := resp.Copy()
+	compareTTL := !cacheStatus.Hit()
+	tid, _ := trace.GetTraceId(ctx)
+	for _, shadow := range a.shadows {
+		select {
+		case a.sem <- struct{}{}:
+		default:
+			logutil.GetLogger(ctx).Debug("mirror action busy, skip shadow", zap.String("action", a.name), zap.String("shadow", shadow.Name()))
+			continue
+		}
+		go func(shadow action.IDNSAction) {
+			var diffs []string
+			defer func() {
+				<-a.sem
+				if a.done != nil {
+					a.done(diffs)
+				}
+			}()
+			sctx, cancel := context.WithTimeout(resolver.WithCacheBypass(trace.WithTraceId(context.Background(), tid)), a.timeout)
+			defer cancel()
+			diffs = a.compare(sctx, shadow, shadowReq.Copy(), primary, compareTTL)
+		}(shadow)
+	}
+	return resp, nil
+}
+
+// compare queries the shadow action and logs how its answer differs from the
+// primary one, the differences are returned.
+func (a *mirrorAction) compare(ctx context.Context, shadow action.IDNSAction, req *dns.Msg, primary *dns.Msg, compareTTL bool) []string {
+	logger := logutil.GetLogger(ctx).With(zap.String("action", a.name), zap.String("shadow", shadow.Name()))
+	if len(req.Question) > 0 {
+		logger = logger.With(zap.String("domain", req.Question[0].Name), zap.String("qtype", dns.TypeToString[req.Question[0].Qtype]))
+	}
+	resp, err := shadow.Perform(ctx, req)
+	if err != nil || resp == nil {
+		logger.Info("mirror shadow query failed", zap.Error(err))
+		return nil
+	}
+	diffs := diffResponses(primary, resp, compareTTL, a.ttlTolerance)
+	if len(diffs) == 0 {
+		logger.Debug("mirror answers match")
+		return nil
+	}
+	logger.Info("mirror answers differ",
+		zap.Strings("diff", diffs),
+		zap.String("primary_rcode", dns.RcodeToString[primary.Rcode]),
+		zap.String("shadow_rcode", dns.RcodeToString[resp.Rcode]),
+		zap.Strings("primary_ips", answerIPs(primary)),
+		zap.Strings("shadow_ips", answerIPs(resp)),
+	)
+	return diffs
+}
+
+// diffResponses returns the names of the aspects that differ: rcode, ips
+// (the set of A/AAAA addresses) and, with compareTTL, ttl (the lowest answer
+// ttl, compared with tolerance when both sides have answers).
+func diffResponses(primary, shadow *dns.Msg, compareTTL bool, tolerance uint32) []string {
+	var diffs []string
+	if primary.Rcode != shadow.Rcode {
+		diffs = append(diffs, "rcode")
+	}
+	if !slices.Equal(answerIPs(primary), answerIPs(shadow)) {
+		diffs = append(diffs, "ips")
+	}
+	if !compareTTL {
+		return diffs
+	}
+	pttl, pok := minTTL(primary)
+	sttl, sok := minTTL(shadow)
+	if pok && sok {
+		gap := pttl - sttl
+		if sttl > pttl {
+			gap = sttl - pttl
+		}
+		if gap > tolerance {
+			diffs = append(diffs, fmt.Sprintf("ttl(%d/%d)", pttl, sttl))
+		}
+	}
+	return diffs
+}
+
+func answerIPs(msg *dns.Msg) []string {
+	var ips []string
+	for _, rr := range msg.Answer {
+		switch v := rr.(type) {
+		case *dns.A:
+			ips = append(ips, v.A.String())
+		case *dns.AAAA:
+			ips = append(ips, v.AAAA.String())
+		}
+	}
+	slices.Sort(ips)
+	return slices.Compact(ips)
+}
+
+func minTTL(msg *dns.Msg) (uint32, bool) {
+	var ttl uint32
+	found := false
+	for _, rr := range msg.Answer {
+		if !found || rr.Header().Ttl < ttl {
+			ttl = rr.Header().Ttl
+			found = true
+		}
+	}
+	return ttl, found
+}
+
+func newMirrorAction(name string, c *config, primary action.IDNSAction, shadows []action.IDNSAction) (action.IDNSAction, error) {
+	if len(shadows) == 0 {
+		return nil, fmt.Errorf("mirror action requires at least one shadow")
+	}
+	if c.Concurrency < 0 || c.Timeout < 0 {
+		return nil, fmt.Errorf("mirror action concurrency and timeout should not be negative")
+	}
+	concurrency := c.Concurrency
+	if concurrency == 0 {
+		concurrency = defaultConcurrency
+	}
+	timeout := time.Duration(c.Timeout) * time.Millisecond
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+	return &mirrorAction{
+		name:         name,
+		primary:      primary,
+		shadows:      shadows,
+		sem:          make(chan struct{}, concurrency),
+		timeout:      timeout,
+		ttlTolerance: c.TTLTolerance,
+	}, nil
+}
+
+func createMirrorAction(name string, args interface{}, lookup action.Lookup) (action.IDNSAction, error) {
+	c := &config{}
+	if err := utils.ConvStructJson(args, c); err != nil {
+		return nil, err
+	}
+	if c.Primary == "" {
+		return nil, fmt.Errorf("mirror action requires primary")
+	}
+	primary, err := lookup(c.Primary)
+	if err != nil {
+		return nil, err
+	}
+	shadows := make([]action.IDNSAction, 0, len(c.Shadows))
+	for _, item := range c.Shadows {
+		shadow, err := lookup(item)
+		if err != nil {
+			return nil, err
+		}
+		shadows = append(shadows, shadow)
+	}
+	return newMirrorAction(name, c, primary, shadows)
+}
+
+func init() {
+	action.Register("mirror", createMirrorAction)
+}
@@ -0,0 +1,201 @@
+package mirror
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/xxxsen/atlas/internal/action"
+	"github.com/xxxsen/atlas/internal/resolver"
+)
+
+type stubAction struct {
+	name  string
+	ip    string
+	ttl   uint32
+	block chan struct{}
+	calls atomic.Int32
+}
+
+func (s *stubAction) Name() string { return s.name }
+
+func (s *stubAction) Type() string { return "stub" }
+
+func (s *stubAction) Perform(ctx context.Context, req *dns.Msg) (*dns.Msg, error) {
+	s.calls.Add(1)
+	if s.block != nil {
+		<-s.block
+	}
+	return newResponse(req, s.ip, s.ttl), nil
+}
+
+func newResponse(req *dns.Msg, ip string, ttl uint32) *dns.Msg {
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	resp.Answer = append(resp.Answer, &dns.A{
+		Hdr: dns.RR_Header{Name: req.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: ttl},
+		A:   net.ParseIP(ip),
+	})
+	return resp
+}
+
+type stubResolver struct {
+	calls atomic.Int32
+}
+
+func (s *stubResolver) Name() string { return "stub-resolver" }
+
+func (s *stubResolver) Query(ctx context.Context, req *dns.Msg) (*dns.Msg, error) {
+	s.calls.Add(1)
+	return newResponse(req, "9.9.9.9", 60), nil
+}
+
+type resolverAction struct {
+	r resolver.IDNSResolver
+}
+
+func (a *resolverAction) Name() string { return "resolver" }
+
+func (a *resolverAction) Type() string { return "stub" }
+
+func (a *resolverAction) Perform(ctx context.Context, req *dns.Msg) (*dns.Msg, error) {
+	return a.r.Query(ctx, req)
+}
+
+func newRequest() *dns.Msg {
+	req := new(dns.Msg)
+	req.SetQuestion("example.com.", dns.TypeA)
+	return req
+}
+
+func TestMirrorActionPerform(t *testing.T) {
+	resolver.ConfigureCache(resolver.CacheOptions{Size: 10})
+	defer resolver.ConfigureCache(resolver.CacheOptions{})
+
+	primary := &stubAction{name: "primary", ip: "1.1.1.1", ttl: 60}
+	other := &stubAction{name: "other", ip: "8.8.8.8", ttl: 60}
+	base := &stubResolver{}
+	cached := &resolverAction{r: resolver.TryEnableResolverCache(base)}
+	act, err := newMirrorAction("mirror", &config{}, primary, []action.IDNSAction{other, cached})
+	if err != nil {
+		t.Fatalf("newMirrorAction error: %v", err)
+	}
+	var wg sync.WaitGroup
+	act.(*mirrorAction).done = func([]string) { wg.Done() }
+	for i := 0; i < 2; i++ {
+		wg.Add(2)
+		resp, err := act.Perform(context.Background(), newRequest())
+		if err != nil {
+			t.Fatalf("Perform error: %v", err)
+		}
+		if resp.Answer[0].(*dns.A).A.String() != "1.1.1.1" {
+			t.Fatalf("expected primary answer, got %v", resp.Answer)
+		}
+	}
+	wg.Wait()
+	if other.calls.Load() != 2 {
+		t.Fatalf("expected shadow called twice, got %d", other.calls.Load())
+	}
+	if base.calls.Load() != 2 {
+		t.Fatalf("shadow queries should bypass the cache, got %d upstream calls", base.calls.Load())
+	}
+}
+
+func TestMirrorActionConcurrencyLimit(t *testing.T) {
+	primary := &stubAction{name: "primary", ip: "1.1.1.1", ttl: 60}
+	shadow := &stubAction{name: "shadow", ip: "1.1.1.1", ttl: 60, block: make(chan struct{})}
+	act, err := newMirrorAction("mirror", &config{Concurrency: 1}, primary, []action.IDNSAction{shadow})
+	if err != nil {
+		t.Fatalf("newMirrorAction error: %v", err)
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	act.(*mirrorAction).done = func([]string) { wg.Done() }
+	for i := 0; i < 3; i++ {
+		if _, err := act.Perform(context.Background(), newRequest()); err != nil {
+			t.Fatalf("Perform error: %v", err)
+		}
+	}
+	close(shadow.block)
+	wg.Wait()
+	if shadow.calls.Load() != 1 {
+		t.Fatalf("expected shadow queries over the limit to be dropped, got %d calls", shadow.calls.Load())
+	}
+}
+
+func TestDiffResponses(t *testing.T) {
+	req := newRequest()
+	base := newResponse(req, "1.1.1.1", 300)
+	tests := []struct {
+		name      string
+		shadow    *dns.Msg
+		tolerance uint32
+		want      []string
+	}{
+		{"same", newResponse(req, "1.1.1.1", 300), 0, nil},
+		{"ips", newResponse(req, "8.8.8.8", 300), 0, []string{"ips"}},
+		{"ttl", newResponse(req, "1.1.1.1", 60), 0, []string{"ttl(300/60)"}},
+		{"ttl within tolerance", newResponse(req, "1.1.1.1", 250), 60, nil},
+		{"rcode", func() *dns.Msg {
+			m := new(dns.Msg)
+			m.SetRcode(req, dns.RcodeNameError)
+			return m
+		}(), 0, []string{"rcode", "ips"}},
+	}
+	for _, tt := range tests {
+		got := diffResponses(base, tt.shadow, true, tt.tolerance)
+		if len(got) != len(tt.want) {
+			t.Fatalf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Fatalf("%s: expected %v, got %v", tt.name, tt.want, got)
+			}
+		}
+	}
+}
+
+func TestMirrorActionCachedPrimary(t *testing.T) {
+	resolver.ConfigureCache(resolver.CacheOptions{Size: 10})
+	defer resolver.ConfigureCache(resolver.CacheOptions{})
+
+	primary := &resolverAction{r: resolver.TryEnableResolverCache(&stubResolver{})}
+	shadow := &stubAction{name: "shadow", ip: "9.9.9.9", ttl: 300}
+	act, err := newMirrorAction("mirror", &config{}, primary, []action.IDNSAction{shadow})
+	if err != nil {
+		t.Fatalf("newMirrorAction error: %v", err)
+	}
+	var diffs [][]string
+	var wg sync.WaitGroup
+	act.(*mirrorAction).done = func(d []string) {
+		diffs = append(diffs, d)
+		wg.Done()
+	}
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		if _, err := act.Perform(context.Background(), newRequest()); err != nil {
+			t.Fatalf("Perform error: %v", err)
+		}
+		wg.Wait()
+	}
+	// the upstream answer has ttl 60 and is compared, the second primary
+	// answer is a cache hit with a decayed ttl and is not
+	if len(diffs) != 2 || len(diffs[0]) != 1 || diffs[0][0] != "ttl(60/300)" || len(diffs[1]) != 0 {
+		t.Fatalf("expected ttl compared for the upstream answer only, got %v", diffs)
+	}
+	if diffs := diffResponses(newResponse(newRequest(), "9.9.9.9", 42), newResponse(newRequest(), "9.9.9.9", 300), false, 0); len(diffs) != 0 {
+		t.Fatalf("expected ttl ignored, got %v", diffs)
+	}
+}
+
+func TestCreateMirrorActionInvalid(t *testing.T) {
+	if _, err := createMirrorAction("mirror", map[string]interface{}{"shadows": []interface{}{"a"}}, action.MapLookup(nil)); err == nil {
+		t.Fatalf("expected error without primary")
+	}
+	if _, err := newMirrorAction("mirror", &config{}, &stubAction{}, nil); err == nil {
+		t.Fatalf("expected error without shadows")
+	}
+}
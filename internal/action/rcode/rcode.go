@@ -43,7 +43,7 @@ func newRCodeAction(name string, code int) action.IDNSAction {
 
 const maxRcode = 0x0FFF
 
-func createRcodeAction(name string, args interface{}) (action.IDNSAction, error) {
+func createRcodeAction(name string, args interface{}, _ action.Lookup) (action.IDNSAction, error) {
 	cfg := &config{}
 	if err := utils.ConvStructJson(args, cfg); err != nil {
 		return nil, err
@@ -8,7 +8,7 @@ import (
 )
 
 func TestRcodeActionPerform(t *testing.T) {
-	act, err := createRcodeAction("block", &config{Code: dns.RcodeRefused})
+	act, err := createRcodeAction("block", &config{Code: dns.RcodeRefused}, nil)
 	if err != nil {
 		t.Fatalf("createRcodeAction error: %v", err)
 	}
@@ -27,7 +27,7 @@ func TestRcodeActionPerform(t *testing.T) {
 }
 
 func TestRcodeActionInvalidCode(t *testing.T) {
-	if _, err := createRcodeAction("invalid", &config{Code: -1}); err == nil {
+	if _, err := createRcodeAction("invalid", &config{Code: -1}, nil); err == nil {
 		t.Fatalf("expected error for invalid code")
 	}
 }
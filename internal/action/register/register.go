@@ -1,6 +1,16 @@
 package register
 
 import (
+	_ "github.com/xxxsen/atlas/internal/action/answer"
+	_ "github.com/xxxsen/atlas/internal/action/block"
+	_ "github.com/xxxsen/atlas/internal/action/dns64"
+	_ "github.com/xxxsen/atlas/internal/action/fakeip"
+	_ "github.com/xxxsen/atlas/internal/action/fallback"
+	_ "github.com/xxxsen/atlas/internal/action/filter"
 	_ "github.com/xxxsen/atlas/internal/action/forward"
+	_ "github.com/xxxsen/atlas/internal/action/ipfilter"
+	_ "github.com/xxxsen/atlas/internal/action/mirror"
 	_ "github.com/xxxsen/atlas/internal/action/rcode"
+	_ "github.com/xxxsen/atlas/internal/action/rewrite"
+	_ "github.com/xxxsen/atlas/internal/action/ttl"
 )
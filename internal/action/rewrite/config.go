@@ -0,0 +1,8 @@
+package rewrite
+
+type config struct {
+	Pattern string `json:"pattern"`
+	Target  string `json:"target"`
+	Action  string `json:"action"`
+	TTL     uint32 `json:"ttl"`
+}
@@ -0,0 +1,139 @@
+package rewrite
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/xxxsen/atlas/internal/action"
+	"github.com/xxxsen/common/logutil"
+	"github.com/xxxsen/common/utils"
+	"go.uber.org/zap"
+)
+
+const defaultRewriteTTL = 60
+
+type rewriteAction struct {
+	name    string
+	pattern *regexp.Regexp
+	target  string
+	next    action.IDNSAction
+	ttl     uint32
+}
+
+func (a *rewriteAction) Name() string {
+	return a.name
+}
+
+func (a *rewriteAction) Type() string {
+	return "rewrite"
+}
+
+// rewriteTarget returns the fully qualified target for qname, false when the
+// pattern does not match or the name would stay the same.
+func (a *rewriteAction) rewriteTarget(qname string) (string, bool) {
+	name := strings.ToLower(strings.TrimSuffix(qname, "."))
+	target := a.target
+	if a.pattern != nil {
+		match := a.pattern.FindStringSubmatchIndex(name)
+		if match == nil {
+			return "", false
+		}
+		target = string(a.pattern.ExpandString(nil, a.target, name, match))
+	}
+	target = dns.Fqdn(strings.ToLower(target))
+	if strings.EqualFold(target, dns.Fqdn(qname)) {
+		return "", false
+	}
+	return target, true
+}
+
+// Perform resolves the rewritten name through the next action and answers
+// with a CNAME from the original name to the target followed by the target's
+// answers. Requests that are not rewritten are passed to the next action as is.
+func (a *rewriteAction) Perform(ctx context.Context, req *dns.Msg) (*dns.Msg, error) {
+	if len(req.Question) == 0 {
+		return a.next.Perform(ctx, req)
+	}
+	q := req.Question[0]
+	target, ok := a.rewriteTarget(q.Name)
+	if !ok {
+		return a.next.Perform(ctx, req)
+	}
+	if _, ok := dns.IsDomainName(target); !ok {
+		return nil, fmt.Errorf("rewrite %s to invalid target:%s", q.Name, target)
+	}
+	logger := logutil.GetLogger(ctx).With(zap.String("action", a.name), zap.String("target", target))
+	logger.Debug("rewrite action start")
+	sub := req.Copy()
+	sub.Question = []dns.Question{{Name: target, Qtype: q.Qtype, Qclass: q.Qclass}}
+	subResp, err := a.next.Perform(ctx, sub)
+	if err != nil {
+		return nil, fmt.Errorf("resolve rewrite target %s failed, err:%w", target, err)
+	}
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	resp.Answer = append(resp.Answer, &dns.CNAME{
+		Hdr: dns.RR_Header{
+			Name:   q.Name,
+			Rrtype: dns.TypeCNAME,
+			Class:  q.Qclass,
+			Ttl:    a.ttl,
+		},
+		Target: target,
+	})
+	if subResp != nil {
+		resp.Rcode = subResp.Rcode
+		resp.RecursionAvailable = subResp.RecursionAvailable
+		resp.Answer = append(resp.Answer, subResp.Answer...)
+		resp.Ns = subResp.Ns
+		resp.Extra = subResp.Extra
+	}
+	return resp, nil
+}
+
+func newRewriteAction(name string, c *config, next action.IDNSAction) (action.IDNSAction, error) {
+	if strings.TrimSpace(c.Target) == "" {
+		return nil, fmt.Errorf("rewrite action requires target")
+	}
+	act := &rewriteAction{
+		name:   name,
+		target: strings.TrimSpace(c.Target),
+		next:   next,
+		ttl:    c.TTL,
+	}
+	if act.ttl == 0 {
+		act.ttl = defaultRewriteTTL
+	}
+	if c.Pattern != "" {
+		re, err := regexp.Compile(c.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("compile rewrite pattern failed, pattern:%s, err:%w", c.Pattern, err)
+		}
+		act.pattern = re
+	} else if _, ok := dns.IsDomainName(act.target); !ok || strings.Contains(act.target, "$") {
+		return nil, fmt.Errorf("rewrite action invalid target:%s", act.target)
+	}
+	return act, nil
+}
+
+func createRewriteAction(name string, args interface{}, lookup action.Lookup) (action.IDNSAction, error) {
+	c := &config{}
+	if err := utils.ConvStructJson(args, c); err != nil {
+		return nil, err
+	}
+	if c.Action == "" {
+		return nil, fmt.Errorf("rewrite action requires action to resolve the target")
+	}
+	next, err := lookup(c.Action)
+	if err != nil {
+		return nil, err
+	}
+	return newRewriteAction(name, c, next)
+}
+
+func init() {
+	action.Register("rewrite", createRewriteAction)
+}
@@ -0,0 +1,118 @@
+package rewrite
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/xxxsen/atlas/internal/action"
+)
+
+type stubAction struct {
+	last *dns.Msg
+}
+
+func (s *stubAction) Name() string { return "stub" }
+
+func (s *stubAction) Type() string { return "stub" }
+
+func (s *stubAction) Perform(ctx context.Context, req *dns.Msg) (*dns.Msg, error) {
+	s.last = req
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	resp.RecursionAvailable = true
+	resp.Answer = append(resp.Answer, &dns.A{
+		Hdr: dns.RR_Header{Name: req.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 30},
+		A:   net.IPv4(216, 239, 38, 120),
+	})
+	return resp, nil
+}
+
+func TestRewriteActionFixedTarget(t *testing.T) {
+	next := &stubAction{}
+	act, err := newRewriteAction("safesearch", &config{Target: "forcesafesearch.google.com"}, next)
+	if err != nil {
+		t.Fatalf("newRewriteAction error: %v", err)
+	}
+	req := new(dns.Msg)
+	req.SetQuestion("www.google.com.", dns.TypeA)
+	resp, err := act.Perform(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Perform error: %v", err)
+	}
+	if next.last.Question[0].Name != "forcesafesearch.google.com." {
+		t.Fatalf("unexpected upstream question: %v", next.last.Question[0])
+	}
+	if resp.Id != req.Id || resp.Question[0].Name != "www.google.com." {
+		t.Fatalf("original question should be preserved: %v", resp.Question)
+	}
+	if len(resp.Answer) != 2 || !resp.RecursionAvailable {
+		t.Fatalf("expected CNAME plus target answers, got %v", resp.Answer)
+	}
+	cname := resp.Answer[0].(*dns.CNAME)
+	if cname.Hdr.Name != "www.google.com." || cname.Target != "forcesafesearch.google.com." || cname.Hdr.Ttl != defaultRewriteTTL {
+		t.Fatalf("unexpected CNAME: %v", cname)
+	}
+	if resp.Answer[1].Header().Name != "forcesafesearch.google.com." {
+		t.Fatalf("unexpected target answer: %v", resp.Answer[1])
+	}
+}
+
+func TestRewriteActionPattern(t *testing.T) {
+	next := &stubAction{}
+	act, err := newRewriteAction("migrate", &config{
+		Pattern: `^(.+)\.old\.example\.com$`,
+		Target:  "${1}.new.example.com",
+		TTL:     300,
+	}, next)
+	if err != nil {
+		t.Fatalf("newRewriteAction error: %v", err)
+	}
+	req := new(dns.Msg)
+	req.SetQuestion("API.old.example.com.", dns.TypeAAAA)
+	resp, err := act.Perform(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Perform error: %v", err)
+	}
+	q := next.last.Question[0]
+	if q.Name != "api.new.example.com." || q.Qtype != dns.TypeAAAA {
+		t.Fatalf("unexpected upstream question: %v", q)
+	}
+	if resp.Answer[0].Header().Ttl != 300 {
+		t.Fatalf("unexpected CNAME ttl: %v", resp.Answer[0])
+	}
+
+	// names not matching the pattern are resolved unchanged
+	req = new(dns.Msg)
+	req.SetQuestion("www.example.com.", dns.TypeA)
+	resp, err = act.Perform(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Perform error: %v", err)
+	}
+	if next.last.Question[0].Name != "www.example.com." || len(resp.Answer) != 1 {
+		t.Fatalf("expected passthrough, got %v", resp.Answer)
+	}
+}
+
+func TestCreateRewriteAction(t *testing.T) {
+	lookup := action.MapLookup(map[string]action.IDNSAction{"rewrite-upstream": &stubAction{}})
+	if _, err := createRewriteAction("ok", map[string]interface{}{
+		"target": "forcesafesearch.google.com",
+		"action": "rewrite-upstream",
+	}, lookup); err != nil {
+		t.Fatalf("createRewriteAction error: %v", err)
+	}
+	invalid := []map[string]interface{}{
+		{"target": "forcesafesearch.google.com", "action": "missing"},
+		{"target": "forcesafesearch.google.com"},
+		{"action": "rewrite-upstream"},
+		{"target": "${1}.example.com", "action": "rewrite-upstream"},
+		{"pattern": "(", "target": "a.example.com", "action": "rewrite-upstream"},
+	}
+	for _, args := range invalid {
+		if _, err := createRewriteAction("bad", args, lookup); err == nil {
+			t.Fatalf("expected error for %v", args)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+package ttl
+
+type config struct {
+	Action string `json:"action"`
+	Min    uint32 `json:"min"`
+	Max    uint32 `json:"max"`
+	Fixed  uint32 `json:"fixed"`
+	Jitter uint32 `json:"jitter"`
+}
@@ -0,0 +1,141 @@
+package ttl
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+
+	"github.com/miekg/dns"
+	"github.com/xxxsen/atlas/internal/action"
+	"github.com/xxxsen/atlas/internal/resolver"
+	"github.com/xxxsen/common/utils"
+)
+
+type ttlAction struct {
+	name   string
+	next   action.IDNSAction
+	min    uint32
+	max    uint32
+	fixed  uint32
+	jitter uint32
+	randn  func(n uint32) uint32
+}
+
+func (a *ttlAction) Name() string {
+	return a.name
+}
+
+func (a *ttlAction) Type() string {
+	return "ttl"
+}
+
+// Perform runs the next action with the rewrite attached to ctx, so that a
+// forward action caches the rewritten ttls. Responses that did not pass the
+// cache, such as those of answer or block actions, are rewritten here. When
+// the cache did rewrite the response, records added after it, such as the
+// CNAME of rewrite or the AAAA synthesized by dns64, are still clamped.
+func (a *ttlAction) Perform(ctx context.Context, req *dns.Msg) (*dns.Msg, error) {
+	ctx, rw := resolver.WithResponseRewrite(ctx, a.name, a.rewrite)
+	resp, err := a.next.Perform(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil {
+		return resp, nil
+	}
+	if rw.Handled() {
+		a.clamp(resp)
+		return resp, nil
+	}
+	a.rewrite(resp)
+	return resp, nil
+}
+
+func (a *ttlAction) rewrite(resp *dns.Msg) {
+	a.walk(resp, a.convert)
+}
+
+// clamp converts only the ttls out of the range convert produces, records
+// already rewritten by the cache keep their remaining ttl and jitter.
+func (a *ttlAction) clamp(resp *dns.Msg) {
+	lo, hi := a.min, a.max
+	if a.fixed > 0 {
+		lo, hi = a.fixed, a.fixed
+	}
+	if hi > 0 {
+		hi += a.jitter
+	}
+	a.walk(resp, func(ttl uint32) uint32 {
+		if ttl < lo || (hi > 0 && ttl > hi) {
+			return a.convert(ttl)
+		}
+		return ttl
+	})
+}
+
+func (a *ttlAction) walk(resp *dns.Msg, fn func(ttl uint32) uint32) {
+	for _, section := range [][]dns.RR{resp.Answer, resp.Ns, resp.Extra} {
+		for _, rr := range section {
+			if rr.Header().Rrtype == dns.TypeOPT {
+				continue
+			}
+			rr.Header().Ttl = fn(rr.Header().Ttl)
+		}
+	}
+}
+
+func (a *ttlAction) convert(ttl uint32) uint32 {
+	if a.fixed > 0 {
+		ttl = a.fixed
+	}
+	if ttl < a.min {
+		ttl = a.min
+	}
+	if a.max > 0 && ttl > a.max {
+		ttl = a.max
+	}
+	if a.jitter > 0 {
+		ttl += a.randn(a.jitter + 1)
+	}
+	return ttl
+}
+
+func newTTLAction(name string, c *config, next action.IDNSAction) (action.IDNSAction, error) {
+	if c.Min == 0 && c.Max == 0 && c.Fixed == 0 && c.Jitter == 0 {
+		return nil, fmt.Errorf("ttl action requires one of min, max, fixed or jitter")
+	}
+	if c.Max > 0 && c.Min > c.Max {
+		return nil, fmt.Errorf("ttl action min:%d should not be greater than max:%d", c.Min, c.Max)
+	}
+	if c.Fixed > 0 && (c.Min > 0 || c.Max > 0) {
+		return nil, fmt.Errorf("ttl action fixed can not be used with min or max")
+	}
+	return &ttlAction{
+		name:   name,
+		next:   next,
+		min:    c.Min,
+		max:    c.Max,
+		fixed:  c.Fixed,
+		jitter: c.Jitter,
+		randn:  rand.Uint32N,
+	}, nil
+}
+
+func createTTLAction(name string, args interface{}, lookup action.Lookup) (action.IDNSAction, error) {
+	c := &config{}
+	if err := utils.ConvStructJson(args, c); err != nil {
+		return nil, err
+	}
+	if c.Action == "" {
+		return nil, fmt.Errorf("ttl action requires action to wrap")
+	}
+	next, err := lookup(c.Action)
+	if err != nil {
+		return nil, err
+	}
+	return newTTLAction(name, c, next)
+}
+
+func init() {
+	action.Register("ttl", createTTLAction)
+}
@@ -0,0 +1,245 @@
+package ttl
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/xxxsen/atlas/internal/action"
+	_ "github.com/xxxsen/atlas/internal/action/dns64"
+	_ "github.com/xxxsen/atlas/internal/action/rewrite"
+	"github.com/xxxsen/atlas/internal/resolver"
+)
+
+type stubAction struct {
+	r     resolver.IDNSResolver
+	count int
+}
+
+func (s *stubAction) Name() string { return "stub" }
+
+func (s *stubAction) Type() string { return "stub" }
+
+func (s *stubAction) Perform(ctx context.Context, req *dns.Msg) (*dns.Msg, error) {
+	if s.r != nil {
+		return s.r.Query(ctx, req)
+	}
+	s.count++
+	return newResponse(req), nil
+}
+
+type stubResolver struct {
+	count int
+}
+
+func (s *stubResolver) Name() string { return "stub-resolver" }
+
+func (s *stubResolver) Query(ctx context.Context, req *dns.Msg) (*dns.Msg, error) {
+	s.count++
+	return newResponse(req), nil
+}
+
+func newResponse(req *dns.Msg) *dns.Msg {
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	name := req.Question[0].Name
+	resp.Answer = append(resp.Answer, &dns.A{
+		Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 1},
+		A:   net.IPv4(1, 1, 1, 1),
+	})
+	resp.Ns = append(resp.Ns, &dns.NS{
+		Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeNS, Class: dns.ClassINET, Ttl: 86400},
+		Ns:  "ns1.example.com.",
+	})
+	resp.Extra = append(resp.Extra, &dns.A{
+		Hdr: dns.RR_Header{Name: "ns1.example.com.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 600},
+		A:   net.IPv4(2, 2, 2, 2),
+	})
+	resp.SetEdns0(1232, false)
+	return resp
+}
+
+func performTTL(t *testing.T, c *config, next *stubAction) *dns.Msg {
+	t.Helper()
+	act, err := newTTLAction("ttl", c, next)
+	if err != nil {
+		t.Fatalf("newTTLAction error: %v", err)
+	}
+	act.(*ttlAction).randn = func(n uint32) uint32 { return n - 1 }
+	req := new(dns.Msg)
+	req.SetQuestion("cdn.example.com.", dns.TypeA)
+	resp, err := act.Perform(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Perform error: %v", err)
+	}
+	return resp
+}
+
+func ttls(resp *dns.Msg) []uint32 {
+	var rs []uint32
+	for _, section := range [][]dns.RR{resp.Answer, resp.Ns, resp.Extra} {
+		for _, rr := range section {
+			rs = append(rs, rr.Header().Ttl)
+		}
+	}
+	return rs
+}
+
+func TestTTLActionRewrite(t *testing.T) {
+	tests := []struct {
+		name string
+		c    *config
+		want []uint32
+	}{
+		{"clamp", &config{Min: 60, Max: 3600}, []uint32{60, 3600, 600}},
+		{"min", &config{Min: 300}, []uint32{300, 86400, 600}},
+		{"fixed", &config{Fixed: 30}, []uint32{30, 30, 30}},
+		{"jitter", &config{Min: 60, Max: 300, Jitter: 10}, []uint32{70, 310, 310}},
+	}
+	for _, tt := range tests {
+		resp := performTTL(t, tt.c, &stubAction{})
+		got := ttls(resp)
+		// the trailing OPT record keeps its ttl, it holds the extended rcode and flags
+		want := append(tt.want, 0)
+		if len(got) != len(want) {
+			t.Fatalf("%s: unexpected records %v", tt.name, got)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("%s: expected ttls %v, got %v", tt.name, want, got)
+			}
+		}
+	}
+}
+
+func TestTTLActionCacheSeesRewrite(t *testing.T) {
+	resolver.ConfigureCache(resolver.CacheOptions{Size: 10})
+	defer resolver.ConfigureCache(resolver.CacheOptions{})
+
+	base := &stubResolver{}
+	next := &stubAction{r: resolver.TryEnableResolverCache(base)}
+	c := &config{Min: 60, Jitter: 5}
+	resp := performTTL(t, c, next)
+	if resp.Answer[0].Header().Ttl != 65 {
+		t.Fatalf("expected rewritten ttl, got %v", resp.Answer[0])
+	}
+	// the cached entry was stored with the rewritten ttl and is not rewritten twice
+	resp = performTTL(t, c, next)
+	if base.count != 1 {
+		t.Fatalf("expected cached response, base count=%d", base.count)
+	}
+	if ttl := resp.Answer[0].Header().Ttl; ttl < 64 || ttl > 65 {
+		t.Fatalf("unexpected cached ttl %d", ttl)
+	}
+}
+
+func TestTTLActionCacheHitFromPlainQuery(t *testing.T) {
+	resolver.ConfigureCache(resolver.CacheOptions{Size: 10})
+	defer resolver.ConfigureCache(resolver.CacheOptions{})
+
+	base := &stubResolver{}
+	next := &stubAction{r: resolver.TryEnableResolverCache(base)}
+	req := new(dns.Msg)
+	req.SetQuestion("cdn.example.com.", dns.TypeA)
+	if _, err := next.Perform(context.Background(), req); err != nil {
+		t.Fatalf("plain Perform error: %v", err)
+	}
+	// the entry cached without the wrapper is not served unclamped
+	resp := performTTL(t, &config{Min: 60}, next)
+	if ttl := resp.Answer[0].Header().Ttl; ttl != 60 {
+		t.Fatalf("expected clamped ttl, got %d", ttl)
+	}
+	resp = performTTL(t, &config{Min: 60}, next)
+	if ttl := resp.Answer[0].Header().Ttl; base.count != 2 || ttl < 59 {
+		t.Fatalf("expected clamped cached response, base count=%d ttl=%d", base.count, ttl)
+	}
+	// and the clamped entry does not leak to the plain query
+	resp, err := next.Perform(context.Background(), req)
+	if err != nil {
+		t.Fatalf("plain Perform error: %v", err)
+	}
+	if ttl := resp.Answer[0].Header().Ttl; base.count != 2 || ttl > 1 {
+		t.Fatalf("expected plain cached response, base count=%d ttl=%d", base.count, ttl)
+	}
+}
+
+type dns64Resolver struct{}
+
+func (dns64Resolver) Name() string { return "dns64-resolver" }
+
+// Query answers A queries with an address and anything else with an empty
+// reply whose SOA allows negative caching for 5 seconds.
+func (dns64Resolver) Query(ctx context.Context, req *dns.Msg) (*dns.Msg, error) {
+	if req.Question[0].Qtype == dns.TypeA {
+		return newResponse(req), nil
+	}
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	resp.Ns = append(resp.Ns, &dns.SOA{
+		Hdr:    dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 5},
+		Ns:     "ns1.example.com.",
+		Mbox:   "admin.example.com.",
+		Minttl: 5,
+	})
+	return resp, nil
+}
+
+func TestTTLActionClampsRecordsAddedAfterCache(t *testing.T) {
+	resolver.ConfigureCache(resolver.CacheOptions{Size: 10})
+	defer resolver.ConfigureCache(resolver.CacheOptions{})
+
+	tests := []struct {
+		name  string
+		typ   string
+		args  map[string]interface{}
+		r     resolver.IDNSResolver
+		qtype uint16
+	}{
+		{"rewrite", "rewrite", map[string]interface{}{"target": "target.example.com.", "action": "stub"}, &stubResolver{}, dns.TypeA},
+		{"dns64", "dns64", map[string]interface{}{"action": "stub"}, dns64Resolver{}, dns.TypeAAAA},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := &stubAction{r: resolver.TryEnableResolverCache(tt.r)}
+			inner, err := action.MakeAction(tt.typ, tt.name, tt.args, action.MapLookup(map[string]action.IDNSAction{"stub": stub}))
+			if err != nil {
+				t.Fatalf("MakeAction error: %v", err)
+			}
+			act, err := newTTLAction("ttl-"+tt.name, &config{Min: 300}, inner)
+			if err != nil {
+				t.Fatalf("newTTLAction error: %v", err)
+			}
+			req := new(dns.Msg)
+			req.SetQuestion("cdn.example.com.", tt.qtype)
+			resp, err := act.Perform(context.Background(), req)
+			if err != nil {
+				t.Fatalf("Perform error: %v", err)
+			}
+			if len(resp.Answer) == 0 {
+				t.Fatalf("expected answers, got %v", resp)
+			}
+			for _, rr := range resp.Answer {
+				if rr.Header().Ttl != 300 {
+					t.Fatalf("expected clamped ttl, got %v", rr)
+				}
+			}
+		})
+	}
+}
+
+func TestTTLActionInvalidConfig(t *testing.T) {
+	invalid := []*config{
+		{},
+		{Min: 600, Max: 60},
+		{Fixed: 60, Max: 300},
+	}
+	for _, c := range invalid {
+		if _, err := newTTLAction("ttl", c, &stubAction{}); err == nil {
+			t.Fatalf("expected error for %+v", c)
+		}
+	}
+	if _, err := createTTLAction("ttl", map[string]interface{}{"action": "missing", "min": 60}, action.MapLookup(nil)); err == nil {
+		t.Fatalf("expected error for unknown action")
+	}
+}
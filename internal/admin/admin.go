@@ -0,0 +1,18 @@
+package admin
+
+import (
+	"net/http"
+)
+
+var mux = http.NewServeMux()
+
+// Handle registers a handler on the admin http server, handlers are only
+// registered for configured components so the server exposes nothing else.
+func Handle(pattern string, h http.Handler) {
+	mux.Handle(pattern, h)
+}
+
+// Handler returns the admin http handler.
+func Handler() http.Handler {
+	return mux
+}
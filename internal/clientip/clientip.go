@@ -0,0 +1,40 @@
+package clientip
+
+import (
+	"context"
+	"net"
+	"net/netip"
+)
+
+type clientIPKey struct{}
+
+// With stores the address of the querying client in ctx.
+func With(ctx context.Context, addr netip.Addr) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, addr.Unmap())
+}
+
+// From returns the client address stored by With.
+func From(ctx context.Context) (netip.Addr, bool) {
+	addr, ok := ctx.Value(clientIPKey{}).(netip.Addr)
+	return addr, ok && addr.IsValid()
+}
+
+// FromNetAddr extracts the ip part of a net.Addr such as the remote address of a dns.ResponseWriter.
+func FromNetAddr(addr net.Addr) (netip.Addr, bool) {
+	if addr == nil {
+		return netip.Addr{}, false
+	}
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		ip, ok := netip.AddrFromSlice(a.IP)
+		return ip.Unmap(), ok
+	case *net.TCPAddr:
+		ip, ok := netip.AddrFromSlice(a.IP)
+		return ip.Unmap(), ok
+	}
+	ap, err := netip.ParseAddrPort(addr.String())
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return ap.Addr().Unmap(), true
+}
@@ -0,0 +1,70 @@
+package clientip
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+)
+
+func TestFromNetAddr(t *testing.T) {
+	tests := []struct {
+		name string
+		addr net.Addr
+		want string
+		ok   bool
+	}{
+		{"udp v4", &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 53}, "192.0.2.1", true},
+		{"udp v4 mapped", &net.UDPAddr{IP: net.ParseIP("::ffff:192.0.2.1").To16(), Port: 53}, "192.0.2.1", true},
+		{"udp v6", &net.UDPAddr{IP: net.ParseIP("2001:db8::1"), Port: 53}, "2001:db8::1", true},
+		{"tcp v4", &net.TCPAddr{IP: net.ParseIP("198.51.100.7"), Port: 853}, "198.51.100.7", true},
+		{"udp without ip", &net.UDPAddr{Port: 53}, "", false},
+		{"other addr", &net.IPAddr{IP: net.ParseIP("203.0.113.5")}, "", false},
+		{"nil", nil, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := FromNetAddr(tt.addr)
+			if ok != tt.ok {
+				t.Fatalf("FromNetAddr ok = %t, want %t", ok, tt.ok)
+			}
+			if ok && got.String() != tt.want {
+				t.Fatalf("FromNetAddr = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromNetAddrString(t *testing.T) {
+	addr, err := net.ResolveUnixAddr("unix", "/tmp/atlas.sock")
+	if err != nil {
+		t.Fatalf("resolve unix addr: %v", err)
+	}
+	if _, ok := FromNetAddr(addr); ok {
+		t.Fatalf("expected no address for a unix socket")
+	}
+	got, ok := FromNetAddr(stringAddr("[::ffff:10.0.0.1]:5353"))
+	if !ok || got.String() != "10.0.0.1" {
+		t.Fatalf("unexpected address %s, ok:%t", got, ok)
+	}
+}
+
+type stringAddr string
+
+func (s stringAddr) Network() string { return "test" }
+
+func (s stringAddr) String() string { return string(s) }
+
+func TestWithFrom(t *testing.T) {
+	if _, ok := From(context.Background()); ok {
+		t.Fatalf("expected no address in an empty context")
+	}
+	ctx := With(context.Background(), netip.MustParseAddr("::ffff:192.0.2.1"))
+	got, ok := From(ctx)
+	if !ok || got.String() != "192.0.2.1" {
+		t.Fatalf("unexpected address %s, ok:%t", got, ok)
+	}
+	if _, ok := From(With(context.Background(), netip.Addr{})); ok {
+		t.Fatalf("expected an invalid address to be ignored")
+	}
+}
@@ -17,6 +17,9 @@ type Config struct {
 	Log      logger.LogConfig `json:"log" yaml:"log"`
 	Cache    CacheConfig      `json:"cache" yaml:"cache"`
 	Pprof    PprofConfig      `json:"pprof" yaml:"pprof"`
+	Watch    WatchConfig      `json:"watch" yaml:"watch"`
+	PSL      PSLConfig        `json:"psl" yaml:"psl"`
+	Admin    AdminConfig      `json:"admin" yaml:"admin"`
 }
 
 type CacheConfig struct {
@@ -44,6 +47,23 @@ type PprofConfig struct {
 	Bind   string `json:"bind" yaml:"bind"`
 }
 
+// AdminConfig enables the admin http server, used for lookups such as fakeip reverse mapping.
+type AdminConfig struct {
+	Enable bool   `json:"enable" yaml:"enable"`
+	Bind   string `json:"bind" yaml:"bind"`
+}
+
+// WatchConfig controls automatic reloading of domain, host and geosite files.
+type WatchConfig struct {
+	Enable   bool  `json:"enable" yaml:"enable"`
+	Debounce int64 `json:"debounce" yaml:"debounce"`
+}
+
+// PSLConfig points to a local copy of the Public Suffix List used by etld1 rules.
+type PSLConfig struct {
+	File string `json:"file" yaml:"file"`
+}
+
 type Rule struct {
 	Remark string `json:"remark" yaml:"remark"`
 	Match  string `json:"match" yaml:"match"`
@@ -101,9 +101,7 @@ func parseGeoSiteFiltered(data []byte, filter map[string]struct{}) (string, []Do
 
 func parseGeoDomain(data []byte) (Domain, error) {
 	offset := 0
-	result := Domain{
-		Attributes: make(map[string]Attribute),
-	}
+	result := Domain{}
 	for offset < len(data) {
 		fieldNum, wireType, err := readTag(data, &offset)
 		if err != nil {
@@ -145,6 +143,9 @@ func parseGeoDomain(data []byte) (Domain, error) {
 				return Domain{}, err
 			}
 			if key != "" {
+				if result.Attributes == nil { //most entries carry no attribute, allocate lazily
+					result.Attributes = make(map[string]Attribute, 1)
+				}
 				result.Attributes[key] = attr
 			}
 		default:
@@ -19,10 +19,18 @@ func NewProvider() *Provider {
 }
 
 // Load parses the provided geosite file and returns the decoded dataset.
+// A directory is read as a domain-list-community source tree.
 func (p *Provider) Load(path string) (*Data, error) {
 	if p == nil {
 		return nil, fmt.Errorf("geosite provider is nil")
 	}
+	if isDir(path) {
+		entries, err := newSourceLoader(path).loadAll()
+		if err != nil {
+			return nil, err
+		}
+		return newData(entries), nil
+	}
 	data, err := readFile(path)
 	if err != nil {
 		return nil, err
@@ -34,7 +42,8 @@ func (p *Provider) Load(path string) (*Data, error) {
 	return newData(entries), nil
 }
 
-// LoadCategories loads only the specified categories from the geosite file.
+// LoadCategories loads only the specified categories from the geosite file,
+// or from a domain-list-community source tree when path is a directory.
 func (p *Provider) LoadCategories(path string, categories []string) (map[string][]Domain, error) {
 	if p == nil {
 		return nil, fmt.Errorf("geosite provider is nil")
@@ -43,6 +52,9 @@ func (p *Provider) LoadCategories(path string, categories []string) (map[string]
 	if len(filter) == 0 {
 		return map[string][]Domain{}, nil
 	}
+	if isDir(path) {
+		return newSourceLoader(path).loadCategories(filter)
+	}
 	data, err := readFile(path)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,268 @@
+package geosite
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sourceDataDir is the folder holding category files in a domain-list-community checkout.
+const sourceDataDir = "data"
+
+// SourceDir returns the folder containing the category files, path may point to
+// the repository root or to its data folder.
+func SourceDir(path string) string {
+	data := filepath.Join(path, sourceDataDir)
+	if st, err := os.Stat(data); err == nil && st.IsDir() {
+		return data
+	}
+	return path
+}
+
+func isDir(path string) bool {
+	st, err := os.Stat(path)
+	return err == nil && st.IsDir()
+}
+
+// SourceFiles lists the category files of a source tree.
+func SourceFiles(path string) ([]string, error) {
+	dir := SourceDir(filepath.Clean(path))
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read geosite source dir %s: %w", dir, err)
+	}
+	files := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		files = append(files, filepath.Join(dir, entry.Name()))
+	}
+	return files, nil
+}
+
+type sourceInclude struct {
+	name  string
+	attrs []sourceAttrFilter
+	pos   string
+}
+
+type sourceAttrFilter struct {
+	name   string
+	negate bool
+}
+
+type sourceList struct {
+	domains  []Domain
+	includes []sourceInclude
+}
+
+// sourceLoader reads the v2fly domain-list-community format, where every file
+// under data/ is a category named after the file and holds lines such as
+//
+//	domain:example.com @cn
+//	full:www.example.com
+//	include:other-list @ads @-cn
+//
+// Includes are resolved recursively and flattened into the including category.
+type sourceLoader struct {
+	dir      string
+	lists    map[string]*sourceList
+	resolved map[string][]Domain
+	visiting map[string]bool
+}
+
+func newSourceLoader(path string) *sourceLoader {
+	return &sourceLoader{
+		dir:      SourceDir(filepath.Clean(path)),
+		lists:    make(map[string]*sourceList),
+		resolved: make(map[string][]Domain),
+		visiting: make(map[string]bool),
+	}
+}
+
+func (l *sourceLoader) loadCategories(filter map[string]struct{}) (map[string][]Domain, error) {
+	rs := make(map[string][]Domain, len(filter))
+	for name := range filter {
+		if err := checkSourceName(name); err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(filepath.Join(l.dir, name)); os.IsNotExist(err) {
+			continue //keep the same behaviour as geosite.dat, missing categories are left to the caller
+		}
+		domains, err := l.resolve(name, nil)
+		if err != nil {
+			return nil, err
+		}
+		rs[name] = domains
+	}
+	return rs, nil
+}
+
+func (l *sourceLoader) loadAll() (map[string][]Domain, error) {
+	files, err := SourceFiles(l.dir)
+	if err != nil {
+		return nil, err
+	}
+	rs := make(map[string][]Domain, len(files))
+	for _, file := range files {
+		name := strings.ToLower(filepath.Base(file))
+		domains, err := l.resolve(name, nil)
+		if err != nil {
+			return nil, err
+		}
+		rs[name] = domains
+	}
+	return rs, nil
+}
+
+func (l *sourceLoader) resolve(name string, chain []string) ([]Domain, error) {
+	if domains, ok := l.resolved[name]; ok {
+		return domains, nil
+	}
+	chain = append(chain, name)
+	if l.visiting[name] {
+		return nil, fmt.Errorf("geosite include cycle: %s", strings.Join(chain, " -> "))
+	}
+	l.visiting[name] = true
+	defer delete(l.visiting, name)
+
+	list, err := l.read(name)
+	if err != nil {
+		return nil, err
+	}
+	domains := append([]Domain(nil), list.domains...)
+	for _, inc := range list.includes {
+		included, err := l.resolve(inc.name, chain)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", inc.pos, err)
+		}
+		for _, d := range included {
+			if matchAttrFilters(d.Attributes, inc.attrs) {
+				domains = append(domains, d)
+			}
+		}
+	}
+	l.resolved[name] = domains
+	return domains, nil
+}
+
+// checkSourceName rejects category names that would resolve outside the source folder.
+func checkSourceName(name string) error {
+	if strings.ContainsAny(name, `/\`) || strings.Contains(name, "..") {
+		return fmt.Errorf("invalid geosite category name:%s", name)
+	}
+	return nil
+}
+
+func matchAttrFilters(attrs map[string]Attribute, filters []sourceAttrFilter) bool {
+	for _, f := range filters {
+		_, has := attrs[f.name]
+		if has == f.negate {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *sourceLoader) read(name string) (*sourceList, error) {
+	if list, ok := l.lists[name]; ok {
+		return list, nil
+	}
+	path := filepath.Join(l.dir, name)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open geosite source %s: %w", path, err)
+	}
+	defer f.Close()
+	list := &sourceList{}
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		if idx := strings.IndexByte(line, '#'); idx >= 0 {
+			line = line[:idx]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		pos := fmt.Sprintf("%s:%d", path, lineNum)
+		if err := parseSourceLine(line, pos, list); err != nil {
+			return nil, fmt.Errorf("parse geosite source %s: %w", pos, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read geosite source %s: %w", path, err)
+	}
+	l.lists[name] = list
+	return list, nil
+}
+
+func parseSourceLine(line string, pos string, list *sourceList) error {
+	fields := strings.Fields(line)
+	rule := fields[0]
+	kind := "domain"
+	value := rule
+	if idx := strings.IndexByte(rule, ':'); idx >= 0 {
+		kind = strings.ToLower(rule[:idx])
+		value = rule[idx+1:]
+	}
+	if value == "" {
+		return fmt.Errorf("empty value in rule:%s", rule)
+	}
+	var attrs []sourceAttrFilter
+	for _, field := range fields[1:] {
+		if !strings.HasPrefix(field, "@") || len(field) == 1 {
+			return fmt.Errorf("unsupported token:%s", field)
+		}
+		attr := sourceAttrFilter{name: strings.ToLower(field[1:])}
+		if strings.HasPrefix(attr.name, "-") || strings.HasPrefix(attr.name, "!") {
+			attr.name = attr.name[1:]
+			attr.negate = true
+		}
+		if attr.name == "" {
+			return fmt.Errorf("empty attribute in rule:%s", line)
+		}
+		attrs = append(attrs, attr)
+	}
+	if kind == "include" {
+		if err := checkSourceName(value); err != nil {
+			return err
+		}
+		list.includes = append(list.includes, sourceInclude{name: strings.ToLower(value), attrs: attrs, pos: pos})
+		return nil
+	}
+	var typ DomainType
+	switch kind {
+	case "domain":
+		typ = DomainTypeDomain
+	case "full":
+		typ = DomainTypeFull
+	case "keyword":
+		typ = DomainTypePlain
+	case "regexp":
+		typ = DomainTypeRegex
+	default:
+		return fmt.Errorf("unknown rule type:%s", kind)
+	}
+	d := Domain{Type: typ, Value: value}
+	if typ != DomainTypeRegex {
+		d.Value = strings.ToLower(value)
+	}
+	for _, attr := range attrs {
+		if attr.negate {
+			return fmt.Errorf("negated attribute is only allowed on include:%s", line)
+		}
+		if d.Attributes == nil {
+			d.Attributes = make(map[string]Attribute, len(attrs))
+		}
+		val := true
+		d.Attributes[attr.name] = Attribute{BoolValue: &val}
+	}
+	list.domains = append(list.domains, d)
+	return nil
+}
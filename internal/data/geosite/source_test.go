@@ -0,0 +1,148 @@
+package geosite
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func writeSourceTree(t *testing.T, files map[string]string) string {
+	root := t.TempDir()
+	dir := filepath.Join(root, "data")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	return root
+}
+
+func domainStrings(domains []Domain) []string {
+	rs := make([]string, 0, len(domains))
+	for _, d := range domains {
+		item := []string{strconv.Itoa(int(d.Type)), d.Value}
+		attrs := make([]string, 0, len(d.Attributes))
+		for name := range d.Attributes {
+			attrs = append(attrs, "@"+name)
+		}
+		sort.Strings(attrs)
+		rs = append(rs, strings.Join(append(item, attrs...), " "))
+	}
+	sort.Strings(rs)
+	return rs
+}
+
+func TestLoadSourceCategories(t *testing.T) {
+	root := writeSourceTree(t, map[string]string{
+		"example": `# comment line
+domain:example.com @cn
+Example.org # trailing comment
+full:www.example.net
+keyword:exam
+regexp:^ex[0-9]+\.com$ @ads
+include:ads
+include:mixed @cn
+include:mixed @-cn
+`,
+		"ads":   "domain:ads.example @ads\n",
+		"mixed": "domain:cn.mixed @cn\ndomain:global.mixed\ninclude:leaf\n",
+		"leaf":  "full:leaf.example @cn @ads\n",
+	})
+	categories, err := NewProvider().LoadCategories(root, []string{"Example", "missing"})
+	if err != nil {
+		t.Fatalf("LoadCategories error: %v", err)
+	}
+	if _, ok := categories["missing"]; ok {
+		t.Fatalf("missing category should not be returned")
+	}
+	want := []string{
+		"0 exam",
+		"1 ^ex[0-9]+\\.com$ @ads",
+		"2 ads.example @ads",
+		"2 cn.mixed @cn",
+		"2 example.com @cn",
+		"2 example.org",
+		"2 global.mixed",
+		"3 leaf.example @ads @cn",
+		"3 www.example.net",
+	}
+	got := domainStrings(categories["example"])
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected domains:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestLoadSourceDataDir(t *testing.T) {
+	root := writeSourceTree(t, map[string]string{"one": "one.example\n", "two": "include:one\n"})
+	data, err := NewProvider().Load(filepath.Join(root, "data"))
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	domains, ok := data.Domains("two")
+	if !ok || len(domains) != 1 || domains[0].Value != "one.example" {
+		t.Fatalf("unexpected domains of two: %+v", domains)
+	}
+	files, err := SourceFiles(root)
+	if err != nil || len(files) != 2 {
+		t.Fatalf("expected 2 source files, got %v, %v", files, err)
+	}
+}
+
+func TestLoadSourceErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		msg   string
+	}{
+		{"cycle", map[string]string{"a": "include:b\n", "b": "include:c\n", "c": "include:a\n"}, "a -> b -> c -> a"},
+		{"self", map[string]string{"a": "include:a\n"}, "a -> a"},
+		{"missing include", map[string]string{"a": "include:nope\n"}, "nope"},
+		{"unknown type", map[string]string{"a": "suffix:example.com\n"}, "a:1"},
+		{"bad token", map[string]string{"a": "\n\nexample.com cn\n"}, "a:3"},
+		{"negated attr", map[string]string{"a": "example.com @-cn\n"}, "only allowed on include"},
+		{"include parent", map[string]string{"a": "include:../../etc/passwd\n"}, "invalid geosite category name"},
+		{"include separator", map[string]string{"a": "include:sub/b\n"}, "a:1"},
+	}
+	for _, tt := range tests {
+		root := writeSourceTree(t, tt.files)
+		_, err := NewProvider().LoadCategories(root, []string{"a"})
+		if err == nil {
+			t.Fatalf("%s: expected error", tt.name)
+		}
+		if !strings.Contains(err.Error(), tt.msg) {
+			t.Fatalf("%s: expected error containing %q, got %v", tt.name, tt.msg, err)
+		}
+	}
+}
+
+func TestLoadSourceInvalidCategoryName(t *testing.T) {
+	root := writeSourceTree(t, map[string]string{"a": "domain:example.com\n"})
+	if err := os.WriteFile(filepath.Join(root, "outside"), []byte("domain:outside.com\n"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if _, err := NewProvider().LoadCategories(root, []string{"../outside"}); err == nil {
+		t.Fatalf("expected error for a category outside the source folder")
+	}
+}
+
+func TestLoadSourceDiamond(t *testing.T) {
+	root := writeSourceTree(t, map[string]string{
+		"top":   "include:left\ninclude:right\n",
+		"left":  "include:base\n",
+		"right": "include:base\n",
+		"base":  "base.example\n",
+	})
+	categories, err := NewProvider().LoadCategories(root, []string{"top"})
+	if err != nil {
+		t.Fatalf("diamond include should not be reported as a cycle: %v", err)
+	}
+	if len(categories["top"]) != 2 {
+		t.Fatalf("expected base domain from both branches, got %+v", categories["top"])
+	}
+}
@@ -17,6 +17,7 @@ type Attribute struct {
 }
 
 // Domain is a single entry inside a geosite category.
+// Attributes is nil when the entry carries no attribute.
 type Domain struct {
 	Type       DomainType
 	Value      string
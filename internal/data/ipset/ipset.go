@@ -0,0 +1,158 @@
+package ipset
+
+import (
+	"bufio"
+	"fmt"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+)
+
+type ipRange struct {
+	from netip.Addr
+	to   netip.Addr
+}
+
+// Set is an immutable set of IPv4/IPv6 prefixes, stored as sorted and merged
+// address ranges so lookups are a binary search.
+type Set struct {
+	ranges []ipRange
+}
+
+// New builds a set from prefixes, IPv4-mapped IPv6 prefixes are treated as IPv4.
+func New(prefixes []netip.Prefix) *Set {
+	ranges := make([]ipRange, 0, len(prefixes))
+	for _, p := range prefixes {
+		p = normalizePrefix(p)
+		ranges = append(ranges, ipRange{from: p.Addr(), to: LastAddr(p)})
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].from.Less(ranges[j].from)
+	})
+	merged := make([]ipRange, 0, len(ranges))
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && merged[n-1].from.Is4() == r.from.Is4() {
+			last := &merged[n-1]
+			next := last.to.Next()
+			if r.from.Compare(last.to) <= 0 || (next.IsValid() && r.from == next) {
+				if last.to.Less(r.to) {
+					last.to = r.to
+				}
+				continue
+			}
+		}
+		merged = append(merged, r)
+	}
+	return &Set{ranges: merged}
+}
+
+// Parse builds a set from CIDR strings, plain addresses are treated as single host prefixes.
+func Parse(items []string) (*Set, error) {
+	prefixes := make([]netip.Prefix, 0, len(items))
+	for _, item := range items {
+		p, err := ParsePrefix(item)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, p)
+	}
+	return New(prefixes), nil
+}
+
+// ParsePrefix parses a CIDR or a single address.
+func ParsePrefix(item string) (netip.Prefix, error) {
+	item = strings.TrimSpace(item)
+	if strings.Contains(item, "/") {
+		p, err := netip.ParsePrefix(item)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid cidr:%s, err:%w", item, err)
+		}
+		return p.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(item)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid ip:%s, err:%w", item, err)
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// LoadFiles reads CIDRs from files, one per line, empty lines and lines starting with # are skipped.
+func LoadFiles(files []string) ([]string, error) {
+	var rs []string
+	for _, path := range files {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("open cidr file %s: %w", path, err)
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			rs = append(rs, line)
+		}
+		if err := scanner.Err(); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("read cidr file %s: %w", path, err)
+		}
+		if err := f.Close(); err != nil {
+			return nil, fmt.Errorf("close cidr file %s: %w", path, err)
+		}
+	}
+	return rs, nil
+}
+
+// Contains reports whether addr falls into any prefix of the set.
+func (s *Set) Contains(addr netip.Addr) bool {
+	if s == nil || len(s.ranges) == 0 || !addr.IsValid() {
+		return false
+	}
+	addr = addr.Unmap()
+	i := sort.Search(len(s.ranges), func(i int) bool {
+		return addr.Less(s.ranges[i].from)
+	})
+	if i == 0 {
+		return false
+	}
+	r := s.ranges[i-1]
+	return r.from.Is4() == addr.Is4() && addr.Compare(r.to) <= 0
+}
+
+// Len returns the number of merged ranges.
+func (s *Set) Len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.ranges)
+}
+
+func normalizePrefix(p netip.Prefix) netip.Prefix {
+	addr := p.Addr()
+	if addr.Is4In6() && p.Bits() >= 96 {
+		return netip.PrefixFrom(addr.Unmap(), p.Bits()-96).Masked()
+	}
+	return p.Masked()
+}
+
+// LastAddr returns the highest address of p.
+func LastAddr(p netip.Prefix) netip.Addr {
+	b := p.Addr().AsSlice()
+	bits := p.Bits()
+	for i := range b {
+		hostBits := len(b)*8 - bits - (len(b)-1-i)*8
+		switch {
+		case hostBits >= 8:
+			b[i] = 0xff
+		case hostBits > 0:
+			b[i] |= byte(1<<hostBits) - 1
+		}
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
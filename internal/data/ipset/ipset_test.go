@@ -0,0 +1,46 @@
+package ipset
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestSetContains(t *testing.T) {
+	s, err := Parse([]string{"10.0.0.0/8", "192.168.1.0/24", "192.168.2.0/24", "1.1.1.1", "fd00::/8", "::ffff:172.16.0.0/108"})
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if s.Len() != 5 {
+		t.Fatalf("expected adjacent ranges to be merged, got %d ranges", s.Len())
+	}
+	tests := []struct {
+		ip       string
+		contains bool
+	}{
+		{"10.1.2.3", true},
+		{"11.0.0.1", false},
+		{"192.168.1.255", true},
+		{"192.168.2.0", true},
+		{"192.168.3.0", false},
+		{"1.1.1.1", true},
+		{"1.1.1.2", false},
+		{"172.16.5.5", true},
+		{"::ffff:10.0.0.1", true},
+		{"fd12::1", true},
+		{"fe80::1", false},
+	}
+	for _, tt := range tests {
+		if got := s.Contains(netip.MustParseAddr(tt.ip)); got != tt.contains {
+			t.Errorf("Contains(%s) = %t, want %t", tt.ip, got, tt.contains)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	if _, err := Parse([]string{"10.0.0.0/33"}); err == nil {
+		t.Fatalf("expected error for invalid cidr")
+	}
+	if _, err := Parse([]string{"not-an-ip"}); err == nil {
+		t.Fatalf("expected error for invalid ip")
+	}
+}
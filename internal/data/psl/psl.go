@@ -0,0 +1,163 @@
+package psl
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/xxxsen/atlas/internal/idn"
+	"github.com/xxxsen/atlas/internal/watcher"
+	"github.com/xxxsen/common/logutil"
+	"go.uber.org/zap"
+)
+
+type ruleFlag uint8
+
+const (
+	flagExact     ruleFlag = 1 << iota // "co.uk"
+	flagWildcard                       // "*.ck", stored on "ck"
+	flagException                      // "!www.ck"
+)
+
+// List is a parsed Public Suffix List.
+type List struct {
+	rules map[string]ruleFlag
+}
+
+// Parse reads the public_suffix_list.dat format, both the ICANN and the
+// PRIVATE sections are used so that "github.io" is a public suffix too.
+func Parse(r io.Reader) (*List, error) {
+	l := &List{rules: make(map[string]ruleFlag)}
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+		// a rule ends at the first whitespace
+		if idx := strings.IndexAny(line, " \t"); idx >= 0 {
+			line = line[:idx]
+		}
+		flag := flagExact
+		switch {
+		case strings.HasPrefix(line, "!"):
+			flag = flagException
+			line = line[1:]
+		case strings.HasPrefix(line, "*."):
+			flag = flagWildcard
+			line = line[2:]
+		}
+		rule, err := idn.ToASCII(line)
+		if err != nil || rule == "" || strings.Contains(rule, "*") {
+			return nil, fmt.Errorf("invalid psl rule at line %d: %s", lineNum, scanner.Text())
+		}
+		l.rules[rule] |= flag
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(l.rules) == 0 {
+		return nil, fmt.Errorf("psl contains no rule")
+	}
+	return l, nil
+}
+
+// Load reads a Public Suffix List file.
+func Load(path string) (*List, error) {
+	cleanPath := filepath.Clean(path)
+	f, err := os.Open(cleanPath)
+	if err != nil {
+		return nil, fmt.Errorf("open psl file %s: %w", cleanPath, err)
+	}
+	defer f.Close()
+	l, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("parse psl file %s: %w", cleanPath, err)
+	}
+	return l, nil
+}
+
+// PublicSuffix returns the public suffix of a lower case ascii domain without
+// trailing dot. Names not covered by any rule fall back to their last label.
+func (l *List) PublicSuffix(domain string) string {
+	for suffix := domain; ; {
+		flags := l.rules[suffix]
+		if flags&flagException != 0 {
+			if dot := strings.IndexByte(suffix, '.'); dot >= 0 {
+				return suffix[dot+1:]
+			}
+			return suffix
+		}
+		if flags&flagExact != 0 {
+			return suffix
+		}
+		next := strings.IndexByte(suffix, '.')
+		if next < 0 {
+			break
+		}
+		if l.rules[suffix[next+1:]]&flagWildcard != 0 {
+			return suffix
+		}
+		suffix = suffix[next+1:]
+	}
+	if dot := strings.LastIndexByte(domain, '.'); dot >= 0 {
+		return domain[dot+1:]
+	}
+	return domain
+}
+
+// ETLDPlusOne returns the registrable domain, the public suffix plus one more
+// label. It reports false when domain is itself a public suffix.
+func (l *List) ETLDPlusOne(domain string) (string, bool) {
+	suffix := l.PublicSuffix(domain)
+	if len(suffix) >= len(domain) {
+		return "", false
+	}
+	rest := domain[:len(domain)-len(suffix)-1]
+	if dot := strings.LastIndexByte(rest, '.'); dot >= 0 {
+		rest = rest[dot+1:]
+	}
+	if rest == "" {
+		return "", false
+	}
+	return rest + "." + suffix, true
+}
+
+var current atomic.Pointer[List]
+
+// Configure loads the global list from file and reloads it whenever the file
+// changes, a failed reload keeps the previous list. An empty file leaves the
+// global list unset.
+func Configure(file string) error {
+	if file == "" {
+		current.Store(nil)
+		return nil
+	}
+	l, err := Load(file)
+	if err != nil {
+		return err
+	}
+	current.Store(l)
+	return watcher.Watch([]string{file}, func() {
+		logger := logutil.GetLogger(context.Background()).With(zap.String("file", file))
+		l, err := Load(file)
+		if err != nil {
+			logger.Error("reload psl failed, keep previous version", zap.Error(err))
+			return
+		}
+		current.Store(l)
+		logger.Info("reload psl succ")
+	})
+}
+
+// Current returns the global list, nil when no list was configured.
+func Current() *List {
+	return current.Load()
+}
@@ -0,0 +1,70 @@
+package psl
+
+import (
+	"strings"
+	"testing"
+)
+
+const testList = `// ===BEGIN ICANN DOMAINS===
+com
+uk
+co.uk
+// wildcard and exception rules
+*.ck
+!www.ck
+jp
+*.kobe.jp
+!city.kobe.jp
+中国
+// ===BEGIN PRIVATE DOMAINS===
+github.io
+`
+
+func mustParse(t *testing.T) *List {
+	l, err := Parse(strings.NewReader(testList))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	return l
+}
+
+func TestPublicSuffix(t *testing.T) {
+	l := mustParse(t)
+	tests := []struct {
+		domain string
+		suffix string
+		etld1  string
+	}{
+		{"example.com", "com", "example.com"},
+		{"www.example.co.uk", "co.uk", "example.co.uk"},
+		{"co.uk", "co.uk", ""},
+		{"foo.github.io", "github.io", "foo.github.io"},
+		{"a.bar.github.io", "github.io", "bar.github.io"},
+		{"a.b.ck", "b.ck", "a.b.ck"},
+		{"b.ck", "b.ck", ""},
+		{"www.ck", "ck", "www.ck"},
+		{"a.www.ck", "ck", "www.ck"},
+		{"a.b.kobe.jp", "b.kobe.jp", "a.b.kobe.jp"},
+		{"a.city.kobe.jp", "kobe.jp", "city.kobe.jp"},
+		{"www.xn--fsqu00a.xn--fiqs8s", "xn--fiqs8s", "xn--fsqu00a.xn--fiqs8s"},
+		{"example.unknown", "unknown", "example.unknown"},
+		{"localhost", "localhost", ""},
+	}
+	for _, tt := range tests {
+		if got := l.PublicSuffix(tt.domain); got != tt.suffix {
+			t.Fatalf("PublicSuffix(%s) = %s, want %s", tt.domain, got, tt.suffix)
+		}
+		got, ok := l.ETLDPlusOne(tt.domain)
+		if ok != (tt.etld1 != "") || got != tt.etld1 {
+			t.Fatalf("ETLDPlusOne(%s) = %s, %v, want %s", tt.domain, got, ok, tt.etld1)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, data := range []string{"", "// only comments\n", "a.*.com\n"} {
+		if _, err := Parse(strings.NewReader(data)); err == nil {
+			t.Fatalf("%q: expected error", data)
+		}
+	}
+}
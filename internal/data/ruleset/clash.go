@@ -0,0 +1,149 @@
+package ruleset
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type clashEntry struct {
+	value string
+	line  int
+}
+
+// ParseClash parses a Clash rule provider, either yaml with a payload list or a
+// plain text list with one entry per line. The three provider behaviours are
+// told apart per entry:
+//
+//	classical: DOMAIN-SUFFIX,example.com
+//	domain:    +.example.com, .example.com, *.example.com or example.com
+//	ipcidr:    192.168.0.0/16
+func ParseClash(data []byte, source string) (*RuleSet, error) {
+	entries, err := clashEntries(data)
+	if err != nil {
+		return nil, err
+	}
+	rs := &RuleSet{}
+	for _, e := range entries {
+		pos := fmt.Sprintf("%s:%d", source, e.line)
+		if err := rs.addClashEntry(e.value, pos); err != nil {
+			return nil, fmt.Errorf("%s: %w", pos, err)
+		}
+	}
+	return rs, nil
+}
+
+func clashEntries(data []byte) ([]clashEntry, error) {
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(data, doc); err == nil && len(doc.Content) == 1 && doc.Content[0].Kind == yaml.MappingNode {
+		root := doc.Content[0]
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			if root.Content[i].Value != "payload" {
+				continue
+			}
+			payload := root.Content[i+1]
+			if payload.Kind != yaml.SequenceNode {
+				return nil, fmt.Errorf("payload should be a list")
+			}
+			entries := make([]clashEntry, 0, len(payload.Content))
+			for _, item := range payload.Content {
+				if item.Kind != yaml.ScalarNode {
+					return nil, fmt.Errorf("line %d: payload entry should be a string", item.Line)
+				}
+				entries = append(entries, clashEntry{value: item.Value, line: item.Line})
+			}
+			return entries, nil
+		}
+		return nil, fmt.Errorf("payload not found")
+	}
+	var entries []clashEntry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, clashEntry{value: line, line: lineNum})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (rs *RuleSet) addClashEntry(entry string, pos string) error {
+	entry = strings.TrimSpace(entry)
+	if entry == "" {
+		return nil
+	}
+	if strings.Contains(entry, ",") {
+		return rs.addClassical(entry, pos)
+	}
+	if prefix, err := parseCIDR(entry); err == nil {
+		rs.CIDRs = append(rs.CIDRs, prefix)
+		return nil
+	}
+	rs.addDomainEntry(strings.ToLower(entry), pos)
+	return nil
+}
+
+func (rs *RuleSet) addDomainEntry(entry string, pos string) {
+	switch {
+	case strings.HasPrefix(entry, "+."):
+		rs.Domains = append(rs.Domains, DomainRule{Kind: KindSuffix, Value: entry[2:], Source: pos})
+	case strings.HasPrefix(entry, "."):
+		rs.Domains = append(rs.Domains, DomainRule{Kind: KindWildcard, Value: "**" + entry, Source: pos})
+	case strings.Contains(entry, "*"):
+		rs.Domains = append(rs.Domains, DomainRule{Kind: KindWildcard, Value: entry, Source: pos})
+	default:
+		rs.Domains = append(rs.Domains, DomainRule{Kind: KindFull, Value: entry, Source: pos})
+	}
+}
+
+func (rs *RuleSet) addClassical(entry string, pos string) error {
+	parts := strings.Split(entry, ",")
+	typ := strings.ToUpper(strings.TrimSpace(parts[0]))
+	value := strings.TrimSpace(parts[1])
+	if value == "" {
+		return fmt.Errorf("empty value in rule:%s", entry)
+	}
+	switch typ {
+	case "DOMAIN":
+		rs.Domains = append(rs.Domains, DomainRule{Kind: KindFull, Value: strings.ToLower(value), Source: pos})
+	case "DOMAIN-SUFFIX":
+		rs.Domains = append(rs.Domains, DomainRule{Kind: KindSuffix, Value: strings.ToLower(value), Source: pos})
+	case "DOMAIN-KEYWORD":
+		rs.Domains = append(rs.Domains, DomainRule{Kind: KindKeyword, Value: strings.ToLower(value), Source: pos})
+	case "DOMAIN-REGEX":
+		rs.Domains = append(rs.Domains, DomainRule{Kind: KindRegexp, Value: value, Source: pos})
+	case "IP-CIDR", "IP-CIDR6":
+		prefix, err := parseCIDR(value)
+		if err != nil {
+			return err
+		}
+		rs.CIDRs = append(rs.CIDRs, prefix)
+	case "SRC-IP-CIDR":
+		prefix, err := parseCIDR(value)
+		if err != nil {
+			return err
+		}
+		rs.SourceCIDRs = append(rs.SourceCIDRs, prefix)
+	default:
+		rs.Skipped++
+	}
+	return nil
+}
+
+func parseCIDR(in string) (string, error) {
+	prefix, err := netip.ParsePrefix(in)
+	if err != nil {
+		return "", err
+	}
+	return prefix.Masked().String(), nil
+}
@@ -0,0 +1,91 @@
+package ruleset
+
+import (
+	"strings"
+	"testing"
+)
+
+func domainRuleStrings(rules []DomainRule) string {
+	items := make([]string, 0, len(rules))
+	for _, r := range rules {
+		items = append(items, r.Kind+":"+r.Value)
+	}
+	return strings.Join(items, ",")
+}
+
+func TestParseClashClassical(t *testing.T) {
+	data := []byte(`# shared with the proxy config
+payload:
+  - DOMAIN,www.Example.com
+  - DOMAIN-SUFFIX,example.org
+  - DOMAIN-KEYWORD,tracker
+  - DOMAIN-REGEX,^ad[0-9]+\.example\.net$
+  - IP-CIDR,192.168.1.1/24,no-resolve
+  - IP-CIDR6,fd00::/8
+  - SRC-IP-CIDR,10.0.0.0/8
+  - PROCESS-NAME,curl
+  - DST-PORT,443
+`)
+	rs, err := ParseClash(data, "rules.yaml")
+	if err != nil {
+		t.Fatalf("ParseClash error: %v", err)
+	}
+	want := `full:www.example.com,suffix:example.org,keyword:tracker,regexp:^ad[0-9]+\.example\.net$`
+	if got := domainRuleStrings(rs.Domains); got != want {
+		t.Fatalf("unexpected domains:%s", got)
+	}
+	if rs.Domains[1].Source != "rules.yaml:4" {
+		t.Fatalf("unexpected source:%s", rs.Domains[1].Source)
+	}
+	if strings.Join(rs.CIDRs, ",") != "192.168.1.0/24,fd00::/8" {
+		t.Fatalf("unexpected cidrs:%v", rs.CIDRs)
+	}
+	if strings.Join(rs.SourceCIDRs, ",") != "10.0.0.0/8" {
+		t.Fatalf("unexpected source cidrs:%v", rs.SourceCIDRs)
+	}
+	if rs.Skipped != 2 {
+		t.Fatalf("expected 2 skipped entries, got %d", rs.Skipped)
+	}
+}
+
+func TestParseClashDomainBehavior(t *testing.T) {
+	data := []byte("payload:\n  - '+.example.com'\n  - '.example.org'\n  - '*.example.net'\n  - 'api.*.example.io'\n  - 'exact.example.com'\n")
+	rs, err := ParseClash(data, "domain.yaml")
+	if err != nil {
+		t.Fatalf("ParseClash error: %v", err)
+	}
+	want := "suffix:example.com,wildcard:**.example.org,wildcard:*.example.net,wildcard:api.*.example.io,full:exact.example.com"
+	if got := domainRuleStrings(rs.Domains); got != want {
+		t.Fatalf("unexpected domains:%s", got)
+	}
+}
+
+func TestParseClashText(t *testing.T) {
+	data := []byte("# text provider\n+.example.com\n\n10.0.0.0/8\nDOMAIN,a.example.org\n")
+	rs, err := ParseClash(data, "list.txt")
+	if err != nil {
+		t.Fatalf("ParseClash error: %v", err)
+	}
+	if got := domainRuleStrings(rs.Domains); got != "suffix:example.com,full:a.example.org" {
+		t.Fatalf("unexpected domains:%s", got)
+	}
+	if rs.Domains[1].Source != "list.txt:5" {
+		t.Fatalf("unexpected source:%s", rs.Domains[1].Source)
+	}
+	if strings.Join(rs.CIDRs, ",") != "10.0.0.0/8" {
+		t.Fatalf("unexpected cidrs:%v", rs.CIDRs)
+	}
+}
+
+func TestParseClashInvalid(t *testing.T) {
+	for _, data := range []string{
+		"rules:\n  - DOMAIN,example.com\n",
+		"payload: DOMAIN,example.com\n",
+		"payload:\n  - IP-CIDR,300.0.0.0/8\n",
+		"payload:\n  - DOMAIN,\n",
+	} {
+		if _, err := ParseClash([]byte(data), "bad.yaml"); err == nil {
+			t.Fatalf("%q: expected error", data)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package ruleset
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Domain rule kinds, they are the same as the kinds understood by the domain matcher.
+const (
+	KindFull     = "full"
+	KindSuffix   = "suffix"
+	KindKeyword  = "keyword"
+	KindRegexp   = "regexp"
+	KindWildcard = "wildcard"
+)
+
+// DomainRule is a domain rule converted from a rule-set entry.
+type DomainRule struct {
+	Kind   string
+	Value  string
+	Source string
+}
+
+// RuleSet is the part of a Clash or sing-box rule-set that can be used for dns matching.
+type RuleSet struct {
+	Domains     []DomainRule
+	CIDRs       []string // destination addresses, IP-CIDR or ip_cidr
+	SourceCIDRs []string // SRC-IP-CIDR or source_ip_cidr
+	Skipped     int      // entries that have no dns equivalent, such as ports or process names
+}
+
+// Load reads a rule-set file, sing-box binary rule-sets are detected by their
+// magic header and everything else is parsed as a Clash rule provider.
+func Load(path string) (*RuleSet, error) {
+	cleanPath := filepath.Clean(path)
+	data, err := os.ReadFile(cleanPath)
+	if err != nil {
+		return nil, fmt.Errorf("read rule-set %s: %w", cleanPath, err)
+	}
+	var rs *RuleSet
+	if bytes.HasPrefix(data, srsMagic[:]) {
+		rs, err = ParseSRS(data, cleanPath)
+	} else {
+		rs, err = ParseClash(data, cleanPath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse rule-set %s: %w", cleanPath, err)
+	}
+	return rs, nil
+}
+
+// LoadFiles loads and merges several rule-set files.
+func LoadFiles(files []string) (*RuleSet, error) {
+	merged := &RuleSet{}
+	for _, file := range files {
+		rs, err := Load(file)
+		if err != nil {
+			return nil, err
+		}
+		merged.Domains = append(merged.Domains, rs.Domains...)
+		merged.CIDRs = append(merged.CIDRs, rs.CIDRs...)
+		merged.SourceCIDRs = append(merged.SourceCIDRs, rs.SourceCIDRs...)
+		merged.Skipped += rs.Skipped
+	}
+	return merged, nil
+}
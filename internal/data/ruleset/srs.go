@@ -0,0 +1,496 @@
+package ruleset
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/netip"
+
+	"github.com/xxxsen/atlas/internal/data/ipset"
+)
+
+var srsMagic = [3]byte{'S', 'R', 'S'}
+
+const srsMaxVersion = 5
+
+// srsMaxLength bounds the lengths and element counts read from a rule-set, so
+// a corrupt file fails instead of allocating without limit.
+const srsMaxLength = 1 << 24
+
+// rule item types of the sing-box binary rule-set format.
+const (
+	srsItemQueryType               = 0
+	srsItemNetwork                 = 1
+	srsItemDomain                  = 2
+	srsItemDomainKeyword           = 3
+	srsItemDomainRegex             = 4
+	srsItemSourceIPCIDR            = 5
+	srsItemIPCIDR                  = 6
+	srsItemSourcePort              = 7
+	srsItemSourcePortRange         = 8
+	srsItemPort                    = 9
+	srsItemPortRange               = 10
+	srsItemProcessName             = 11
+	srsItemProcessPath             = 12
+	srsItemPackageName             = 13
+	srsItemWIFISSID                = 14
+	srsItemWIFIBSSID               = 15
+	srsItemAdGuardDomain           = 16
+	srsItemProcessPathRegex        = 17
+	srsItemNetworkType             = 18
+	srsItemNetworkIsExpensive      = 19
+	srsItemNetworkIsConstrained    = 20
+	srsItemNetworkInterfaceAddress = 21
+	srsItemDefaultInterfaceAddress = 22
+	srsItemPackageNameRegex        = 23
+	srsItemFinal                   = 0xFF
+)
+
+const (
+	srsRuleTypeDefault = 0
+	srsRuleTypeLogical = 1
+	srsIPSetVersion    = 1
+
+	srsSuffixLabel byte = '\n' // the domain itself and all its subdomains
+	srsPrefixLabel byte = '\r' // subdomains only, followed by '.'
+)
+
+// ParseSRS parses a sing-box binary rule-set (.srs). Only plain rules are
+// converted, logical rules, inverted rules, AdGuard rules and rules that also
+// depend on ports, processes, networks or query types have no dns equivalent
+// and are skipped.
+func ParseSRS(data []byte, source string) (*RuleSet, error) {
+	if len(data) < 4 || !bytes.HasPrefix(data, srsMagic[:]) {
+		return nil, fmt.Errorf("invalid srs magic")
+	}
+	version := data[3]
+	if version == 0 || version > srsMaxVersion {
+		return nil, fmt.Errorf("unsupported srs version:%d", version)
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(data[4:]))
+	if err != nil {
+		return nil, fmt.Errorf("open srs body: %w", err)
+	}
+	defer zr.Close()
+	r := bufio.NewReader(zr)
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, fmt.Errorf("read srs rule count: %w", err)
+	}
+	rs := &RuleSet{}
+	for i := uint64(0); i < count; i++ {
+		rule, err := readSRSRule(r)
+		if err != nil {
+			return nil, fmt.Errorf("read srs rule %d: %w", i, err)
+		}
+		rule.mergeInto(rs, fmt.Sprintf("%s#%d", source, i))
+	}
+	return rs, nil
+}
+
+type srsRule struct {
+	domains     []DomainRule
+	cidrs       []string
+	sourceCIDRs []string
+	unsupported bool
+}
+
+// mergeInto adds the rule to rs. Destination items (domains and ip_cidr) are
+// or-ed while different item groups are and-ed, so a rule is only usable when
+// it consists of one group.
+func (r *srsRule) mergeInto(rs *RuleSet, source string) {
+	hasDest := len(r.domains) > 0 || len(r.cidrs) > 0
+	if r.unsupported || (hasDest && len(r.sourceCIDRs) > 0) {
+		rs.Skipped++
+		return
+	}
+	for _, d := range r.domains {
+		d.Source = source
+		rs.Domains = append(rs.Domains, d)
+	}
+	rs.CIDRs = append(rs.CIDRs, r.cidrs...)
+	rs.SourceCIDRs = append(rs.SourceCIDRs, r.sourceCIDRs...)
+}
+
+func readSRSRule(r *bufio.Reader) (*srsRule, error) {
+	typ, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	switch typ {
+	case srsRuleTypeDefault:
+		return readSRSDefaultRule(r)
+	case srsRuleTypeLogical:
+		if _, err := r.ReadByte(); err != nil { // mode
+			return nil, err
+		}
+		count, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		for i := uint64(0); i < count; i++ {
+			if _, err := readSRSRule(r); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := r.ReadByte(); err != nil { // invert
+			return nil, err
+		}
+		return &srsRule{unsupported: true}, nil
+	default:
+		return nil, fmt.Errorf("unknown rule type:%d", typ)
+	}
+}
+
+func readSRSDefaultRule(r *bufio.Reader) (*srsRule, error) {
+	rule := &srsRule{}
+	for {
+		item, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		switch item {
+		case srsItemDomain:
+			domains, err := readSRSDomainSet(r)
+			if err != nil {
+				return nil, fmt.Errorf("read domain set: %w", err)
+			}
+			rule.domains = append(rule.domains, domains...)
+		case srsItemDomainKeyword, srsItemDomainRegex:
+			values, err := readSRSStrings(r)
+			if err != nil {
+				return nil, err
+			}
+			kind := KindKeyword
+			if item == srsItemDomainRegex {
+				kind = KindRegexp
+			}
+			for _, v := range values {
+				rule.domains = append(rule.domains, DomainRule{Kind: kind, Value: v})
+			}
+		case srsItemIPCIDR, srsItemSourceIPCIDR:
+			cidrs, err := readSRSIPSet(r)
+			if err != nil {
+				return nil, fmt.Errorf("read ip set: %w", err)
+			}
+			if item == srsItemIPCIDR {
+				rule.cidrs = append(rule.cidrs, cidrs...)
+			} else {
+				rule.sourceCIDRs = append(rule.sourceCIDRs, cidrs...)
+			}
+		case srsItemQueryType, srsItemSourcePort, srsItemPort:
+			if err := skipSRSUint16s(r); err != nil {
+				return nil, err
+			}
+			rule.unsupported = true
+		case srsItemNetwork, srsItemSourcePortRange, srsItemPortRange, srsItemProcessName, srsItemProcessPath,
+			srsItemPackageName, srsItemWIFISSID, srsItemWIFIBSSID, srsItemProcessPathRegex, srsItemPackageNameRegex:
+			if _, err := readSRSStrings(r); err != nil {
+				return nil, err
+			}
+			rule.unsupported = true
+		case srsItemNetworkType:
+			count, err := binary.ReadUvarint(r)
+			if err != nil {
+				return nil, err
+			}
+			if count > srsMaxLength {
+				return nil, fmt.Errorf("invalid length:%d", count)
+			}
+			if _, err := r.Discard(int(count)); err != nil {
+				return nil, err
+			}
+			rule.unsupported = true
+		case srsItemAdGuardDomain:
+			if _, _, _, err := readSRSSuccinctSet(r); err != nil {
+				return nil, fmt.Errorf("read adguard domain set: %w", err)
+			}
+			rule.unsupported = true
+		case srsItemNetworkInterfaceAddress:
+			count, err := binary.ReadUvarint(r)
+			if err != nil {
+				return nil, err
+			}
+			if count > srsMaxLength {
+				return nil, fmt.Errorf("invalid length:%d", count)
+			}
+			for i := uint64(0); i < count; i++ {
+				if _, err := r.ReadByte(); err != nil { // interface type
+					return nil, err
+				}
+				if err := skipSRSPrefixes(r); err != nil {
+					return nil, err
+				}
+			}
+			rule.unsupported = true
+		case srsItemDefaultInterfaceAddress:
+			if err := skipSRSPrefixes(r); err != nil {
+				return nil, err
+			}
+			rule.unsupported = true
+		case srsItemNetworkIsExpensive, srsItemNetworkIsConstrained:
+			rule.unsupported = true
+		case srsItemFinal:
+			invert, err := r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			if invert != 0 {
+				rule.unsupported = true
+			}
+			return rule, nil
+		default:
+			// items carry no length, an item type added after rule-set version
+			// srsMaxVersion can not be skipped
+			return nil, fmt.Errorf("unsupported rule item type:%d", item)
+		}
+	}
+}
+
+func readSRSStrings(r *bufio.Reader) ([]string, error) {
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if count > srsMaxLength {
+		return nil, fmt.Errorf("invalid length:%d", count)
+	}
+	rs := make([]string, 0, count)
+	for i := uint64(0); i < count; i++ {
+		buf, err := readSRSBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		rs = append(rs, string(buf))
+	}
+	return rs, nil
+}
+
+func readSRSBytes(r *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > srsMaxLength {
+		return nil, fmt.Errorf("invalid length:%d", n)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func skipSRSUint16s(r *bufio.Reader) error {
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return err
+	}
+	if count > srsMaxLength {
+		return fmt.Errorf("invalid length:%d", count)
+	}
+	_, err = r.Discard(int(count) * 2)
+	return err
+}
+
+// skipSRSPrefixes skips a list of prefixes, each stored as an address and a
+// prefix length byte.
+func skipSRSPrefixes(r *bufio.Reader) error {
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return err
+	}
+	if count > srsMaxLength {
+		return fmt.Errorf("invalid length:%d", count)
+	}
+	for i := uint64(0); i < count; i++ {
+		if _, err := readSRSAddr(r); err != nil {
+			return err
+		}
+		if _, err := r.ReadByte(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readSRSUint64s(r *bufio.Reader) ([]uint64, error) {
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if count > srsMaxLength {
+		return nil, fmt.Errorf("invalid length:%d", count)
+	}
+	rs := make([]uint64, count)
+	if err := binary.Read(r, binary.BigEndian, rs); err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+// readSRSSuccinctSet reads the succinct trie sing-box stores domain and
+// AdGuard rules in. The leading version byte is ignored, as sing-box does.
+func readSRSSuccinctSet(r *bufio.Reader) (leaves, bitmap []uint64, labels []byte, err error) {
+	if _, err = r.ReadByte(); err != nil {
+		return nil, nil, nil, err
+	}
+	if leaves, err = readSRSUint64s(r); err != nil {
+		return nil, nil, nil, err
+	}
+	if bitmap, err = readSRSUint64s(r); err != nil {
+		return nil, nil, nil, err
+	}
+	if labels, err = readSRSBytes(r); err != nil {
+		return nil, nil, nil, err
+	}
+	return leaves, bitmap, labels, nil
+}
+
+// readSRSDomainSet decodes the domains of a succinct trie. Keys are reversed
+// domains, a trailing '\n' marks a suffix rule and a trailing "\r." a
+// subdomain only rule.
+func readSRSDomainSet(r *bufio.Reader) ([]DomainRule, error) {
+	leaves, bitmap, labels, err := readSRSSuccinctSet(r)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := succinctKeys(leaves, bitmap, labels)
+	if err != nil {
+		return nil, err
+	}
+	rs := make([]DomainRule, 0, len(keys))
+	for _, key := range keys {
+		domain := reverseString(key)
+		switch {
+		case len(domain) > 0 && domain[0] == srsSuffixLabel:
+			rs = append(rs, DomainRule{Kind: KindSuffix, Value: domain[1:]})
+		case len(domain) > 1 && domain[0] == srsPrefixLabel && domain[1] == '.':
+			rs = append(rs, DomainRule{Kind: KindWildcard, Value: "**" + domain[1:]})
+		default:
+			rs = append(rs, DomainRule{Kind: KindFull, Value: domain})
+		}
+	}
+	return rs, nil
+}
+
+func bitSet(bm []uint64, i int) bool {
+	return i>>6 < len(bm) && bm[i>>6]&(1<<uint(i&63)) != 0
+}
+
+// succinctKeys lists the keys of a LOUDS encoded trie. Nodes are numbered in
+// breadth first order, every node owns a run of 0 bits in bitmap, one per
+// child, terminated by a 1 bit. The n-th 0 bit leads to node n+1 via labels[n].
+func succinctKeys(leaves, bitmap []uint64, labels []byte) ([]string, error) {
+	nodes := len(labels) + 1
+	parents := make([]int32, nodes)
+	node := 0
+	child := 0
+	for pos := 0; node < nodes; pos++ {
+		if pos >= len(bitmap)*64 {
+			return nil, fmt.Errorf("truncated label bitmap")
+		}
+		if bitSet(bitmap, pos) {
+			node++
+			continue
+		}
+		if child >= len(labels) {
+			return nil, fmt.Errorf("label bitmap has more children than labels")
+		}
+		child++
+		parents[child] = int32(node)
+	}
+	var keys []string
+	var buf []byte
+	for i := 0; i < nodes; i++ {
+		if !bitSet(leaves, i) {
+			continue
+		}
+		buf = buf[:0]
+		for n := i; n != 0; n = int(parents[n]) {
+			buf = append(buf, labels[n-1])
+		}
+		// buf holds the key from its last byte to its first
+		keys = append(keys, reverseString(string(buf)))
+	}
+	return keys, nil
+}
+
+func reverseString(s string) string {
+	b := []byte(s)
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b)
+}
+
+// readSRSIPSet decodes an ip set stored as address ranges into prefixes.
+func readSRSIPSet(r *bufio.Reader) ([]string, error) {
+	version, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if version != srsIPSetVersion {
+		return nil, fmt.Errorf("unsupported ip set version:%d", version)
+	}
+	var count uint64
+	if err := binary.Read(r, binary.BigEndian, &count); err != nil {
+		return nil, err
+	}
+	var rs []string
+	for i := uint64(0); i < count; i++ {
+		from, err := readSRSAddr(r)
+		if err != nil {
+			return nil, err
+		}
+		to, err := readSRSAddr(r)
+		if err != nil {
+			return nil, err
+		}
+		if from.BitLen() != to.BitLen() || to.Less(from) {
+			return nil, fmt.Errorf("invalid ip range:%s-%s", from, to)
+		}
+		for _, p := range rangePrefixes(from, to) {
+			rs = append(rs, p.String())
+		}
+	}
+	return rs, nil
+}
+
+func readSRSAddr(r *bufio.Reader) (netip.Addr, error) {
+	buf, err := readSRSBytes(r)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	addr, ok := netip.AddrFromSlice(buf)
+	if !ok {
+		return netip.Addr{}, fmt.Errorf("invalid ip length:%d", len(buf))
+	}
+	return addr, nil
+}
+
+// rangePrefixes splits the inclusive range [from, to] into the fewest prefixes.
+func rangePrefixes(from, to netip.Addr) []netip.Prefix {
+	var rs []netip.Prefix
+	bits := from.BitLen()
+	for {
+		size := bits
+		for size > 0 {
+			p := netip.PrefixFrom(from, size-1).Masked()
+			if p.Addr() != from || ipset.LastAddr(p).Compare(to) > 0 {
+				break
+			}
+			size--
+		}
+		p := netip.PrefixFrom(from, size)
+		rs = append(rs, p)
+		last := ipset.LastAddr(p)
+		if last.Compare(to) >= 0 {
+			return rs
+		}
+		from = last.Next()
+	}
+}
@@ -0,0 +1,41 @@
+package dnsutil
+
+import (
+	"net/netip"
+	"strings"
+)
+
+// ParseReverseAddr parses an in-addr.arpa or ip6.arpa name, the reverse of dns.ReverseAddr.
+func ParseReverseAddr(name string) (netip.Addr, bool) {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	if rest, ok := strings.CutSuffix(name, ".in-addr.arpa"); ok {
+		labels := strings.Split(rest, ".")
+		if len(labels) != 4 {
+			return netip.Addr{}, false
+		}
+		for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+			labels[i], labels[j] = labels[j], labels[i]
+		}
+		addr, err := netip.ParseAddr(strings.Join(labels, "."))
+		return addr, err == nil && addr.Is4()
+	}
+	if rest, ok := strings.CutSuffix(name, ".ip6.arpa"); ok {
+		labels := strings.Split(rest, ".")
+		if len(labels) != 32 {
+			return netip.Addr{}, false
+		}
+		var sb strings.Builder
+		for i := len(labels) - 1; i >= 0; i-- {
+			if len(labels[i]) != 1 {
+				return netip.Addr{}, false
+			}
+			sb.WriteString(labels[i])
+			if i%4 == 0 && i != 0 {
+				sb.WriteByte(':')
+			}
+		}
+		addr, err := netip.ParseAddr(sb.String())
+		return addr, err == nil && addr.Is6()
+	}
+	return netip.Addr{}, false
+}
@@ -0,0 +1,32 @@
+package dnsutil
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestParseReverseAddr(t *testing.T) {
+	for _, ip := range []string{"192.0.2.1", "2001:db8::1", "64:ff9b::c000:201", "fc00::"} {
+		name, err := dns.ReverseAddr(ip)
+		if err != nil {
+			t.Fatalf("ReverseAddr error: %v", err)
+		}
+		addr, ok := ParseReverseAddr(name)
+		if !ok || addr.String() != ip {
+			t.Fatalf("expected %s from %s, got %v", ip, name, addr)
+		}
+	}
+	for _, name := range []string{
+		"example.com.",
+		"1.2.0.192.in-addr.arpa.extra.",
+		"2.0.192.in-addr.arpa.",
+		"300.2.0.192.in-addr.arpa.",
+		"1.0.0.0.ip6.arpa.",
+		"10.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.",
+	} {
+		if addr, ok := ParseReverseAddr(name); ok {
+			t.Fatalf("expected %s to be rejected, got %v", name, addr)
+		}
+	}
+}
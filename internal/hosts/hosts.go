@@ -2,12 +2,19 @@ package hosts
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"net"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/miekg/dns"
+	"github.com/xxxsen/atlas/internal/idn"
+	"github.com/xxxsen/atlas/internal/watcher"
+	"github.com/xxxsen/common/logutil"
+	"go.uber.org/zap"
 )
 
 // DefaultTTL is the TTL applied to host records.
@@ -39,9 +46,68 @@ func New(records ...map[string]string) (IHostResolver, error) {
 	return st, nil
 }
 
+type reloadableStore struct {
+	records map[string]string
+	files   []string
+	mu      sync.Mutex
+	cur     atomic.Pointer[hostStore]
+}
+
+// NewFromFiles builds a host resolver from inline records and host files.
+// The files are watched and the store is rebuilt when any of them changes,
+// a failed rebuild keeps the previous records.
+func NewFromFiles(records map[string]string, files []string) (IHostResolver, error) {
+	st, err := buildFromFiles(records, files)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return st, nil
+	}
+	r := &reloadableStore{records: records, files: files}
+	r.cur.Store(st)
+	if err := watcher.Watch(files, r.reload); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func buildFromFiles(records map[string]string, files []string) (*hostStore, error) {
+	recs, err := LoadRecordsFromFiles(files)
+	if err != nil {
+		return nil, err
+	}
+	st, err := New(append(recs, records)...)
+	if err != nil {
+		return nil, err
+	}
+	return st.(*hostStore), nil
+}
+
+func (r *reloadableStore) Resolve(q dns.Question) ([]dns.RR, bool) {
+	return r.cur.Load().Resolve(q)
+}
+
+func (r *reloadableStore) reload() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	logger := logutil.GetLogger(context.Background()).With(zap.Strings("files", r.files))
+	st, err := buildFromFiles(r.records, r.files)
+	if err != nil {
+		logger.Error("reload host files failed, keep previous records", zap.Error(err))
+		return
+	}
+	r.cur.Store(st)
+	logger.Info("reload host files succ")
+}
+
 func (s *hostStore) mergeRecords(m map[string]string, out map[string]*record) error {
 	for domain, list := range m {
-		domain = normalize(domain)
+		ascii, err := idn.ToASCII(normalize(domain))
+		if err != nil {
+			return fmt.Errorf("hosts: %w", err)
+		}
+		domain = ascii
 		if domain == "" {
 			return fmt.Errorf("hosts: invalid domain in records")
 		}
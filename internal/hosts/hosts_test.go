@@ -0,0 +1,99 @@
+package hosts
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/xxxsen/atlas/internal/watcher"
+)
+
+func resolveA(t *testing.T, r IHostResolver, name string) []string {
+	t.Helper()
+	rrs, ok := r.Resolve(dns.Question{Name: name, Qtype: dns.TypeA, Qclass: dns.ClassINET})
+	if !ok {
+		return nil
+	}
+	ips := make([]string, 0, len(rrs))
+	for _, rr := range rrs {
+		ips = append(ips, rr.(*dns.A).A.String())
+	}
+	return ips
+}
+
+func TestNewFromFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+	content := "# static hosts\nWWW.Example.com 10.0.0.1 10.0.0.2\n例子.中国 10.0.0.3\nv6.example.com ::1\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	r, err := NewFromFiles(map[string]string{"inline.example.com": "10.0.0.9"}, []string{path})
+	if err != nil {
+		t.Fatalf("NewFromFiles error: %v", err)
+	}
+	if got := resolveA(t, r, "www.example.com."); len(got) != 2 || got[0] != "10.0.0.1" || got[1] != "10.0.0.2" {
+		t.Fatalf("unexpected answers %v", got)
+	}
+	if got := resolveA(t, r, "xn--fsqu00a.xn--fiqs8s."); len(got) != 1 || got[0] != "10.0.0.3" {
+		t.Fatalf("unexpected answers for the idn host %v", got)
+	}
+	if got := resolveA(t, r, "inline.example.com."); len(got) != 1 || got[0] != "10.0.0.9" {
+		t.Fatalf("unexpected answers for the inline record %v", got)
+	}
+	if _, ok := r.Resolve(dns.Question{Name: "v6.example.com.", Qtype: dns.TypeA, Qclass: dns.ClassINET}); ok {
+		t.Fatalf("expected no A record for an ipv6 only host")
+	}
+	if _, ok := r.Resolve(dns.Question{Name: "www.example.com.", Qtype: dns.TypeMX, Qclass: dns.ClassINET}); ok {
+		t.Fatalf("expected no answer for MX")
+	}
+}
+
+func TestNewFromFilesInvalid(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"missing-ip": "www.example.com\n",
+		"bad-ip":     "www.example.com 10.0.0.256\n",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+		if _, err := NewFromFiles(nil, []string{path}); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
+
+func TestNewFromFilesReload(t *testing.T) {
+	if err := watcher.Configure(watcher.Options{Enable: true, Debounce: 50 * time.Millisecond}); err != nil {
+		t.Fatalf("configure watcher: %v", err)
+	}
+	defer watcher.Close()
+
+	path := filepath.Join(t.TempDir(), "hosts")
+	if err := os.WriteFile(path, []byte("www.example.com 10.0.0.1\n"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	r, err := NewFromFiles(nil, []string{path})
+	if err != nil {
+		t.Fatalf("NewFromFiles error: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("www.example.com 10.0.0.2\n"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	if got := resolveA(t, r, "www.example.com."); len(got) != 1 || got[0] != "10.0.0.2" {
+		t.Fatalf("expected reloaded record, got %v", got)
+	}
+
+	if err := os.WriteFile(path, []byte("www.example.com\n"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	if got := resolveA(t, r, "www.example.com."); len(got) != 1 || got[0] != "10.0.0.2" {
+		t.Fatalf("expected previous record after a failed reload, got %v", got)
+	}
+}
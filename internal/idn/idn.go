@@ -0,0 +1,83 @@
+package idn
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// profile follows the UTS-46 lookup mapping but does not enforce STD3 rules,
+// configured names may carry '_' (srv and dkim names) or wildcard labels.
+var profile = idna.New(
+	idna.MapForLookup(),
+	idna.Transitional(false),
+	idna.StrictDomainName(false),
+	idna.BidiRule(),
+)
+
+var display = idna.Display
+
+// ToASCII lowercases a configured domain and converts its unicode labels to
+// punycode, so "例子.中国" becomes "xn--fsqu00a.xn--fiqs8s". Labels containing '*'
+// are kept as written so wildcard patterns can be normalized too.
+func ToASCII(domain string) (string, error) {
+	if isASCII(domain) {
+		return strings.ToLower(domain), nil
+	}
+	labels := strings.Split(domain, ".")
+	for i, label := range labels {
+		if isASCII(label) || strings.Contains(label, "*") {
+			labels[i] = strings.ToLower(label)
+			continue
+		}
+		ascii, err := profile.ToASCII(label)
+		if err != nil {
+			return "", fmt.Errorf("invalid idn label %q in %s: %w", label, domain, err)
+		}
+		labels[i] = ascii
+	}
+	return strings.Join(labels, "."), nil
+}
+
+// ToUnicode converts punycode labels back to unicode for display, labels that
+// fail to decode are left untouched.
+func ToUnicode(domain string) string {
+	if !HasPunycode(domain) {
+		return domain
+	}
+	labels := strings.Split(domain, ".")
+	for i, label := range labels {
+		if !isPunycode(label) {
+			continue
+		}
+		// a label decoding to plain ascii is not a valid idn, keep it as is
+		if u, err := display.ToUnicode(strings.ToLower(label)); err == nil && !isASCII(u) {
+			labels[i] = u
+		}
+	}
+	return strings.Join(labels, ".")
+}
+
+// HasPunycode reports whether any label of domain is an "xn--" label.
+func HasPunycode(domain string) bool {
+	for label := range strings.SplitSeq(domain, ".") {
+		if isPunycode(label) {
+			return true
+		}
+	}
+	return false
+}
+
+func isPunycode(label string) bool {
+	return len(label) > 4 && strings.EqualFold(label[:4], "xn--")
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
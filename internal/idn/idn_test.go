@@ -0,0 +1,47 @@
+package idn
+
+import "testing"
+
+func TestToASCII(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Example.COM", "example.com"},
+		{"例子.中国", "xn--fsqu00a.xn--fiqs8s"},
+		{"WWW.Bücher.de", "www.xn--bcher-kva.de"},
+		{"faß.de", "xn--fa-hia.de"},
+		{"*.例子.中国", "*.xn--fsqu00a.xn--fiqs8s"},
+		{"_dmarc.例子.中国", "_dmarc.xn--fsqu00a.xn--fiqs8s"},
+		{"ＥＸＡＭＰＬＥ.com", "example.com"},
+	}
+	for _, tt := range tests {
+		got, err := ToASCII(tt.in)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.in, err)
+		}
+		if got != tt.want {
+			t.Fatalf("%s: expected %s got %s", tt.in, tt.want, got)
+		}
+	}
+	if _, err := ToASCII("a‍.example"); err == nil {
+		t.Fatalf("expected error for invalid label")
+	}
+}
+
+func TestToUnicode(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"xn--fsqu00a.xn--fiqs8s", "例子.中国"},
+		{"www.XN--bcher-kva.de", "www.bücher.de"},
+		{"example.com", "example.com"},
+		{"xn--invalid-.com", "xn--invalid-.com"},
+	}
+	for _, tt := range tests {
+		if got := ToUnicode(tt.in); got != tt.want {
+			t.Fatalf("%s: expected %s got %s", tt.in, tt.want, got)
+		}
+	}
+}
@@ -35,4 +35,7 @@ func createAnyMatcher(name string, args interface{}) (IDNSMatcher, error) {
 
 func init() {
 	Register("any", createAnyMatcher)
+	RegisterInline("any", func(args []string) (interface{}, error) {
+		return nil, nil
+	})
 }
@@ -0,0 +1,81 @@
+package matcher
+
+import (
+	"context"
+
+	"github.com/miekg/dns"
+	"github.com/xxxsen/atlas/internal/clientip"
+	"github.com/xxxsen/atlas/internal/data/ipset"
+	"github.com/xxxsen/atlas/internal/data/ruleset"
+	"github.com/xxxsen/atlas/internal/matcher"
+	"github.com/xxxsen/common/logutil"
+	"github.com/xxxsen/common/utils"
+	"go.uber.org/zap"
+)
+
+type clientMatcher struct {
+	name string
+	set  *ipset.Set
+}
+
+func (c *clientMatcher) Name() string {
+	return c.name
+}
+
+func (c *clientMatcher) Type() string {
+	return "client"
+}
+
+func (c *clientMatcher) Match(ctx context.Context, req *dns.Msg) (bool, error) {
+	addr, ok := clientip.From(ctx)
+	if !ok || !c.set.Contains(addr) {
+		return false, nil
+	}
+	matcher.ReportEvidence(ctx, c.name, c.Type(), addr.String())
+	return true, nil
+}
+
+func newClientMatcher(name string, cidrs []string) (matcher.IDNSMatcher, error) {
+	set, err := ipset.Parse(cidrs)
+	if err != nil {
+		return nil, err
+	}
+	return &clientMatcher{name: name, set: set}, nil
+}
+
+func createClientMatcher(name string, args interface{}) (matcher.IDNSMatcher, error) {
+	c := &config{}
+	if err := utils.ConvStructJson(args, c); err != nil {
+		return nil, err
+	}
+	watchFiles := append(append([]string(nil), c.Files...), c.RuleSets...)
+	return matcher.NewReloadableMatcher(name, "client", watchFiles, func() (matcher.IDNSMatcher, error) {
+		cidrs := append([]string(nil), c.CIDRs...)
+		fileCIDRs, err := ipset.LoadFiles(c.Files)
+		if err != nil {
+			return nil, err
+		}
+		cidrs = append(cidrs, fileCIDRs...)
+		if len(c.RuleSets) > 0 {
+			rs, err := ruleset.LoadFiles(c.RuleSets)
+			if err != nil {
+				return nil, err
+			}
+			// IP-CIDR and ip_cidr entries are destination addresses, they are
+			// used by the actions checking answer addresses instead
+			if len(rs.CIDRs) > 0 {
+				logutil.GetLogger(context.Background()).Warn("rule-set destination cidr entries ignored by client matcher",
+					zap.String("matcher", name), zap.Int("count", len(rs.CIDRs)))
+			}
+			cidrs = append(cidrs, rs.SourceCIDRs...)
+		}
+		return newClientMatcher(name, cidrs)
+	})
+}
+
+func init() {
+	matcher.Register("client", createClientMatcher)
+	matcher.RegisterInline("client", func(args []string) (interface{}, error) {
+		return map[string]interface{}{"cidrs": args}, nil
+	})
+}
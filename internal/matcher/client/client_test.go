@@ -0,0 +1,89 @@
+package matcher
+
+import (
+	"context"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/xxxsen/atlas/internal/clientip"
+	"github.com/xxxsen/atlas/internal/matcher"
+)
+
+func TestClientMatcher(t *testing.T) {
+	m, err := newClientMatcher("lan", []string{"192.168.0.0/16", "fd00::/8", "10.0.0.1"})
+	if err != nil {
+		t.Fatalf("newClientMatcher error: %v", err)
+	}
+	req := new(dns.Msg)
+	req.SetQuestion("example.com.", dns.TypeA)
+	tests := []struct {
+		addr  string
+		match bool
+	}{
+		{"192.168.1.20", true},
+		{"::ffff:192.168.1.20", true},
+		{"10.0.0.1", true},
+		{"10.0.0.2", false},
+		{"fd12::1", true},
+		{"2001:db8::1", false},
+	}
+	for _, tt := range tests {
+		ctx := clientip.With(context.Background(), netip.MustParseAddr(tt.addr))
+		ok, err := m.Match(ctx, req)
+		if err != nil {
+			t.Fatalf("%s: match error: %v", tt.addr, err)
+		}
+		if ok != tt.match {
+			t.Fatalf("%s: expected %v got %v", tt.addr, tt.match, ok)
+		}
+	}
+	ok, err := m.Match(context.Background(), req)
+	if err != nil || ok {
+		t.Fatalf("expected no match without client address, got %v, %v", ok, err)
+	}
+}
+
+func TestClientMatcherInvalidCIDR(t *testing.T) {
+	if _, err := newClientMatcher("bad", []string{"300.1.1.0/24"}); err == nil {
+		t.Fatalf("expected error for invalid cidr")
+	}
+}
+
+func TestClientMatcherInline(t *testing.T) {
+	m, err := matcher.BuildExpressionMatcher("client(10.0.0.0/8, 192.168.0.0/16)", nil)
+	if err != nil {
+		t.Fatalf("build expression error: %v", err)
+	}
+	req := new(dns.Msg)
+	req.SetQuestion("example.com.", dns.TypeA)
+	ctx := clientip.With(context.Background(), netip.MustParseAddr("10.1.2.3"))
+	ok, err := m.Match(ctx, req)
+	if err != nil || !ok {
+		t.Fatalf("expected match, got %v, %v", ok, err)
+	}
+}
+
+func TestClientMatcherRuleSets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clients.yaml")
+	content := "payload:\n  - SRC-IP-CIDR,10.0.0.0/8\n  - IP-CIDR,1.0.0.0/8\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write rule-set: %v", err)
+	}
+	m, err := createClientMatcher("ruleset", map[string]interface{}{"rulesets": []string{path}})
+	if err != nil {
+		t.Fatalf("createClientMatcher error: %v", err)
+	}
+	req := new(dns.Msg)
+	req.SetQuestion("example.com.", dns.TypeA)
+	// destination entries describe answer addresses and must not match clients
+	for addr, match := range map[string]bool{"10.1.2.3": true, "1.2.3.4": false} {
+		ctx := clientip.With(context.Background(), netip.MustParseAddr(addr))
+		ok, err := m.Match(ctx, req)
+		if err != nil || ok != match {
+			t.Fatalf("%s: expected %v got %v, %v", addr, match, ok, err)
+		}
+	}
+}
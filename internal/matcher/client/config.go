@@ -0,0 +1,7 @@
+package matcher
+
+type config struct {
+	CIDRs    []string `json:"cidrs"`
+	Files    []string `json:"files"`
+	RuleSets []string `json:"rulesets"`
+}
@@ -0,0 +1,143 @@
+package matcher
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CodeSpecs converts code specs as decoded from config into strings, entries may
+// be plain numbers (28) or strings ("AAAA", "64-65", "!HTTPS").
+func CodeSpecs(items []interface{}) ([]string, error) {
+	rs := make([]string, 0, len(items))
+	for _, item := range items {
+		switch v := item.(type) {
+		case string:
+			rs = append(rs, v)
+		case int:
+			rs = append(rs, strconv.Itoa(v))
+		case int64:
+			rs = append(rs, strconv.FormatInt(v, 10))
+		case uint64:
+			rs = append(rs, strconv.FormatUint(v, 10))
+		case uint16:
+			rs = append(rs, strconv.FormatUint(uint64(v), 10))
+		case float64:
+			if v != float64(int64(v)) {
+				return nil, fmt.Errorf("invalid code:%v, should be an integer or a name", v)
+			}
+			rs = append(rs, strconv.FormatInt(int64(v), 10))
+		default:
+			return nil, fmt.Errorf("invalid code:%v, should be an integer or a name", item)
+		}
+	}
+	return rs, nil
+}
+
+// CodeNames resolves mnemonics of a code space such as qtype or qclass.
+type CodeNames struct {
+	Kind    string            // used in error messages, e.g. "qtype"
+	Names   map[string]uint16 // upper case mnemonic to code, e.g. dns.StringToType
+	Generic string            // RFC 3597 generic prefix, e.g. "TYPE" for TYPE65534
+}
+
+func (n *CodeNames) parse(in string) (uint16, error) {
+	in = strings.ToUpper(strings.TrimSpace(in))
+	if in == "" {
+		return 0, fmt.Errorf("empty %s", n.Kind)
+	}
+	if code, ok := n.Names[in]; ok {
+		return code, nil
+	}
+	num := in
+	if n.Generic != "" && strings.HasPrefix(in, n.Generic) {
+		num = in[len(n.Generic):]
+	}
+	code, err := strconv.ParseUint(num, 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("unknown %s:%s", n.Kind, in)
+	}
+	return uint16(code), nil
+}
+
+type codeRange struct {
+	lo, hi uint16
+}
+
+func (r codeRange) contains(code uint16) bool {
+	return code >= r.lo && code <= r.hi
+}
+
+// CodeSet is a set of dns codes built from specs like "A", "28", "64-65" and
+// "!HTTPS". Negated specs are removed from the set, a set made only of negated
+// specs contains every other code.
+type CodeSet struct {
+	include []codeRange
+	exclude []codeRange
+}
+
+// ParseCodeSet parses specs with names, unknown names are rejected.
+func ParseCodeSet(specs []string, names *CodeNames) (*CodeSet, error) {
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("no %s specified", names.Kind)
+	}
+	s := &CodeSet{}
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		negate := strings.HasPrefix(spec, "!")
+		if negate {
+			spec = strings.TrimSpace(spec[1:])
+		}
+		r, err := parseCodeRange(spec, names)
+		if err != nil {
+			return nil, err
+		}
+		if negate {
+			s.exclude = append(s.exclude, r)
+			continue
+		}
+		s.include = append(s.include, r)
+	}
+	return s, nil
+}
+
+func parseCodeRange(spec string, names *CodeNames) (codeRange, error) {
+	// mnemonics such as NSAP-PTR contain '-', so try the whole spec first
+	code, err := names.parse(spec)
+	if err == nil {
+		return codeRange{lo: code, hi: code}, nil
+	}
+	idx := strings.IndexByte(spec, '-')
+	if idx < 0 {
+		return codeRange{}, err
+	}
+	lo, err := names.parse(spec[:idx])
+	if err != nil {
+		return codeRange{}, err
+	}
+	hi, err := names.parse(spec[idx+1:])
+	if err != nil {
+		return codeRange{}, err
+	}
+	if lo > hi {
+		return codeRange{}, fmt.Errorf("invalid %s range:%s", names.Kind, spec)
+	}
+	return codeRange{lo: lo, hi: hi}, nil
+}
+
+func (s *CodeSet) Contains(code uint16) bool {
+	for _, r := range s.exclude {
+		if r.contains(code) {
+			return false
+		}
+	}
+	if len(s.include) == 0 {
+		return true
+	}
+	for _, r := range s.include {
+		if r.contains(code) {
+			return true
+		}
+	}
+	return false
+}
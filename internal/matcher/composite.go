@@ -0,0 +1,131 @@
+package matcher
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/xxxsen/common/utils"
+)
+
+// CompositeType is the matcher type whose data is an expression over other named matchers.
+const CompositeType = "composite"
+
+// CompositeDef is a composite matcher as defined in config.
+type CompositeDef struct {
+	Name string
+	Expr string
+}
+
+type compositeConfig struct {
+	Expr string `json:"expr"`
+}
+
+// ParseCompositeDef reads the data of a composite matcher, either the expression
+// itself or a map with an expr field.
+func ParseCompositeDef(name string, data interface{}) (CompositeDef, error) {
+	expr, ok := data.(string)
+	if !ok {
+		c := &compositeConfig{}
+		if err := utils.ConvStructJson(data, c); err != nil {
+			return CompositeDef{}, fmt.Errorf("parse composite matcher failed, name:%s, err:%w", name, err)
+		}
+		expr = c.Expr
+	}
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return CompositeDef{}, fmt.Errorf("composite matcher %s has empty expression", name)
+	}
+	return CompositeDef{Name: name, Expr: expr}, nil
+}
+
+type compositeMatcher struct {
+	name string
+	expr IDNSMatcher
+}
+
+func (c *compositeMatcher) Name() string {
+	return c.name
+}
+
+func (c *compositeMatcher) Type() string {
+	return CompositeType
+}
+
+func (c *compositeMatcher) Match(ctx context.Context, req *dns.Msg) (bool, error) {
+	return c.expr.Match(ctx, req)
+}
+
+// BuildComposites compiles composite matchers into registry. Composites may
+// refer to each other in any order, they are built in dependency order and a
+// reference cycle is reported as an error.
+func BuildComposites(defs []CompositeDef, registry map[string]IDNSMatcher) error {
+	pending := make(map[string]CompositeDef, len(defs))
+	for _, def := range defs {
+		if _, ok := registry[def.Name]; ok {
+			return fmt.Errorf("composite matcher %s conflicts with an existing matcher", def.Name)
+		}
+		if _, ok := pending[def.Name]; ok {
+			return fmt.Errorf("composite matcher %s defined more than once", def.Name)
+		}
+		pending[def.Name] = def
+	}
+	visiting := make(map[string]bool, len(defs))
+	var build func(name string, chain []string) error
+	build = func(name string, chain []string) error {
+		def := pending[name]
+		chain = append(chain, name)
+		if visiting[name] {
+			return fmt.Errorf("composite matcher cycle: %s", strings.Join(chain, " -> "))
+		}
+		visiting[name] = true
+		defer delete(visiting, name)
+		refs, err := expressionRefs(def.Expr)
+		if err != nil {
+			return fmt.Errorf("parse composite matcher %s failed, err:%w", name, err)
+		}
+		for _, ref := range refs {
+			if _, ok := registry[ref]; ok {
+				continue
+			}
+			if _, ok := pending[ref]; !ok {
+				continue //left to BuildExpressionMatcher to report
+			}
+			if err := build(ref, chain); err != nil {
+				return err
+			}
+		}
+		expr, err := BuildExpressionMatcher(def.Expr, registry)
+		if err != nil {
+			return fmt.Errorf("build composite matcher %s failed, err:%w", name, err)
+		}
+		registry[name] = &compositeMatcher{name: name, expr: expr}
+		delete(pending, name)
+		return nil
+	}
+	for _, def := range defs {
+		if _, ok := pending[def.Name]; !ok {
+			continue
+		}
+		if err := build(def.Name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expressionRefs returns the named matchers an expression refers to.
+func expressionRefs(expr string) ([]string, error) {
+	tokens, err := tokenizeExpression(expr)
+	if err != nil {
+		return nil, err
+	}
+	var refs []string
+	for _, tk := range tokens {
+		if tk.typ == tokenIdentifier {
+			refs = append(refs, tk.value)
+		}
+	}
+	return refs, nil
+}
@@ -0,0 +1,83 @@
+package matcher
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestBuildComposites(t *testing.T) {
+	registry := map[string]IDNSMatcher{
+		"ads":      fakeMatcher{name: "ads", result: false},
+		"trackers": fakeMatcher{name: "trackers", result: false},
+		"malware":  fakeMatcher{name: "malware", result: true},
+		"allow":    fakeMatcher{name: "allow", result: false},
+	}
+	// block refers to risky which is defined later
+	defs := []CompositeDef{
+		{Name: "block", Expr: "ads or trackers or risky"},
+		{Name: "risky", Expr: "malware and not allow"},
+		{Name: "strict", Expr: "block && !ads"},
+	}
+	if err := BuildComposites(defs, registry); err != nil {
+		t.Fatalf("BuildComposites error: %v", err)
+	}
+	for _, name := range []string{"block", "risky", "strict"} {
+		m, ok := registry[name]
+		if !ok {
+			t.Fatalf("composite %s not registered", name)
+		}
+		if m.Name() != name || m.Type() != CompositeType {
+			t.Fatalf("unexpected name/type %s/%s", m.Name(), m.Type())
+		}
+		ok, err := m.Match(context.Background(), &dns.Msg{})
+		if err != nil || !ok {
+			t.Fatalf("%s: expected match, ok:%v err:%v", name, ok, err)
+		}
+	}
+	expr, err := BuildExpressionMatcher("strict and not allow", registry)
+	if err != nil {
+		t.Fatalf("composite should be usable in rule expressions: %v", err)
+	}
+	if ok, _ := expr.Match(context.Background(), &dns.Msg{}); !ok {
+		t.Fatalf("expected match")
+	}
+}
+
+func TestBuildCompositesErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		defs []CompositeDef
+		msg  string
+	}{
+		{"cycle", []CompositeDef{{"a", "b || base"}, {"b", "c"}, {"c", "!a"}}, "a -> b -> c -> a"},
+		{"self", []CompositeDef{{"a", "a && base"}}, "a -> a"},
+		{"unknown", []CompositeDef{{"a", "base && missing"}}, "missing"},
+		{"conflict", []CompositeDef{{"base", "any"}}, "conflicts"},
+		{"duplicate", []CompositeDef{{"a", "base"}, {"a", "base"}}, "more than once"},
+		{"syntax", []CompositeDef{{"a", "(base"}}, "build composite matcher a"},
+	}
+	for _, tt := range tests {
+		registry := map[string]IDNSMatcher{"base": fakeMatcher{name: "base", result: true}}
+		err := BuildComposites(tt.defs, registry)
+		if err == nil || !strings.Contains(err.Error(), tt.msg) {
+			t.Fatalf("%s: expected error containing %q, got %v", tt.name, tt.msg, err)
+		}
+	}
+}
+
+func TestParseCompositeDef(t *testing.T) {
+	def, err := ParseCompositeDef("c", " ads or trackers ")
+	if err != nil || def.Expr != "ads or trackers" {
+		t.Fatalf("unexpected def %+v, err:%v", def, err)
+	}
+	def, err = ParseCompositeDef("c", map[string]interface{}{"expr": "ads"})
+	if err != nil || def.Expr != "ads" {
+		t.Fatalf("unexpected def %+v, err:%v", def, err)
+	}
+	if _, err := ParseCompositeDef("c", map[string]interface{}{}); err == nil {
+		t.Fatalf("expected error for empty expression")
+	}
+}
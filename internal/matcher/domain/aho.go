@@ -3,7 +3,10 @@ package matcher
 type ahoNode struct {
 	children map[byte]*ahoNode
 	fail     *ahoNode
+	dict     *ahoNode // nearest node along the fail chain where a pattern ends
 	output   bool
+	term     bool
+	id       int
 }
 
 type ahoMatcher struct {
@@ -20,9 +23,10 @@ func newAhoNode() *ahoNode {
 	return &ahoNode{children: make(map[byte]*ahoNode)}
 }
 
-func (a *ahoMatcher) add(pattern string) {
+// add inserts pattern and returns its id, adding an existing pattern returns the original id.
+func (a *ahoMatcher) add(pattern string) int {
 	if pattern == "" {
-		return
+		return -1
 	}
 	node := a.root
 	for i := 0; i < len(pattern); i++ {
@@ -34,11 +38,14 @@ func (a *ahoMatcher) add(pattern string) {
 		}
 		node = child
 	}
-	if !node.output {
+	if !node.term {
 		node.output = true
+		node.term = true
+		node.id = a.patterns
 		a.patterns++
 	}
 	a.constructed = false
+	return node.id
 }
 
 func (a *ahoMatcher) build() {
@@ -64,8 +71,14 @@ func (a *ahoMatcher) build() {
 			} else {
 				child.fail = fail.children[ch]
 			}
-			if child.fail != nil && child.fail.output {
+			if child.fail != nil && child.fail.output && !child.output {
 				child.output = true
+				child.id = child.fail.id
+			}
+			if child.fail.term {
+				child.dict = child.fail
+			} else {
+				child.dict = child.fail.dict
 			}
 			queue = append(queue, child)
 		}
@@ -74,8 +87,14 @@ func (a *ahoMatcher) build() {
 }
 
 func (a *ahoMatcher) match(text string) bool {
+	_, ok := a.find(text)
+	return ok
+}
+
+// find returns the id of the first pattern found in text.
+func (a *ahoMatcher) find(text string) (int, bool) {
 	if a.patterns == 0 {
-		return false
+		return 0, false
 	}
 	if !a.constructed {
 		a.build()
@@ -92,8 +111,38 @@ func (a *ahoMatcher) match(text string) bool {
 			node = a.root
 		}
 		if node.output {
-			return true
+			return node.id, true
+		}
+	}
+	return 0, false
+}
+
+// findAll calls fn with the id of every pattern occurrence in text.
+func (a *ahoMatcher) findAll(text string, fn func(id int)) {
+	if a.patterns == 0 {
+		return
+	}
+	if !a.constructed {
+		a.build()
+	}
+	node := a.root
+	for i := 0; i < len(text); i++ {
+		ch := text[i]
+		for node != a.root && node.children[ch] == nil {
+			node = node.fail
+		}
+		if next := node.children[ch]; next != nil {
+			node = next
+		} else {
+			node = a.root
+		}
+		if !node.output {
+			continue
+		}
+		for out := node; out != nil; out = out.dict {
+			if out.term {
+				fn(out.id)
+			}
 		}
 	}
-	return false
 }
@@ -36,3 +36,33 @@ func TestAhoMatcherEmpty(t *testing.T) {
 		t.Fatal("expected empty matcher not to match")
 	}
 }
+
+func TestAhoMatcherFindID(t *testing.T) {
+	m := newAhoMatcher()
+	foo := m.add("foo")
+	bar := m.add("bar")
+	if dup := m.add("foo"); dup != foo {
+		t.Fatalf("expected duplicate pattern to keep id %d, got %d", foo, dup)
+	}
+	m.add("xbar")
+	m.build()
+	if id, ok := m.find("aabarcc"); !ok || id != bar {
+		t.Fatalf("expected bar id %d, got %d (%v)", bar, id, ok)
+	}
+	if id, ok := m.find("zzfoo"); !ok || id != foo {
+		t.Fatalf("expected foo id %d, got %d (%v)", foo, id, ok)
+	}
+}
+
+func TestAhoMatcherFindAll(t *testing.T) {
+	m := newAhoMatcher()
+	he := m.add("he")
+	she := m.add("she")
+	hers := m.add("hers")
+	m.build()
+	got := map[int]int{}
+	m.findAll("ushers", func(id int) { got[id]++ })
+	if got[he] != 1 || got[she] != 1 || got[hers] != 1 || len(got) != 3 {
+		t.Fatalf("unexpected matches: %v", got)
+	}
+}
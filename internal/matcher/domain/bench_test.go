@@ -0,0 +1,122 @@
+package matcher
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+)
+
+const benchDomainCount = 200000
+
+var benchTLDs = []string{"com", "net", "org", "cn", "io", "co.uk"}
+
+func benchDomains(n int) []string {
+	rs := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		rs = append(rs, fmt.Sprintf("host%d.site%d.%s", i%7, i, benchTLDs[i%len(benchTLDs)]))
+	}
+	return rs
+}
+
+func benchQueries(domains []string) []string {
+	qs := make([]string, 0, 1024)
+	for i := 0; i < 512; i++ {
+		qs = append(qs, "www."+domains[(i*7919)%len(domains)])                                // hit
+		qs = append(qs, fmt.Sprintf("www.miss%d.example.%s", i, benchTLDs[i%len(benchTLDs)])) // miss
+	}
+	return qs
+}
+
+// heapDelta reports the live heap retained by the value returned from build.
+func heapDelta(b *testing.B, build func() interface{}) {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	v := build()
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(v)
+	b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc), "heap-bytes")
+}
+
+func BenchmarkDomainMemoryTrie(b *testing.B) {
+	domains := benchDomains(benchDomainCount)
+	for i := 0; i < b.N; i++ {
+		heapDelta(b, func() interface{} {
+			t := newDomainTrie()
+			for _, d := range domains {
+				t.add(d)
+			}
+			return t
+		})
+	}
+}
+
+func BenchmarkDomainMemoryIndex(b *testing.B) {
+	domains := benchDomains(benchDomainCount)
+	for i := 0; i < b.N; i++ {
+		heapDelta(b, func() interface{} {
+			ib := &indexBuilder{}
+			for _, d := range domains {
+				ib.add(d, 0)
+			}
+			return ib.build()
+		})
+	}
+}
+
+func BenchmarkDomainMemoryAho(b *testing.B) {
+	domains := benchDomains(benchDomainCount)
+	for i := 0; i < b.N; i++ {
+		heapDelta(b, func() interface{} {
+			a := newAhoMatcher()
+			for _, d := range domains {
+				a.add(d)
+			}
+			a.build()
+			return a
+		})
+	}
+}
+
+func BenchmarkDomainLookupTrie(b *testing.B) {
+	domains := benchDomains(benchDomainCount)
+	t := newDomainTrie()
+	for _, d := range domains {
+		t.add(d)
+	}
+	qs := benchQueries(domains)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		t.matchSuffix(qs[i%len(qs)])
+	}
+}
+
+func BenchmarkDomainLookupIndex(b *testing.B) {
+	domains := benchDomains(benchDomainCount)
+	ib := &indexBuilder{}
+	for _, d := range domains {
+		ib.add(d, 0)
+	}
+	idx := ib.build()
+	qs := benchQueries(domains)
+	var buf [256]byte
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		idx.lookupSuffix(reverseLabels(buf[:0], qs[i%len(qs)]))
+	}
+}
+
+func BenchmarkDomainLookupAho(b *testing.B) {
+	domains := benchDomains(benchDomainCount)
+	a := newAhoMatcher()
+	for _, d := range domains {
+		a.add(d)
+	}
+	a.build()
+	qs := benchQueries(domains)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		a.match(qs[i%len(qs)])
+	}
+}
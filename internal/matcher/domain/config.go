@@ -1,6 +1,7 @@
 package matcher
 
 type config struct {
-	Domains []string `json:"domains"`
-	Files   []string `json:"files"`
+	Domains  []string `json:"domains"`
+	Files    []string `json:"files"`
+	RuleSets []string `json:"rulesets"`
 }
@@ -9,16 +9,66 @@ import (
 	"strings"
 
 	"github.com/miekg/dns"
+	"github.com/xxxsen/atlas/internal/data/psl"
+	"github.com/xxxsen/atlas/internal/data/ruleset"
+	"github.com/xxxsen/atlas/internal/idn"
 	"github.com/xxxsen/atlas/internal/matcher"
+	"github.com/xxxsen/common/logutil"
 	"github.com/xxxsen/common/utils"
+	"go.uber.org/zap"
 )
 
+// Rule is a parsed domain rule together with where it was loaded from.
+type Rule struct {
+	Kind   string
+	Value  string
+	Source string
+}
+
+func (r *Rule) String() string {
+	if r.Source == "" {
+		return r.Kind + ":" + r.Value
+	}
+	return r.Kind + ":" + r.Value + " (" + r.Source + ")"
+}
+
+// ParseRule parses a "kind:value" rule, a rule without kind is treated as suffix.
+func ParseRule(in string, source string) (Rule, error) {
+	if len(in) == 0 {
+		return Rule{}, fmt.Errorf("nil domain found")
+	}
+	kind, data := extractKindData(in)
+	if len(data) == 0 {
+		return Rule{}, fmt.Errorf("invalid rule:%s", in)
+	}
+	return Rule{Kind: kind, Value: data, Source: source}, nil
+}
+
+func extractKindData(in string) (string, string) {
+	if idx := strings.IndexByte(in, ':'); idx >= 0 {
+		kind := strings.ToLower(strings.TrimSpace(in[:idx]))
+		data := strings.TrimSpace(in[idx+1:])
+		return kind, data
+	}
+	return "suffix", strings.TrimSpace(in)
+}
+
+type regexpRule struct {
+	exp  *regexp.Regexp
+	rule *Rule
+}
+
 type domainMatcher struct {
-	name   string
-	full   *domainTrie
-	suffix *domainTrie
-	kw     *ahoMatcher
-	reg    []*regexp.Regexp
+	name     string
+	typ      string
+	full     *domainIndex
+	suffix   *domainIndex
+	etld1    *domainIndex
+	wildcard *wildcardTrie
+	kw       *ahoMatcher
+	kwRules  []*Rule
+	reg      *regexpSet
+	sources  []string
 }
 
 func (d *domainMatcher) Name() string {
@@ -26,102 +76,248 @@ func (d *domainMatcher) Name() string {
 }
 
 func (d *domainMatcher) Type() string {
-	return "domain"
+	return d.typ
 }
 
 func (d *domainMatcher) Match(ctx context.Context, req *dns.Msg) (bool, error) {
 	name := strings.ToLower(matcher.NormalizeDomain(req.Question[0].Name))
-	if d.full.matchExact(name) {
-		return true, nil
+	rule, ok := d.lookup(name)
+	if !ok {
+		return false, nil
+	}
+	matcher.ReportEvidence(ctx, d.name, d.Type(), rule.String())
+	return true, nil
+}
+
+func (d *domainMatcher) lookup(name string) (*Rule, bool) {
+	if name == "" {
+		return nil, false
 	}
-	if d.suffix.matchSuffix(name) {
-		return true, nil
+	var buf [256]byte
+	rev := reverseLabels(buf[:0], name)
+	if pos, ok := d.full.lookupExact(rev); ok {
+		return d.indexRule("full", d.full, pos), true
 	}
-	if d.kw.match(name) {
-		return true, nil
+	if pos, ok := d.suffix.lookupSuffix(rev); ok {
+		return d.indexRule("suffix", d.suffix, pos), true
 	}
-	for _, reg := range d.reg {
-		if reg.MatchString(name) {
-			return true, nil
+	if d.etld1.size() > 0 {
+		if list := psl.Current(); list != nil {
+			if etld1, ok := list.ETLDPlusOne(name); ok {
+				if pos, ok := d.etld1.lookupExact(reverseLabels(buf[:0], etld1)); ok {
+					return d.indexRule("etld1", d.etld1, pos), true
+				}
+			}
 		}
 	}
-	return false, nil
+	if rule, ok := d.wildcard.lookup(name); ok {
+		return rule, true
+	}
+	if id, ok := d.kw.find(name); ok {
+		return d.kwRules[id], true
+	}
+	return d.reg.match(name)
 }
 
-func (d *domainMatcher) extractKindData(in string) (string, string) {
-	if idx := strings.IndexByte(in, ':'); idx >= 0 {
-		kind := strings.ToLower(strings.TrimSpace(in[:idx]))
-		data := strings.TrimSpace(in[idx+1:])
-		return kind, data
+// indexRule rebuilds the rule of an index entry, only done on a hit so the
+// index itself does not need to keep rule objects around.
+func (d *domainMatcher) indexRule(kind string, idx *domainIndex, pos int) *Rule {
+	return &Rule{Kind: kind, Value: idx.domain(pos), Source: d.sources[idx.sources[pos]]}
+}
+
+// Builder accumulates domain rules and compiles them into an immutable matcher.
+type Builder struct {
+	name      string
+	typ       string
+	full      indexBuilder
+	suffix    indexBuilder
+	etld1     indexBuilder
+	wildcard  *wildcardTrie
+	kw        *ahoMatcher
+	kwRules   []*Rule
+	reg       []regexpRule
+	regSeen   map[string]struct{}
+	sources   []string
+	sourceIDs map[string]uint32
+}
+
+// NewBuilder creates an empty builder for a matcher called name, typ is the
+// matcher type reported by the built matcher and its evidence.
+func NewBuilder(name string, typ string) *Builder {
+	return &Builder{
+		name:      name,
+		typ:       typ,
+		wildcard:  newWildcardTrie(),
+		kw:        newAhoMatcher(),
+		regSeen:   make(map[string]struct{}),
+		sources:   []string{""},
+		sourceIDs: map[string]uint32{"": 0},
 	}
-	return "suffix", strings.TrimSpace(in)
 }
 
-func (d *domainMatcher) init(drs []string) error {
-	for _, dr := range drs {
-		if len(dr) == 0 {
-			return fmt.Errorf("nil domain found")
-		}
-		kind, data := d.extractKindData(dr)
-		if len(data) == 0 {
-			return fmt.Errorf("invalid rule:%s", dr)
-		}
-		normalized := strings.ToLower(matcher.NormalizeDomain(data))
-		switch kind {
-		case "suffix":
-			d.suffix.add(normalized)
-		case "keyword":
-			d.kw.add(strings.ToLower(data))
-		case "full":
-			d.full.add(normalized)
-		case "regexp":
-			exp, err := regexp.Compile(data)
-			if err != nil {
-				return err
-			}
-			d.reg = append(d.reg, exp)
-		default:
-			return fmt.Errorf("unknow domain rule kind:%s", kind)
+func (b *Builder) sourceID(source string) uint32 {
+	if id, ok := b.sourceIDs[source]; ok {
+		return id
+	}
+	id := uint32(len(b.sources))
+	b.sources = append(b.sources, source)
+	b.sourceIDs[source] = id
+	return id
+}
+
+// Add appends a single rule, the first rule added wins when the same pattern shows up twice.
+func (b *Builder) Add(kind string, value string, source string) error {
+	var normalized string
+	switch kind {
+	case "suffix", "full", "wildcard", "etld1":
+		ascii, err := idn.ToASCII(matcher.NormalizeDomain(strings.TrimSpace(value)))
+		if err != nil {
+			return err
 		}
+		normalized = ascii
+	}
+	switch kind {
+	case "suffix":
+		b.suffix.add(normalized, b.sourceID(source))
+	case "full":
+		b.full.add(normalized, b.sourceID(source))
+	case "etld1":
+		list := psl.Current()
+		if list == nil {
+			return fmt.Errorf("etld1 rule requires psl.file to be configured")
+		}
+		if etld1, ok := list.ETLDPlusOne(normalized); !ok || etld1 != normalized {
+			return fmt.Errorf("etld1 rule %s is not a registrable domain", value)
+		}
+		b.etld1.add(normalized, b.sourceID(source))
+	case "keyword":
+		id := b.kw.add(strings.ToLower(value))
+		if id == len(b.kwRules) {
+			b.kwRules = append(b.kwRules, &Rule{Kind: kind, Value: value, Source: source})
+		}
+	case "wildcard":
+		if err := b.wildcard.addRule(normalized, &Rule{Kind: kind, Value: value, Source: source}); err != nil {
+			return err
+		}
+	case "regexp":
+		if _, ok := b.regSeen[value]; ok {
+			return nil
+		}
+		exp, err := regexp.Compile(value)
+		if err != nil {
+			return err
+		}
+		b.regSeen[value] = struct{}{}
+		b.reg = append(b.reg, regexpRule{exp: exp, rule: &Rule{Kind: kind, Value: value, Source: source}})
+	default:
+		return fmt.Errorf("unknow domain rule kind:%s", kind)
 	}
 	return nil
 }
 
-func newDomainMatcher(name string, drs []string) (matcher.IDNSMatcher, error) {
+// Build compiles the collected rules, the builder must not be reused afterwards.
+func (b *Builder) Build() (matcher.IDNSMatcher, error) {
 	d := &domainMatcher{
-		name:   name,
-		full:   newDomainTrie(),
-		suffix: newDomainTrie(),
-		kw:     newAhoMatcher(),
-	}
-	if err := d.init(drs); err != nil {
-		return nil, err
+		name:     b.name,
+		typ:      b.typ,
+		full:     b.full.build(),
+		suffix:   b.suffix.build(),
+		etld1:    b.etld1.build(),
+		wildcard: b.wildcard,
+		kw:       b.kw,
+		kwRules:  b.kwRules,
+		reg:      newRegexpSet(b.reg),
+		sources:  b.sources,
 	}
 	d.kw.build()
 	return d, nil
 }
 
+// NewMatcher creates a domain matcher from parsed rules.
+func NewMatcher(name string, rules []Rule) (matcher.IDNSMatcher, error) {
+	b := NewBuilder(name, "domain")
+	for _, rule := range rules {
+		if err := b.Add(rule.Kind, rule.Value, rule.Source); err != nil {
+			return nil, err
+		}
+	}
+	return b.Build()
+}
+
+func newDomainMatcher(name string, drs []string) (matcher.IDNSMatcher, error) {
+	rules, err := parseRules(drs, "")
+	if err != nil {
+		return nil, err
+	}
+	return NewMatcher(name, rules)
+}
+
+func parseRules(drs []string, source string) ([]Rule, error) {
+	rules := make([]Rule, 0, len(drs))
+	for _, dr := range drs {
+		rule, err := ParseRule(dr, source)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
 func createDomainMatcher(name string, args interface{}) (matcher.IDNSMatcher, error) {
 	c := &config{}
 	if err := utils.ConvStructJson(args, c); err != nil {
 		return nil, err
 	}
-	domains := make([]string, 0, len(c.Domains))
-	domains = append(domains, c.Domains...)
-	fileDomains, err := loadDomainFiles(c.Files)
-	if err != nil {
-		return nil, err
-	}
-	domains = append(domains, fileDomains...)
-	return newDomainMatcher(name, domains)
+	watchFiles := append(append([]string(nil), c.Files...), c.RuleSets...)
+	return matcher.NewReloadableMatcher(name, "domain", watchFiles, func() (matcher.IDNSMatcher, error) {
+		rules, err := parseRules(c.Domains, "")
+		if err != nil {
+			return nil, err
+		}
+		fileRules, err := loadDomainFiles(c.Files)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, fileRules...)
+		setRules, err := loadRuleSets(name, c.RuleSets)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, setRules...)
+		return NewMatcher(name, rules)
+	})
 }
 
 func init() {
 	matcher.Register("domain", createDomainMatcher)
+	matcher.RegisterInline("domain", func(args []string) (interface{}, error) {
+		return map[string]interface{}{"domains": args}, nil
+	})
 }
 
-func loadDomainFiles(files []string) ([]string, error) {
-	var domains []string
+// loadRuleSets converts the domain entries of Clash and sing-box rule-sets.
+func loadRuleSets(name string, files []string) ([]Rule, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+	rs, err := ruleset.LoadFiles(files)
+	if err != nil {
+		return nil, err
+	}
+	if rs.Skipped > 0 {
+		logutil.GetLogger(context.Background()).Warn("rule-set entries without dns equivalent skipped",
+			zap.String("matcher", name), zap.Int("count", rs.Skipped))
+	}
+	rules := make([]Rule, 0, len(rs.Domains))
+	for _, d := range rs.Domains {
+		rules = append(rules, Rule{Kind: d.Kind, Value: d.Value, Source: d.Source})
+	}
+	return rules, nil
+}
+
+func loadDomainFiles(files []string) ([]Rule, error) {
+	var rules []Rule
 	for _, path := range files {
 		path = strings.TrimSpace(path)
 		if path == "" {
@@ -132,12 +328,19 @@ func loadDomainFiles(files []string) ([]string, error) {
 			return nil, fmt.Errorf("open domain file %s: %w", path, err)
 		}
 		scanner := bufio.NewScanner(f)
+		lineNum := 0
 		for scanner.Scan() {
+			lineNum++
 			line := strings.TrimSpace(scanner.Text())
 			if line == "" || strings.HasPrefix(line, "#") {
 				continue
 			}
-			domains = append(domains, line)
+			rule, err := ParseRule(line, fmt.Sprintf("%s:%d", path, lineNum))
+			if err != nil {
+				f.Close()
+				return nil, fmt.Errorf("parse domain file %s:%d: %w", path, lineNum, err)
+			}
+			rules = append(rules, rule)
 		}
 		if err := scanner.Err(); err != nil {
 			f.Close()
@@ -147,5 +350,5 @@ func loadDomainFiles(files []string) ([]string, error) {
 			return nil, fmt.Errorf("close domain file %s: %w", path, err)
 		}
 	}
-	return domains, nil
+	return rules, nil
 }
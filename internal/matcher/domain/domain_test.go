@@ -2,9 +2,15 @@ package matcher
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/miekg/dns"
+	"github.com/xxxsen/atlas/internal/data/psl"
+	"github.com/xxxsen/atlas/internal/matcher"
+	"github.com/xxxsen/atlas/internal/watcher"
 )
 
 func TestDomainMatcherDefaultSuffix(t *testing.T) {
@@ -13,6 +19,7 @@ func TestDomainMatcherDefaultSuffix(t *testing.T) {
 		"suffix:sub.domain", // explicit suffix
 		"full:exact.match",  // full match
 		"keyword:needle",    // keyword
+		"wildcard:*.cdn.wild.com",
 	})
 	if err != nil {
 		t.Fatalf("newDomainMatcher error: %v", err)
@@ -27,6 +34,8 @@ func TestDomainMatcherDefaultSuffix(t *testing.T) {
 		{"explicit suffix", "deep.sub.domain.", true},
 		{"full match positive", "exact.match.", true},
 		{"keyword positive", "with-needle-inside.com.", true},
+		{"wildcard positive", "img.cdn.wild.com.", true},
+		{"wildcard depth mismatch", "a.img.cdn.wild.com.", false},
 		{"negative case", "otherdomain.com.", false},
 	}
 
@@ -42,3 +51,196 @@ func TestDomainMatcherDefaultSuffix(t *testing.T) {
 		}
 	}
 }
+
+func TestDomainMatcherReloadFiles(t *testing.T) {
+	if err := watcher.Configure(watcher.Options{Enable: true, Debounce: 50 * time.Millisecond}); err != nil {
+		t.Fatalf("configure watcher: %v", err)
+	}
+	defer watcher.Close()
+
+	path := filepath.Join(t.TempDir(), "domains.txt")
+	if err := os.WriteFile(path, []byte("full:old.example\n"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	m, err := createDomainMatcher("reload", map[string]interface{}{
+		"files": []string{path},
+	})
+	if err != nil {
+		t.Fatalf("createDomainMatcher error: %v", err)
+	}
+	match := func(domain string) bool {
+		req := new(dns.Msg)
+		req.SetQuestion(domain, dns.TypeA)
+		ok, err := m.Match(context.Background(), req)
+		if err != nil {
+			t.Fatalf("match error: %v", err)
+		}
+		return ok
+	}
+	if !match("old.example.") {
+		t.Fatalf("expected initial rule to match")
+	}
+
+	if err := os.WriteFile(path, []byte("full:new.example\n"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	if match("old.example.") || !match("new.example.") {
+		t.Fatalf("expected rules to be reloaded")
+	}
+
+	if err := os.WriteFile(path, []byte("badkind:broken\n"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	if !match("new.example.") {
+		t.Fatalf("expected previous rules to be kept after parse error")
+	}
+}
+
+func TestDomainMatcherEvidence(t *testing.T) {
+	m, err := NewMatcher("evidence", []Rule{
+		{Kind: "suffix", Value: "example.com", Source: "geosite:test@ads"},
+		{Kind: "full", Value: "exact.match"},
+		{Kind: "keyword", Value: "needle"},
+		{Kind: "wildcard", Value: "api-*.wild.com"},
+		{Kind: "regexp", Value: `^ad[0-9]+\.`},
+	})
+	if err != nil {
+		t.Fatalf("NewMatcher error: %v", err)
+	}
+	tests := []struct {
+		domain   string
+		evidence string
+	}{
+		{"www.example.com.", "evidence(domain)=suffix:example.com (geosite:test@ads)"},
+		{"exact.match.", "evidence(domain)=full:exact.match"},
+		{"haystack-needle.org.", "evidence(domain)=keyword:needle"},
+		{"api-v2.wild.com.", "evidence(domain)=wildcard:api-*.wild.com"},
+		{"ad12.tracker.net.", `evidence(domain)=regexp:^ad[0-9]+\.`},
+	}
+	for _, tc := range tests {
+		ctx := matcher.WithEvidence(context.Background())
+		req := new(dns.Msg)
+		req.SetQuestion(tc.domain, dns.TypeA)
+		ok, err := m.Match(ctx, req)
+		if err != nil || !ok {
+			t.Fatalf("%s: expected match, ok:%v err:%v", tc.domain, ok, err)
+		}
+		got := matcher.EvidenceStrings(ctx)
+		if len(got) != 1 || got[0] != tc.evidence {
+			t.Fatalf("%s: expected evidence %q, got %v", tc.domain, tc.evidence, got)
+		}
+	}
+}
+
+func TestDomainMatcherRuleSets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "proxy.yaml")
+	content := "payload:\n  - DOMAIN-SUFFIX,example.com\n  - DOMAIN,exact.example.org\n  - '.wild.example.net'\n  - PROCESS-NAME,curl\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write rule-set: %v", err)
+	}
+	m, err := createDomainMatcher("ruleset", map[string]interface{}{"rulesets": []string{path}})
+	if err != nil {
+		t.Fatalf("createDomainMatcher error: %v", err)
+	}
+	tests := []struct {
+		domain string
+		match  bool
+	}{
+		{"www.example.com.", true},
+		{"exact.example.org.", true},
+		{"www.exact.example.org.", false},
+		{"a.b.wild.example.net.", true},
+		{"wild.example.net.", false},
+	}
+	for _, tc := range tests {
+		ctx := matcher.WithEvidence(context.Background())
+		req := new(dns.Msg)
+		req.SetQuestion(tc.domain, dns.TypeA)
+		ok, err := m.Match(ctx, req)
+		if err != nil || ok != tc.match {
+			t.Fatalf("%s: expected %v, ok:%v err:%v", tc.domain, tc.match, ok, err)
+		}
+	}
+	ctx := matcher.WithEvidence(context.Background())
+	req := new(dns.Msg)
+	req.SetQuestion("www.example.com.", dns.TypeA)
+	if _, err := m.Match(ctx, req); err != nil {
+		t.Fatalf("match error: %v", err)
+	}
+	if got := matcher.EvidenceStrings(ctx); len(got) != 1 || got[0] != "ruleset(domain)=suffix:example.com ("+path+":2)" {
+		t.Fatalf("unexpected evidence:%v", got)
+	}
+}
+
+func TestDomainMatcherUnicodeRules(t *testing.T) {
+	m, err := newDomainMatcher("idn", []string{"例子.中国", "full:WWW.Bücher.de", "wildcard:*.пример.рф"})
+	if err != nil {
+		t.Fatalf("newDomainMatcher error: %v", err)
+	}
+	tests := []struct {
+		domain string
+		match  bool
+	}{
+		{"xn--fsqu00a.xn--fiqs8s.", true},
+		{"www.XN--FSQU00A.xn--fiqs8s.", true},
+		{"www.xn--bcher-kva.de.", true},
+		{"xn--bcher-kva.de.", false},
+		{"api.xn--e1afmkfd.xn--p1ai.", true},
+	}
+	for _, tc := range tests {
+		req := new(dns.Msg)
+		req.SetQuestion(tc.domain, dns.TypeA)
+		ok, err := m.Match(context.Background(), req)
+		if err != nil || ok != tc.match {
+			t.Fatalf("%s: expected %v, ok:%v err:%v", tc.domain, tc.match, ok, err)
+		}
+	}
+	if _, err := newDomainMatcher("idn", []string{"full:a‍.example"}); err == nil {
+		t.Fatalf("expected error for invalid idn rule")
+	}
+}
+
+func TestDomainMatcherETLD1Rule(t *testing.T) {
+	if _, err := newDomainMatcher("nopsl", []string{"etld1:example.co.uk"}); err == nil {
+		t.Fatalf("expected error without psl")
+	}
+	path := filepath.Join(t.TempDir(), "public_suffix_list.dat")
+	if err := os.WriteFile(path, []byte("uk\nco.uk\nio\ngithub.io\n"), 0o644); err != nil {
+		t.Fatalf("write psl: %v", err)
+	}
+	if err := psl.Configure(path); err != nil {
+		t.Fatalf("configure psl: %v", err)
+	}
+	defer psl.Configure("") //nolint:errcheck
+	m, err := newDomainMatcher("etld1", []string{"etld1:example.co.uk", "etld1:foo.github.io"})
+	if err != nil {
+		t.Fatalf("newDomainMatcher error: %v", err)
+	}
+	tests := []struct {
+		domain string
+		match  bool
+	}{
+		{"www.example.co.uk.", true},
+		{"example.co.uk.", true},
+		{"example.uk.", false},
+		{"a.b.foo.github.io.", true},
+		{"bar.github.io.", false},
+	}
+	for _, tc := range tests {
+		ctx := matcher.WithEvidence(context.Background())
+		req := new(dns.Msg)
+		req.SetQuestion(tc.domain, dns.TypeA)
+		ok, err := m.Match(ctx, req)
+		if err != nil || ok != tc.match {
+			t.Fatalf("%s: expected %v, ok:%v err:%v", tc.domain, tc.match, ok, err)
+		}
+		if ok && matcher.EvidenceStrings(ctx)[0] == "" {
+			t.Fatalf("%s: expected evidence", tc.domain)
+		}
+	}
+	if _, err := newDomainMatcher("etld1", []string{"etld1:github.io"}); err == nil {
+		t.Fatalf("expected error for public suffix")
+	}
+}
@@ -0,0 +1,169 @@
+package matcher
+
+import (
+	"bytes"
+	"sort"
+)
+
+// domainIndex is an immutable, sorted array of reversed domains ("com.example.www")
+// packed into a single byte slice, plus an open addressing hash table over the
+// entries. Compared with a map based label trie it keeps no per-label maps or
+// pointers, one entry costs its key bytes plus about 16 bytes of bookkeeping.
+type domainIndex struct {
+	data    []byte
+	offsets []uint32
+	sources []uint32
+	table   []uint32 // entry position + 1, 0 marks an empty slot
+	mask    uint32
+}
+
+type indexEntry struct {
+	key    string
+	source uint32
+}
+
+type indexBuilder struct {
+	entries []indexEntry
+}
+
+func (b *indexBuilder) add(domain string, source uint32) {
+	if domain == "" {
+		return
+	}
+	b.entries = append(b.entries, indexEntry{key: string(reverseLabels(nil, domain)), source: source})
+}
+
+func (b *indexBuilder) build() *domainIndex {
+	sort.SliceStable(b.entries, func(i, j int) bool {
+		return b.entries[i].key < b.entries[j].key
+	})
+	size := 0
+	count := 0
+	for i, e := range b.entries {
+		if i > 0 && e.key == b.entries[i-1].key {
+			continue
+		}
+		size += len(e.key)
+		count++
+	}
+	idx := &domainIndex{
+		data:    make([]byte, 0, size),
+		offsets: make([]uint32, 0, count+1),
+		sources: make([]uint32, 0, count),
+	}
+	for i, e := range b.entries {
+		if i > 0 && e.key == b.entries[i-1].key { //keep the first rule added for duplicated keys
+			continue
+		}
+		idx.offsets = append(idx.offsets, uint32(len(idx.data)))
+		idx.data = append(idx.data, e.key...)
+		idx.sources = append(idx.sources, e.source)
+	}
+	idx.offsets = append(idx.offsets, uint32(len(idx.data)))
+	b.entries = nil
+	idx.buildTable()
+	return idx
+}
+
+func (x *domainIndex) buildTable() {
+	n := x.size()
+	if n == 0 {
+		return
+	}
+	slots := 1
+	for slots < n*2 {
+		slots <<= 1
+	}
+	x.table = make([]uint32, slots)
+	x.mask = uint32(slots - 1)
+	for i := 0; i < n; i++ {
+		h := hashBytes(hashSeed, x.key(i))
+		for {
+			slot := h & x.mask
+			if x.table[slot] == 0 {
+				x.table[slot] = uint32(i + 1)
+				break
+			}
+			h++
+		}
+	}
+}
+
+const (
+	hashSeed  uint32 = 2166136261
+	hashPrime uint32 = 16777619
+)
+
+// hashBytes continues an FNV-1a hash, so the hash of every prefix can be computed in one pass.
+func hashBytes(h uint32, data []byte) uint32 {
+	for _, c := range data {
+		h ^= uint32(c)
+		h *= hashPrime
+	}
+	return h
+}
+
+func (x *domainIndex) probe(h uint32, rev []byte) (int, bool) {
+	for {
+		slot := x.table[h&x.mask]
+		if slot == 0 {
+			return 0, false
+		}
+		if pos := int(slot - 1); bytes.Equal(x.key(pos), rev) {
+			return pos, true
+		}
+		h++
+	}
+}
+
+func (x *domainIndex) size() int {
+	return len(x.sources)
+}
+
+func (x *domainIndex) key(i int) []byte {
+	return x.data[x.offsets[i]:x.offsets[i+1]]
+}
+
+// lookupExact expects a domain already reversed with reverseLabels.
+func (x *domainIndex) lookupExact(rev []byte) (int, bool) {
+	if len(rev) == 0 || x.size() == 0 {
+		return 0, false
+	}
+	return x.probe(hashBytes(hashSeed, rev), rev)
+}
+
+// lookupSuffix returns the shortest stored suffix of the reversed domain.
+func (x *domainIndex) lookupSuffix(rev []byte) (int, bool) {
+	if len(rev) == 0 || x.size() == 0 {
+		return 0, false
+	}
+	h := hashSeed
+	for i := 0; i < len(rev); i++ {
+		if rev[i] == '.' {
+			if pos, ok := x.probe(h, rev[:i]); ok {
+				return pos, true
+			}
+		}
+		h = (h ^ uint32(rev[i])) * hashPrime
+	}
+	return x.probe(h, rev)
+}
+
+// domain returns the stored entry in its normal label order.
+func (x *domainIndex) domain(i int) string {
+	return string(reverseLabels(nil, string(x.key(i))))
+}
+
+// reverseLabels appends the labels of domain in reverse order to dst.
+func reverseLabels(dst []byte, domain string) []byte {
+	end := len(domain)
+	for i := len(domain) - 1; i >= 0; i-- {
+		if domain[i] != '.' {
+			continue
+		}
+		dst = append(dst, domain[i+1:end]...)
+		dst = append(dst, '.')
+		end = i
+	}
+	return append(dst, domain[:end]...)
+}
@@ -0,0 +1,97 @@
+package matcher
+
+import "testing"
+
+func buildTestIndex(domains ...string) *domainIndex {
+	b := &indexBuilder{}
+	for i, d := range domains {
+		b.add(d, uint32(i))
+	}
+	return b.build()
+}
+
+func TestDomainIndexLookupSuffix(t *testing.T) {
+	idx := buildTestIndex("example.com", "co.uk", "sub.domain.org")
+
+	tests := []struct {
+		name    string
+		domain  string
+		matched bool
+	}{
+		{"exact match", "example.com", true},
+		{"subdomain match", "www.example.com", true},
+		{"partial mismatch", "example.co", false},
+		{"label boundary", "notexample.com", false},
+		{"multi-label suffix", "service.co.uk", true},
+		{"suffix only", "co.uk", true},
+		{"deep suffix exact", "sub.domain.org", true},
+		{"deep suffix subdomain", "deep.sub.domain.org", true},
+		{"non matching", "wrongdomain.org", false},
+	}
+	for _, tt := range tests {
+		_, got := idx.lookupSuffix(reverseLabels(nil, tt.domain))
+		if got != tt.matched {
+			t.Errorf("%s: lookupSuffix(%s) = %t, want %t", tt.name, tt.domain, got, tt.matched)
+		}
+	}
+}
+
+func TestDomainIndexLookupExact(t *testing.T) {
+	idx := buildTestIndex("example.com", "sub.domain.org")
+
+	tests := []struct {
+		domain  string
+		matched bool
+	}{
+		{"example.com", true},
+		{"www.example.com", false},
+		{"sub.domain.org", true},
+		{"deep.sub.domain.org", false},
+		{"other.org", false},
+	}
+	for _, tt := range tests {
+		if _, got := idx.lookupExact(reverseLabels(nil, tt.domain)); got != tt.matched {
+			t.Errorf("lookupExact(%s) = %t, want %t", tt.domain, got, tt.matched)
+		}
+	}
+}
+
+func TestDomainIndexDuplicateKeepsFirst(t *testing.T) {
+	idx := buildTestIndex("b.com", "a.com", "b.com")
+	if idx.size() != 2 {
+		t.Fatalf("expected 2 entries, got %d", idx.size())
+	}
+	pos, ok := idx.lookupExact(reverseLabels(nil, "b.com"))
+	if !ok {
+		t.Fatalf("expected b.com to be found")
+	}
+	if idx.sources[pos] != 0 {
+		t.Fatalf("expected first source to be kept, got %d", idx.sources[pos])
+	}
+	if got := idx.domain(pos); got != "b.com" {
+		t.Fatalf("expected domain b.com, got %s", got)
+	}
+}
+
+func TestDomainIndexEmpty(t *testing.T) {
+	idx := buildTestIndex()
+	if _, ok := idx.lookupSuffix(reverseLabels(nil, "example.com")); ok {
+		t.Fatal("expected empty index not to match suffix")
+	}
+	if _, ok := idx.lookupExact(reverseLabels(nil, "example.com")); ok {
+		t.Fatal("expected empty index not to match exact")
+	}
+}
+
+func TestReverseLabels(t *testing.T) {
+	tests := map[string]string{
+		"www.example.com": "com.example.www",
+		"com":             "com",
+		"a.b":             "b.a",
+	}
+	for in, want := range tests {
+		if got := string(reverseLabels(nil, in)); got != want {
+			t.Errorf("reverseLabels(%s) = %s, want %s", in, got, want)
+		}
+	}
+}
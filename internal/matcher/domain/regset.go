@@ -0,0 +1,150 @@
+package matcher
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"sort"
+)
+
+// minLiteralLen is the shortest literal worth using as a prefilter,
+// shorter ones appear in nearly every name.
+const minLiteralLen = 2
+
+// regexpSet matches a name against many regexps. Every regexp is indexed by the
+// literals it requires (at least one of them must appear in a matching name), the
+// literals are searched with a single Aho-Corasick pass and only regexps whose
+// literal showed up are executed. Regexps without usable literals are always run.
+type regexpSet struct {
+	rules    []regexpRule
+	lits     *ahoMatcher
+	litRules [][]int
+	always   []int
+}
+
+func newRegexpSet(rules []regexpRule) *regexpSet {
+	s := &regexpSet{rules: rules, lits: newAhoMatcher()}
+	for i, r := range rules {
+		lits := requiredLiterals(r.exp)
+		if len(lits) == 0 {
+			s.always = append(s.always, i)
+			continue
+		}
+		for _, lit := range lits {
+			id := s.lits.add(lit)
+			for id >= len(s.litRules) {
+				s.litRules = append(s.litRules, nil)
+			}
+			s.litRules[id] = append(s.litRules[id], i)
+		}
+	}
+	s.lits.build()
+	return s
+}
+
+// match returns the first rule, in insertion order, whose regexp matches name.
+func (s *regexpSet) match(name string) (*Rule, bool) {
+	if len(s.rules) == 0 {
+		return nil, false
+	}
+	var buf [16]int
+	cands := append(buf[:0], s.always...)
+	s.lits.findAll(name, func(id int) {
+		cands = append(cands, s.litRules[id]...)
+	})
+	if len(cands) == 0 {
+		return nil, false
+	}
+	sort.Ints(cands)
+	last := -1
+	for _, idx := range cands {
+		if idx == last {
+			continue
+		}
+		last = idx
+		if s.rules[idx].exp.MatchString(name) {
+			return s.rules[idx].rule, true
+		}
+	}
+	return nil, false
+}
+
+// requiredLiterals returns a set of literals of which at least one appears in
+// every string matched by exp, or nil if no such set could be derived.
+func requiredLiterals(exp *regexp.Regexp) []string {
+	re, err := syntax.Parse(exp.String(), syntax.Perl)
+	if err != nil {
+		return nil
+	}
+	lits := literalSet(re.Simplify())
+	for _, lit := range lits {
+		if len(lit) < minLiteralLen {
+			return nil
+		}
+	}
+	return lits
+}
+
+func literalSet(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase != 0 {
+			return nil
+		}
+		return []string{string(re.Rune)}
+	case syntax.OpCapture, syntax.OpPlus:
+		return literalSet(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min < 1 {
+			return nil
+		}
+		return literalSet(re.Sub[0])
+	case syntax.OpAlternate:
+		var rs []string
+		for _, sub := range re.Sub {
+			lits := literalSet(sub)
+			if len(lits) == 0 {
+				return nil
+			}
+			rs = append(rs, lits...)
+		}
+		return rs
+	case syntax.OpConcat:
+		var best []string
+		run := ""
+		pick := func(lits []string) {
+			if shortest(lits) > shortest(best) {
+				best = lits
+			}
+		}
+		for _, sub := range re.Sub {
+			if sub.Op == syntax.OpLiteral && sub.Flags&syntax.FoldCase == 0 {
+				run += string(sub.Rune)
+				continue
+			}
+			if run != "" {
+				pick([]string{run})
+				run = ""
+			}
+			pick(literalSet(sub))
+		}
+		if run != "" {
+			pick([]string{run})
+		}
+		return best
+	default:
+		return nil
+	}
+}
+
+func shortest(lits []string) int {
+	if len(lits) == 0 {
+		return 0
+	}
+	n := len(lits[0])
+	for _, lit := range lits[1:] {
+		if len(lit) < n {
+			n = len(lit)
+		}
+	}
+	return n
+}
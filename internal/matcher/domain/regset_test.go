@@ -0,0 +1,100 @@
+package matcher
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"testing"
+)
+
+func TestRequiredLiterals(t *testing.T) {
+	tests := []struct {
+		expr string
+		lits []string
+	}{
+		{`^ad[0-9]+\.example\.com$`, []string{".example.com"}},
+		{`^(.+\.)?(google|youtube)\.com$`, []string{"google", "youtube"}},
+		{`tracker`, []string{"tracker"}},
+		{`(?i)tracker`, nil},
+		{`^[a-z]+$`, nil},
+		{`^(foo|.*)bar$`, []string{"bar"}},
+		{`^(foo|.*)$`, nil},
+		{`x\.y`, []string{"x.y"}},
+		{`a.b`, nil},
+	}
+	for _, tt := range tests {
+		got := requiredLiterals(regexp.MustCompile(tt.expr))
+		sort.Strings(got)
+		sort.Strings(tt.lits)
+		if fmt.Sprint(got) != fmt.Sprint(tt.lits) {
+			t.Errorf("requiredLiterals(%s) = %v, want %v", tt.expr, got, tt.lits)
+		}
+	}
+}
+
+func TestRegexpSetMatchesLikeLinearScan(t *testing.T) {
+	exprs := []string{
+		`^ad[0-9]+\.example\.com$`,
+		`^(.+\.)?(google|youtube)\.com$`,
+		`(?i)TRACKER`,
+		`^[0-9]+\.in-addr\.arpa$`,
+		`metrics?\.`,
+		`^.*$`,
+		`cdn[0-9]{2}`,
+	}
+	names := []string{
+		"ad12.example.com", "ad.example.com", "www.google.com", "youtube.com",
+		"mytracker.net", "1.in-addr.arpa", "metric.foo.org", "metrics.bar.org",
+		"cdn12.edge.net", "cdn1.edge.net", "plain.org", "",
+	}
+	var rules []regexpRule
+	for i, expr := range exprs {
+		rules = append(rules, regexpRule{exp: regexp.MustCompile(expr), rule: &Rule{Kind: "regexp", Value: fmt.Sprint(i)}})
+	}
+	for n := 1; n <= len(rules); n++ {
+		set := newRegexpSet(rules[:n])
+		for _, name := range names {
+			var want *Rule
+			for _, r := range rules[:n] {
+				if r.exp.MatchString(name) {
+					want = r.rule
+					break
+				}
+			}
+			got, ok := set.match(name)
+			if ok != (want != nil) || got != want {
+				t.Fatalf("rules:%d name:%q got %v(%v), want %v", n, name, got, ok, want)
+			}
+		}
+	}
+}
+
+func benchRegexpRules(n int) []regexpRule {
+	rules := make([]regexpRule, 0, n)
+	for i := 0; i < n; i++ {
+		expr := fmt.Sprintf(`^(.+\.)?site%d[a-z]*\.(com|net)$`, i)
+		rules = append(rules, regexpRule{exp: regexp.MustCompile(expr), rule: &Rule{Kind: "regexp", Value: expr}})
+	}
+	return rules
+}
+
+func BenchmarkRegexpMiss(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		rules := benchRegexpRules(n)
+		b.Run(fmt.Sprintf("linear-%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, r := range rules {
+					if r.exp.MatchString("www.nothing-here.example.org") {
+						break
+					}
+				}
+			}
+		})
+		set := newRegexpSet(rules)
+		b.Run(fmt.Sprintf("set-%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				set.match("www.nothing-here.example.org")
+			}
+		})
+	}
+}
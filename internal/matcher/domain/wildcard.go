@@ -0,0 +1,194 @@
+package matcher
+
+import (
+	"fmt"
+	"strings"
+)
+
+// wildcardTrie stores label-aware glob patterns keyed by reversed labels.
+// "*" matches exactly one label, "**" matches one or more labels and a
+// label such as "api-*" matches any single label with that shape.
+type wildcardTrie struct {
+	exact    map[string]*wildcardTrie
+	globs    []wildcardGlob
+	single   *wildcardTrie
+	multi    *wildcardTrie
+	terminal bool
+	rule     *Rule
+}
+
+type wildcardGlob struct {
+	pattern string
+	node    *wildcardTrie
+}
+
+func newWildcardTrie() *wildcardTrie {
+	return &wildcardTrie{}
+}
+
+func (t *wildcardTrie) add(pattern string) error {
+	return t.addRule(pattern, nil)
+}
+
+func (t *wildcardTrie) addRule(pattern string, rule *Rule) error {
+	if pattern == "" {
+		return fmt.Errorf("empty wildcard pattern")
+	}
+	labels := strings.Split(pattern, ".")
+	cur := t
+	for i := len(labels) - 1; i >= 0; i-- {
+		label := labels[i]
+		switch {
+		case label == "":
+			return fmt.Errorf("invalid wildcard pattern:%s, empty label", pattern)
+		case label == "**":
+			if cur.multi == nil {
+				cur.multi = newWildcardTrie()
+			}
+			cur = cur.multi
+		case label == "*":
+			if cur.single == nil {
+				cur.single = newWildcardTrie()
+			}
+			cur = cur.single
+		case strings.Contains(label, "**"):
+			return fmt.Errorf("invalid wildcard pattern:%s, '**' must be a whole label", pattern)
+		case strings.Contains(label, "*"):
+			cur = cur.globChild(label)
+		default:
+			if cur.exact == nil {
+				cur.exact = make(map[string]*wildcardTrie)
+			}
+			child, ok := cur.exact[label]
+			if !ok {
+				child = newWildcardTrie()
+				cur.exact[label] = child
+			}
+			cur = child
+		}
+	}
+	if !cur.terminal {
+		cur.terminal = true
+		cur.rule = rule
+	}
+	return nil
+}
+
+func (t *wildcardTrie) globChild(pattern string) *wildcardTrie {
+	for _, g := range t.globs {
+		if g.pattern == pattern {
+			return g.node
+		}
+	}
+	node := newWildcardTrie()
+	t.globs = append(t.globs, wildcardGlob{pattern: pattern, node: node})
+	return node
+}
+
+func (t *wildcardTrie) empty() bool {
+	return len(t.exact) == 0 && len(t.globs) == 0 && t.single == nil && t.multi == nil && !t.terminal
+}
+
+func (t *wildcardTrie) match(domain string) bool {
+	_, ok := t.lookup(domain)
+	return ok
+}
+
+func (t *wildcardTrie) lookup(domain string) (*Rule, bool) {
+	if domain == "" || t.empty() {
+		return nil, false
+	}
+	m := &wildcardMatch{labels: strings.Split(domain, ".")}
+	return m.match(t, len(m.labels))
+}
+
+// wildcardMatch walks the trie for one domain. "**" makes a node reachable
+// with the same remaining labels along many paths, so states that failed are
+// remembered to keep the walk polynomial.
+type wildcardMatch struct {
+	labels []string
+	failed map[wildcardState]struct{}
+}
+
+type wildcardState struct {
+	node *wildcardTrie
+	n    int
+}
+
+// match consumes labels[:n] from the right end starting at node t.
+func (m *wildcardMatch) match(t *wildcardTrie, n int) (*Rule, bool) {
+	if n == 0 {
+		return t.rule, t.terminal
+	}
+	state := wildcardState{node: t, n: n}
+	if _, ok := m.failed[state]; ok {
+		return nil, false
+	}
+	if rule, ok := m.matchNext(t, n); ok {
+		return rule, true
+	}
+	if t.multi != nil && m.failed == nil {
+		m.failed = make(map[wildcardState]struct{})
+	}
+	if m.failed != nil {
+		m.failed[state] = struct{}{}
+	}
+	return nil, false
+}
+
+func (m *wildcardMatch) matchNext(t *wildcardTrie, n int) (*Rule, bool) {
+	label := m.labels[n-1]
+	if child, ok := t.exact[label]; ok {
+		if rule, ok := m.match(child, n-1); ok {
+			return rule, true
+		}
+	}
+	for _, g := range t.globs {
+		if !matchLabelGlob(g.pattern, label) {
+			continue
+		}
+		if rule, ok := m.match(g.node, n-1); ok {
+			return rule, true
+		}
+	}
+	if t.single != nil {
+		if rule, ok := m.match(t.single, n-1); ok {
+			return rule, true
+		}
+	}
+	if t.multi != nil {
+		for i := n - 1; i >= 0; i-- {
+			if rule, ok := m.match(t.multi, i); ok {
+				return rule, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// matchLabelGlob matches a single label against a pattern where '*' matches any run of characters.
+func matchLabelGlob(pattern, label string) bool {
+	p, l := 0, 0
+	star, mark := -1, 0
+	for l < len(label) {
+		switch {
+		case p < len(pattern) && pattern[p] == '*':
+			star = p
+			mark = l
+			p++
+		case p < len(pattern) && pattern[p] == label[l]:
+			p++
+			l++
+		case star >= 0:
+			p = star + 1
+			mark++
+			l = mark
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
@@ -0,0 +1,80 @@
+package matcher
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWildcardTrieMatch(t *testing.T) {
+	trie := newWildcardTrie()
+	for _, p := range []string{
+		"*.cdn.example.com",
+		"**.example.org",
+		"api-*.example.net",
+		"*-edge-*.svc.local",
+		"a.*.b.test",
+	} {
+		if err := trie.add(p); err != nil {
+			t.Fatalf("add(%s) error: %v", p, err)
+		}
+	}
+
+	tests := []struct {
+		domain  string
+		matched bool
+	}{
+		{"img.cdn.example.com", true},
+		{"a.img.cdn.example.com", false},
+		{"cdn.example.com", false},
+		{"www.example.org", true},
+		{"a.b.c.example.org", true},
+		{"example.org", false},
+		{"api-v1.example.net", true},
+		{"api-.example.net", true},
+		{"web.example.net", false},
+		{"x.api-v1.example.net", false},
+		{"eu-edge-01.svc.local", true},
+		{"edge.svc.local", false},
+		{"a.x.b.test", true},
+		{"a.x.y.b.test", false},
+	}
+	for _, tt := range tests {
+		if got := trie.match(tt.domain); got != tt.matched {
+			t.Errorf("match(%s) = %t, want %t", tt.domain, got, tt.matched)
+		}
+	}
+}
+
+func TestWildcardTrieInvalidPattern(t *testing.T) {
+	trie := newWildcardTrie()
+	for _, p := range []string{"", "a..example.com", "a**.example.com"} {
+		if err := trie.add(p); err == nil {
+			t.Errorf("add(%q) expected error", p)
+		}
+	}
+}
+
+func TestWildcardTrieEmpty(t *testing.T) {
+	if newWildcardTrie().match("example.com") {
+		t.Fatal("expected empty trie not to match")
+	}
+}
+
+func TestWildcardTrieManyMultiLabels(t *testing.T) {
+	trie := newWildcardTrie()
+	if err := trie.add("a.**.**.**.**.**.**.**.**.b.test"); err != nil {
+		t.Fatalf("add error: %v", err)
+	}
+	domain := strings.Repeat("x.", 60) + "b.test"
+	start := time.Now()
+	if trie.match(domain) {
+		t.Fatalf("unexpected match for %s", domain)
+	}
+	if !trie.match("a." + domain) {
+		t.Fatalf("expected match for a.%s", domain)
+	}
+	if cost := time.Since(start); cost > time.Second {
+		t.Fatalf("match took %v", cost)
+	}
+}
@@ -0,0 +1,8 @@
+package matcher
+
+type config struct {
+	Domains       []string `json:"domains"`
+	MaxSubdomains int      `json:"max_subdomains"`
+	Window        int64    `json:"window"`
+	Size          int      `json:"size"`
+}
@@ -0,0 +1,198 @@
+package matcher
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/miekg/dns"
+	"github.com/xxxsen/atlas/internal/data/psl"
+	"github.com/xxxsen/atlas/internal/idn"
+	"github.com/xxxsen/atlas/internal/matcher"
+	"github.com/xxxsen/common/utils"
+)
+
+const (
+	defaultWindow = 86400 // seconds
+	defaultSize   = 10000
+)
+
+// subdomainCounter tracks the distinct names seen below one registrable domain
+// within the current window, it stops growing once the limit is exceeded.
+type subdomainCounter struct {
+	start int64
+	names map[uint64]struct{}
+}
+
+// etld1Matcher works on the registrable domain (eTLD+1) of the query. It can
+// match a list of registrable domains, and/or registrable domains that had more
+// than max_subdomains distinct names queried within the window.
+type etld1Matcher struct {
+	name          string
+	domains       map[string]struct{}
+	maxSubdomains int
+	window        int64
+	now           func() time.Time
+
+	mu       sync.Mutex
+	counters *lru.Cache[string, *subdomainCounter]
+}
+
+func (e *etld1Matcher) Name() string {
+	return e.name
+}
+
+func (e *etld1Matcher) Type() string {
+	return "etld1"
+}
+
+func (e *etld1Matcher) Match(ctx context.Context, req *dns.Msg) (bool, error) {
+	_, etld1, ok := e.registrable(req)
+	if !ok {
+		return false, nil
+	}
+	if e.maxSubdomains == 0 {
+		matcher.ReportEvidence(ctx, e.name, e.Type(), etld1)
+		return true, nil
+	}
+	if e.count(etld1) <= e.maxSubdomains {
+		return false, nil
+	}
+	matcher.ReportEvidence(ctx, e.name, e.Type(), etld1+" subdomains>"+strconv.Itoa(e.maxSubdomains))
+	return true, nil
+}
+
+// registrable returns the query name and its registrable domain when the
+// latter is covered by the matcher.
+func (e *etld1Matcher) registrable(req *dns.Msg) (string, string, bool) {
+	list := psl.Current()
+	if list == nil || len(req.Question) == 0 {
+		return "", "", false
+	}
+	name := strings.ToLower(matcher.NormalizeDomain(req.Question[0].Name))
+	etld1, ok := list.ETLDPlusOne(name)
+	if !ok {
+		return "", "", false
+	}
+	if len(e.domains) > 0 {
+		if _, ok := e.domains[etld1]; !ok {
+			return "", "", false
+		}
+	}
+	return name, etld1, true
+}
+
+// Observe records the query name below its registrable domain. Matchers with
+// max_subdomains are registered as matcher.Observer, so every query is counted
+// whether or not a rule evaluates the matcher.
+func (e *etld1Matcher) Observe(ctx context.Context, req *dns.Msg) {
+	name, etld1, ok := e.registrable(req)
+	if !ok {
+		return
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+	sum := h.Sum64()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c := e.counter(etld1, true)
+	if len(c.names) <= e.maxSubdomains {
+		c.names[sum] = struct{}{}
+	}
+}
+
+// count returns the number of distinct names seen below etld1 in the window.
+func (e *etld1Matcher) count(etld1 string) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c := e.counter(etld1, false)
+	if c == nil {
+		return 0
+	}
+	return len(c.names)
+}
+
+// counter returns the counter of etld1 for the current window, create adds
+// it when missing. e.mu must be held.
+func (e *etld1Matcher) counter(etld1 string, create bool) *subdomainCounter {
+	now := e.now().UnixNano()
+	start := now - now%e.window
+	c, ok := e.counters.Get(etld1)
+	if ok && c.start == start {
+		return c
+	}
+	if !create {
+		return nil
+	}
+	c = &subdomainCounter{start: start, names: make(map[uint64]struct{})}
+	e.counters.Add(etld1, c)
+	return c
+}
+
+func newETLD1Matcher(name string, c *config) (*etld1Matcher, error) {
+	if len(c.Domains) == 0 && c.MaxSubdomains == 0 {
+		return nil, fmt.Errorf("etld1 matcher requires domains or max_subdomains")
+	}
+	if c.MaxSubdomains < 0 || c.Window < 0 || c.Size < 0 {
+		return nil, fmt.Errorf("etld1 max_subdomains, window and size should not be negative")
+	}
+	list := psl.Current()
+	if list == nil {
+		return nil, fmt.Errorf("etld1 matcher requires psl.file to be configured")
+	}
+	domains := make(map[string]struct{}, len(c.Domains))
+	for _, d := range c.Domains {
+		normalized, err := idn.ToASCII(matcher.NormalizeDomain(strings.TrimSpace(d)))
+		if err != nil {
+			return nil, err
+		}
+		if etld1, ok := list.ETLDPlusOne(normalized); !ok || etld1 != normalized {
+			return nil, fmt.Errorf("%s is not a registrable domain", d)
+		}
+		domains[normalized] = struct{}{}
+	}
+	window := c.Window
+	if window == 0 {
+		window = defaultWindow
+	}
+	size := c.Size
+	if size == 0 {
+		size = defaultSize
+	}
+	counters, err := lru.New[string, *subdomainCounter](size)
+	if err != nil {
+		return nil, err
+	}
+	e := &etld1Matcher{
+		name:          name,
+		domains:       domains,
+		maxSubdomains: c.MaxSubdomains,
+		window:        int64(time.Duration(window) * time.Second),
+		now:           time.Now,
+		counters:      counters,
+	}
+	if e.maxSubdomains > 0 {
+		matcher.RegisterObserver(e)
+	}
+	return e, nil
+}
+
+func createETLD1Matcher(name string, args interface{}) (matcher.IDNSMatcher, error) {
+	c := &config{}
+	if err := utils.ConvStructJson(args, c); err != nil {
+		return nil, err
+	}
+	return newETLD1Matcher(name, c)
+}
+
+func init() {
+	matcher.Register("etld1", createETLD1Matcher)
+	matcher.RegisterInline("etld1", func(args []string) (interface{}, error) {
+		return map[string]interface{}{"domains": args}, nil
+	})
+}
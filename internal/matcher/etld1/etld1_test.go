@@ -0,0 +1,144 @@
+package matcher
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/xxxsen/atlas/internal/data/psl"
+	"github.com/xxxsen/atlas/internal/matcher"
+	_ "github.com/xxxsen/atlas/internal/matcher/qtype"
+)
+
+func setupPSL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "public_suffix_list.dat")
+	if err := os.WriteFile(path, []byte("com\nuk\nco.uk\n// ===BEGIN PRIVATE DOMAINS===\ngithub.io\n"), 0o644); err != nil {
+		t.Fatalf("write psl: %v", err)
+	}
+	if err := psl.Configure(path); err != nil {
+		t.Fatalf("configure psl: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = psl.Configure("")
+	})
+}
+
+// query sends a query through the observers the way the server does, then
+// evaluates m on it.
+func query(t *testing.T, m matcher.IDNSMatcher, name string) bool {
+	return queryType(t, m, name, dns.TypeA)
+}
+
+func queryType(t *testing.T, m matcher.IDNSMatcher, name string, qtype uint16) bool {
+	req := new(dns.Msg)
+	req.SetQuestion(dns.Fqdn(name), qtype)
+	matcher.Observe(context.Background(), req)
+	ok, err := m.Match(context.Background(), req)
+	if err != nil {
+		t.Fatalf("match error: %v", err)
+	}
+	return ok
+}
+
+func TestETLD1MatcherDomains(t *testing.T) {
+	setupPSL(t)
+	m, err := newETLD1Matcher("owner", &config{Domains: []string{"example.co.uk", "foo.github.io"}})
+	if err != nil {
+		t.Fatalf("newETLD1Matcher error: %v", err)
+	}
+	tests := []struct {
+		name  string
+		match bool
+	}{
+		{"www.example.co.uk", true},
+		{"example.co.uk", true},
+		{"other.co.uk", false},
+		{"a.foo.github.io", true},
+		{"bar.github.io", false},
+		{"github.io", false},
+	}
+	for _, tt := range tests {
+		if got := query(t, m, tt.name); got != tt.match {
+			t.Fatalf("%s: expected %v got %v", tt.name, tt.match, got)
+		}
+	}
+}
+
+func TestETLD1MatcherMaxSubdomains(t *testing.T) {
+	setupPSL(t)
+	m, err := newETLD1Matcher("tunnel", &config{MaxSubdomains: 3, Window: 60})
+	if err != nil {
+		t.Fatalf("newETLD1Matcher error: %v", err)
+	}
+	now := time.Unix(1700000040, 0)
+	m.now = func() time.Time { return now }
+	for i := 0; i < 3; i++ {
+		if query(t, m, fmt.Sprintf("x%d.data.example.com", i)) {
+			t.Fatalf("subdomain %d should be within the limit", i)
+		}
+	}
+	if query(t, m, "x0.data.example.com") {
+		t.Fatalf("repeated names should not be counted twice")
+	}
+	if !query(t, m, "x3.data.example.com") {
+		t.Fatalf("4th distinct subdomain should exceed the limit")
+	}
+	if query(t, m, "a.other.com") {
+		t.Fatalf("other registrable domains should be counted separately")
+	}
+	now = now.Add(time.Minute)
+	if query(t, m, "x4.data.example.com") {
+		t.Fatalf("counter should reset in a new window")
+	}
+}
+
+func TestETLD1MatcherBehindFailingAnd(t *testing.T) {
+	setupPSL(t)
+	m, err := newETLD1Matcher("tunnel", &config{MaxSubdomains: 2, Window: 60})
+	if err != nil {
+		t.Fatalf("newETLD1Matcher error: %v", err)
+	}
+	expr, err := matcher.BuildExpressionMatcher("qtype(AAAA) && tunnel", map[string]matcher.IDNSMatcher{"tunnel": m})
+	if err != nil {
+		t.Fatalf("build expression error: %v", err)
+	}
+	// the etld1 term is never evaluated for these queries, they are counted anyway
+	for i := 0; i < 3; i++ {
+		if queryType(t, expr, fmt.Sprintf("x%d.data.example.com", i), dns.TypeA) {
+			t.Fatalf("A query %d should not match", i)
+		}
+	}
+	if !queryType(t, expr, "x0.data.example.com", dns.TypeAAAA) {
+		t.Fatalf("expected the names seen behind the failing term to be counted")
+	}
+}
+
+func TestETLD1MatcherNoQuestion(t *testing.T) {
+	setupPSL(t)
+	m, err := newETLD1Matcher("tunnel", &config{Domains: []string{"example.com"}, MaxSubdomains: 1})
+	if err != nil {
+		t.Fatalf("newETLD1Matcher error: %v", err)
+	}
+	req := new(dns.Msg)
+	matcher.Observe(context.Background(), req)
+	if ok, err := m.Match(context.Background(), req); ok || err != nil {
+		t.Fatalf("expected no match without question, ok:%t err:%v", ok, err)
+	}
+}
+
+func TestETLD1MatcherInvalid(t *testing.T) {
+	for _, c := range []*config{{}, {Domains: []string{"co.uk"}}, {Domains: []string{"www.example.com"}}, {MaxSubdomains: -1}} {
+		setupPSL(t)
+		if _, err := newETLD1Matcher("bad", c); err == nil {
+			t.Fatalf("%+v: expected error", c)
+		}
+	}
+	_ = psl.Configure("")
+	if _, err := newETLD1Matcher("nopsl", &config{Domains: []string{"example.com"}}); err == nil {
+		t.Fatalf("expected error without psl")
+	}
+}
@@ -0,0 +1,94 @@
+package matcher
+
+import (
+	"context"
+	"sync"
+)
+
+// Evidence describes the concrete pattern that made a matcher hit.
+type Evidence struct {
+	Matcher string
+	Type    string
+	Pattern string
+}
+
+func (e Evidence) String() string {
+	return e.Matcher + "(" + e.Type + ")=" + e.Pattern
+}
+
+type evidenceKey struct{}
+
+type evidenceCollector struct {
+	mu    sync.Mutex
+	items []Evidence
+}
+
+// WithEvidence attaches an evidence collector to ctx, matchers evaluated with
+// the returned context can report the pattern they matched.
+func WithEvidence(ctx context.Context) context.Context {
+	return context.WithValue(ctx, evidenceKey{}, &evidenceCollector{})
+}
+
+func getCollector(ctx context.Context) *evidenceCollector {
+	c, _ := ctx.Value(evidenceKey{}).(*evidenceCollector)
+	return c
+}
+
+// ReportEvidence records the pattern that matched, it is a no-op when ctx carries no collector.
+func ReportEvidence(ctx context.Context, name string, typ string, pattern string) {
+	c := getCollector(ctx)
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items = append(c.items, Evidence{Matcher: name, Type: typ, Pattern: pattern})
+}
+
+// Evidences returns the evidence collected so far.
+func Evidences(ctx context.Context) []Evidence {
+	c := getCollector(ctx)
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Evidence(nil), c.items...)
+}
+
+// EvidenceStrings returns the collected evidence formatted for logging.
+func EvidenceStrings(ctx context.Context) []string {
+	items := Evidences(ctx)
+	if len(items) == 0 {
+		return nil
+	}
+	rs := make([]string, 0, len(items))
+	for _, item := range items {
+		rs = append(rs, item.String())
+	}
+	return rs
+}
+
+func evidenceMark(ctx context.Context) int {
+	c := getCollector(ctx)
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
+
+// evidenceRollback drops evidence reported after mark, used when a sub expression
+// did not contribute to the final result.
+func evidenceRollback(ctx context.Context, mark int) {
+	c := getCollector(ctx)
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if mark < len(c.items) {
+		c.items = c.items[:mark]
+	}
+}
@@ -10,6 +10,8 @@ import (
 
 // BuildExpressionMatcher compiles a logical expression composed of registered matchers.
 // Supported operators: &&, ||, ! plus their textual counterparts (and, or, not).
+// Besides named matchers, operands may be inline calls such as qtype(AAAA) or
+// domain("suffix:corp.local") which build anonymous matchers of that type.
 func BuildExpressionMatcher(expr string, registry map[string]IDNSMatcher) (IDNSMatcher, error) {
 	expr = strings.TrimSpace(expr)
 	if expr == "" {
@@ -38,45 +40,154 @@ const (
 	tokenNot
 	tokenLParen
 	tokenRParen
+	tokenCall
 )
 
 type token struct {
 	typ   tokenType
 	value string
+	args  []string
+	raw   string
 }
 
 func tokenizeExpression(expr string) ([]token, error) {
 	if expr == "" {
 		return nil, nil
 	}
-	normalised := strings.NewReplacer(
-		"&&", " && ",
-		"||", " || ",
-		"(", " ( ",
-		")", " ) ",
-		"!", " ! ",
-	).Replace(expr)
-	fields := strings.Fields(normalised)
-	tokens := make([]token, 0, len(fields))
-	for _, part := range fields {
-		switch strings.ToLower(part) {
-		case "&&", "and":
+	tokens := make([]token, 0, 8)
+	for i := 0; i < len(expr); {
+		switch {
+		case isSpace(expr[i]):
+			i++
+		case strings.HasPrefix(expr[i:], "&&"):
 			tokens = append(tokens, token{typ: tokenAnd})
-		case "||", "or":
+			i += 2
+		case strings.HasPrefix(expr[i:], "||"):
 			tokens = append(tokens, token{typ: tokenOr})
-		case "!", "not":
+			i += 2
+		case expr[i] == '!':
 			tokens = append(tokens, token{typ: tokenNot})
-		case "(":
+			i++
+		case expr[i] == '(':
 			tokens = append(tokens, token{typ: tokenLParen})
-		case ")":
+			i++
+		case expr[i] == ')':
 			tokens = append(tokens, token{typ: tokenRParen})
+			i++
 		default:
-			tokens = append(tokens, token{typ: tokenIdentifier, value: part})
+			start := i
+			for i < len(expr) && !isDelimiter(expr, i) {
+				i++
+			}
+			word := expr[start:i]
+			switch strings.ToLower(word) {
+			case "and":
+				tokens = append(tokens, token{typ: tokenAnd})
+				continue
+			case "or":
+				tokens = append(tokens, token{typ: tokenOr})
+				continue
+			case "not":
+				tokens = append(tokens, token{typ: tokenNot})
+				continue
+			}
+			next := i
+			for next < len(expr) && isSpace(expr[next]) {
+				next++
+			}
+			if next < len(expr) && expr[next] == '(' { // identifier directly followed by '(' is an inline call
+				args, end, err := scanCallArgs(expr, next+1)
+				if err != nil {
+					return nil, fmt.Errorf("invalid inline matcher %s: %w", word, err)
+				}
+				tokens = append(tokens, token{typ: tokenCall, value: word, args: args, raw: expr[start:end]})
+				i = end
+				continue
+			}
+			tokens = append(tokens, token{typ: tokenIdentifier, value: word})
 		}
 	}
 	return tokens, nil
 }
 
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isDelimiter(expr string, i int) bool {
+	c := expr[i]
+	if isSpace(c) || c == '(' || c == ')' || c == '!' {
+		return true
+	}
+	return strings.HasPrefix(expr[i:], "&&") || strings.HasPrefix(expr[i:], "||")
+}
+
+// scanCallArgs parses comma separated call arguments starting right after '(' and
+// returns them with the position following the closing ')'. Arguments may be
+// quoted with ' or ", inside quotes a backslash only escapes the quote and itself.
+func scanCallArgs(expr string, pos int) ([]string, int, error) {
+	var args []string
+	var cur strings.Builder
+	quoted := false
+	depth := 0
+	flush := func(final bool) error {
+		arg := cur.String()
+		if !quoted {
+			arg = strings.TrimSpace(arg)
+		}
+		if arg == "" && !quoted {
+			if final && len(args) == 0 {
+				return nil
+			}
+			return fmt.Errorf("empty argument")
+		}
+		args = append(args, arg)
+		cur.Reset()
+		quoted = false
+		return nil
+	}
+	for i := pos; i < len(expr); i++ {
+		c := expr[i]
+		switch {
+		case quoted && isSpace(c):
+		case quoted && c != ',' && c != ')':
+			return nil, 0, fmt.Errorf("unexpected character %q after quoted argument", c)
+		case (c == '"' || c == '\'') && strings.TrimSpace(cur.String()) == "": //a quote only opens a quoted argument at its start
+			cur.Reset()
+			end := i + 1
+			for ; end < len(expr) && expr[end] != c; end++ {
+				if expr[end] == '\\' && end+1 < len(expr) && (expr[end+1] == c || expr[end+1] == '\\') {
+					end++
+				}
+				cur.WriteByte(expr[end])
+			}
+			if end >= len(expr) {
+				return nil, 0, fmt.Errorf("unterminated quoted argument")
+			}
+			quoted = true
+			i = end
+		case c == '(':
+			depth++
+			cur.WriteByte(c)
+		case c == ')' && depth > 0:
+			depth--
+			cur.WriteByte(c)
+		case c == ')':
+			if err := flush(true); err != nil {
+				return nil, 0, err
+			}
+			return args, i + 1, nil
+		case c == ',' && depth == 0:
+			if err := flush(false); err != nil {
+				return nil, 0, err
+			}
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	return nil, 0, fmt.Errorf("missing ')'")
+}
+
 type exprNode interface {
 	eval(ctx context.Context, req *dns.Msg) (bool, error)
 }
@@ -111,7 +222,10 @@ type notNode struct {
 }
 
 func (n notNode) eval(ctx context.Context, req *dns.Msg) (bool, error) {
+	mark := evidenceMark(ctx)
 	ok, err := n.child.eval(ctx, req)
+	// evidence of the child only explains why the negation is false
+	evidenceRollback(ctx, mark)
 	if err != nil {
 		return false, err
 	}
@@ -132,6 +246,15 @@ type binaryNode struct {
 }
 
 func (b binaryNode) eval(ctx context.Context, req *dns.Msg) (bool, error) {
+	mark := evidenceMark(ctx)
+	ok, err := b.doEval(ctx, req)
+	if err != nil || !ok {
+		evidenceRollback(ctx, mark)
+	}
+	return ok, err
+}
+
+func (b binaryNode) doEval(ctx context.Context, req *dns.Msg) (bool, error) {
 	switch b.op {
 	case opAnd:
 		leftOK, err := b.left.eval(ctx, req)
@@ -170,6 +293,12 @@ func parseExpression(tokens []token, registry map[string]IDNSMatcher) (exprNode,
 				return nil, fmt.Errorf("matcher %s not found", tk.value)
 			}
 			stack = append(stack, matcherNode{matcher: mt})
+		case tokenCall:
+			mt, err := MakeInlineMatcher(tk.value, tk.raw, tk.args)
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, matcherNode{matcher: mt})
 		case tokenNot:
 			if len(stack) < 1 {
 				return nil, fmt.Errorf("invalid expression: missing operand for NOT")
@@ -207,7 +336,7 @@ func shuntingYard(tokens []token) ([]token, error) {
 	for i := 0; i < len(tokens); i++ {
 		tk := tokens[i]
 		switch tk.typ {
-		case tokenIdentifier:
+		case tokenIdentifier, tokenCall:
 			output = append(output, tk)
 		case tokenNot:
 			for len(ops) > 0 && ops[len(ops)-1].typ != tokenLParen && precedence(ops[len(ops)-1]) > precedence(tk) {
@@ -2,6 +2,7 @@ package matcher
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	"github.com/miekg/dns"
@@ -138,3 +139,141 @@ func TestBuildExpressionMatcherInvalidSyntax(t *testing.T) {
 		t.Fatalf("expected error for invalid syntax")
 	}
 }
+
+type evidenceMatcher struct {
+	name   string
+	result bool
+}
+
+func (e evidenceMatcher) Name() string { return e.name }
+func (e evidenceMatcher) Type() string { return "evidence" }
+func (e evidenceMatcher) Match(ctx context.Context, req *dns.Msg) (bool, error) {
+	if e.result {
+		ReportEvidence(ctx, e.name, e.Type(), "pattern-"+e.name)
+	}
+	return e.result, nil
+}
+
+func TestExpressionMatcherEvidence(t *testing.T) {
+	registry := map[string]IDNSMatcher{
+		"hit":   evidenceMatcher{name: "hit", result: true},
+		"miss":  evidenceMatcher{name: "miss", result: false},
+		"allow": evidenceMatcher{name: "allow", result: true},
+	}
+	tests := []struct {
+		expr     string
+		matched  bool
+		evidence []string
+	}{
+		{"hit", true, []string{"hit(evidence)=pattern-hit"}},
+		{"hit && miss", false, nil},
+		{"miss || hit", true, []string{"hit(evidence)=pattern-hit"}},
+		{"hit && !allow", false, nil},
+		{"hit && !miss", true, []string{"hit(evidence)=pattern-hit"}},
+		{"(hit && miss) || allow", true, []string{"allow(evidence)=pattern-allow"}},
+	}
+	for _, tt := range tests {
+		expr, err := BuildExpressionMatcher(tt.expr, registry)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.expr, err)
+		}
+		ctx := WithEvidence(context.Background())
+		ok, err := expr.Match(ctx, &dns.Msg{})
+		if err != nil {
+			t.Fatalf("%s: match failed: %v", tt.expr, err)
+		}
+		if ok != tt.matched {
+			t.Fatalf("%s: expected %v got %v", tt.expr, tt.matched, ok)
+		}
+		got := EvidenceStrings(ctx)
+		if strings.Join(got, ",") != strings.Join(tt.evidence, ",") {
+			t.Fatalf("%s: expected evidence %v got %v", tt.expr, tt.evidence, got)
+		}
+	}
+}
+
+type argsMatcher struct {
+	name string
+	args []string
+}
+
+func (a *argsMatcher) Name() string { return a.name }
+func (a *argsMatcher) Type() string { return "args" }
+func (a *argsMatcher) Match(ctx context.Context, req *dns.Msg) (bool, error) {
+	return len(a.args) > 0 && a.args[0] == "yes", nil
+}
+
+func init() {
+	Register("args", func(name string, args interface{}) (IDNSMatcher, error) {
+		return &argsMatcher{name: name, args: args.([]string)}, nil
+	})
+	RegisterInline("args", func(args []string) (interface{}, error) {
+		return args, nil
+	})
+}
+
+func TestTokenizeInlineCall(t *testing.T) {
+	tests := []struct {
+		expr string
+		args []string
+	}{
+		{"args()", nil},
+		{"args(a)", []string{"a"}},
+		{"args ( a , b )", []string{"a", "b"}},
+		{`args("a,b", 'c)')`, []string{"a,b", "c)"}},
+		{`args("say \"hi\"", 'x\\y')`, []string{`say "hi"`, `x\y`}},
+		{"args(^a(b|c)$)", []string{"^a(b|c)$"}},
+	}
+	for _, tt := range tests {
+		tokens, err := tokenizeExpression(tt.expr)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.expr, err)
+		}
+		if len(tokens) != 1 || tokens[0].typ != tokenCall || tokens[0].value != "args" {
+			t.Fatalf("%s: expected a single call token, got %+v", tt.expr, tokens)
+		}
+		if strings.Join(tokens[0].args, "|") != strings.Join(tt.args, "|") || len(tokens[0].args) != len(tt.args) {
+			t.Fatalf("%s: expected args %q got %q", tt.expr, tt.args, tokens[0].args)
+		}
+	}
+}
+
+func TestTokenizeInlineCallInvalid(t *testing.T) {
+	for _, expr := range []string{"args(a", "args(a,)", "args(,a)", `args("a)`, `args("a"b)`} {
+		if _, err := tokenizeExpression(expr); err == nil {
+			t.Fatalf("%s: expected error", expr)
+		}
+	}
+}
+
+func TestBuildExpressionMatcherInlineCall(t *testing.T) {
+	registry := map[string]IDNSMatcher{
+		"named": fakeMatcher{name: "named", result: true},
+	}
+	tests := []struct {
+		expr    string
+		matched bool
+	}{
+		{"args(yes)", true},
+		{"args(no)", false},
+		{"named && !args(no)", true},
+		{"(args(no) or args(yes)) and named", true},
+		{"not args(yes)", false},
+	}
+	for _, tt := range tests {
+		expr, err := BuildExpressionMatcher(tt.expr, registry)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.expr, err)
+		}
+		ok, err := expr.Match(context.Background(), &dns.Msg{})
+		if err != nil {
+			t.Fatalf("%s: match failed: %v", tt.expr, err)
+		}
+		if ok != tt.matched {
+			t.Fatalf("%s: expected %v got %v", tt.expr, tt.matched, ok)
+		}
+	}
+	if _, err := BuildExpressionMatcher("nosuchtype(a)", registry); err == nil {
+		t.Fatalf("expected error for unknown inline matcher type")
+	}
+}
@@ -2,23 +2,25 @@ package matcher
 
 import (
 	"fmt"
+	"os"
 	"strings"
 
 	geositeprovider "github.com/xxxsen/atlas/internal/data/geosite"
 	mainmatcher "github.com/xxxsen/atlas/internal/matcher"
+	domainmatcher "github.com/xxxsen/atlas/internal/matcher/domain"
 	"github.com/xxxsen/common/utils"
 )
 
-func domainToRule(d geositeprovider.Domain) (string, bool) {
+func domainKind(d geositeprovider.Domain) (string, bool) {
 	switch d.Type {
 	case geositeprovider.DomainTypePlain:
-		return "keyword:" + d.Value, true
+		return "keyword", true
 	case geositeprovider.DomainTypeRegex:
-		return "regexp:" + d.Value, true
+		return "regexp", true
 	case geositeprovider.DomainTypeDomain:
-		return "suffix:" + d.Value, true
+		return "suffix", true
 	case geositeprovider.DomainTypeFull:
-		return "full:" + d.Value, true
+		return "full", true
 	default:
 		return "", false
 	}
@@ -55,6 +57,17 @@ type listSpec struct {
 	attrNegate bool
 }
 
+func (s listSpec) String() string {
+	out := "geosite:" + s.name
+	if s.attr == "" {
+		return out
+	}
+	if s.attrNegate {
+		return out + "@!" + s.attr
+	}
+	return out + "@" + s.attr
+}
+
 func parseListSpec(spec string) listSpec {
 	parts := strings.SplitN(spec, "@", 2)
 	name := strings.TrimSpace(parts[0])
@@ -104,41 +117,56 @@ func createGeositeMatcher(name string, args interface{}) (mainmatcher.IDNSMatche
 	for name := range uniqueNames {
 		names = append(names, name)
 	}
+	watchFiles := []string{cfg.File}
+	if st, err := os.Stat(cfg.File); err == nil && st.IsDir() {
+		// watch the folder itself, so categories added later are noticed too
+		watchFiles = []string{geositeprovider.SourceDir(cfg.File)}
+	}
+	return mainmatcher.NewReloadableMatcher(name, "geosite", watchFiles, func() (mainmatcher.IDNSMatcher, error) {
+		return buildGeositeMatcher(name, cfg.File, specs, names)
+	})
+}
 
-	categories, err := geositeprovider.GeositeProvider.LoadCategories(cfg.File, names)
+func buildGeositeMatcher(name string, file string, specs []listSpec, names []string) (mainmatcher.IDNSMatcher, error) {
+	categories, err := geositeprovider.GeositeProvider.LoadCategories(file, names)
 	if err != nil {
 		return nil, err
 	}
 
-	var domainRules []string
-	seen := make(map[string]struct{})
+	builder := domainmatcher.NewBuilder(name, "geosite")
+	count := 0
 	for _, spec := range specs {
 		domains, ok := categories[spec.name]
 		if !ok {
 			return nil, fmt.Errorf("geosite category %s not found", spec.name)
 		}
+		source := spec.String()
 		for _, domain := range domains {
 			if !matchesAttribute(domain.Attributes, spec.attr, spec.attrNegate) {
 				continue
 			}
-			if rule, ok := domainToRule(domain); ok {
-				if _, exists := seen[rule]; exists {
-					continue
-				}
-				seen[rule] = struct{}{}
-				domainRules = append(domainRules, rule)
+			kind, ok := domainKind(domain)
+			if !ok {
+				continue
+			}
+			if err := builder.Add(kind, domain.Value, source); err != nil {
+				return nil, fmt.Errorf("add geosite domain failed, category:%s, value:%s, err:%w", spec.name, domain.Value, err)
 			}
+			count++
 		}
 	}
-	if len(domainRules) == 0 {
+	if count == 0 {
 		return nil, fmt.Errorf("geosite matcher produced no domains")
 	}
-	dataMap := map[string]interface{}{
-		"domains": domainRules,
-	}
-	return mainmatcher.MakeMatcher("domain", name, dataMap)
+	return builder.Build()
 }
 
 func init() {
 	mainmatcher.Register("geosite", createGeositeMatcher)
+	mainmatcher.RegisterInline("geosite", func(args []string) (interface{}, error) {
+		if len(args) < 2 {
+			return nil, fmt.Errorf("geosite inline call requires a file and at least one category")
+		}
+		return map[string]interface{}{"file": args[0], "categories": args[1:]}, nil
+	})
 }
@@ -0,0 +1,89 @@
+package matcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/xxxsen/atlas/internal/matcher"
+	"github.com/xxxsen/atlas/internal/watcher"
+)
+
+func TestGeositeMatcherEvidence(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "cn"), []byte("domain:a.cn @ads\n"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	m, err := createGeositeMatcher("cn-sites", map[string]interface{}{
+		"file":       dir,
+		"categories": []string{"cn@ads"},
+	})
+	if err != nil {
+		t.Fatalf("createGeositeMatcher error: %v", err)
+	}
+	ctx := matcher.WithEvidence(context.Background())
+	req := new(dns.Msg)
+	req.SetQuestion("www.a.cn.", dns.TypeA)
+	if ok, err := m.Match(ctx, req); err != nil || !ok {
+		t.Fatalf("expected match, ok:%t err:%v", ok, err)
+	}
+	got := matcher.EvidenceStrings(ctx)
+	if len(got) != 1 || got[0] != "cn-sites(geosite)=suffix:a.cn (geosite:cn@ads)" {
+		t.Fatalf("unexpected evidence %v", got)
+	}
+}
+
+func TestGeositeMatcherReloadNewSourceFile(t *testing.T) {
+	if err := watcher.Configure(watcher.Options{Enable: true, Debounce: 50 * time.Millisecond}); err != nil {
+		t.Fatalf("configure watcher: %v", err)
+	}
+	defer watcher.Close()
+
+	root := t.TempDir()
+	dir := filepath.Join(root, "data")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+	write("cn", "domain:a.cn\n")
+	m, err := createGeositeMatcher("reload", map[string]interface{}{
+		"file":       root,
+		"categories": []string{"cn"},
+	})
+	if err != nil {
+		t.Fatalf("createGeositeMatcher error: %v", err)
+	}
+	match := func(domain string) bool {
+		req := new(dns.Msg)
+		req.SetQuestion(domain, dns.TypeA)
+		ok, err := m.Match(context.Background(), req)
+		if err != nil {
+			t.Fatalf("match error: %v", err)
+		}
+		return ok
+	}
+	if !match("www.a.cn.") {
+		t.Fatalf("expected initial category to match")
+	}
+
+	// the include target does not exist yet, the old rules are kept
+	write("cn", "domain:a.cn\ninclude:extra\n")
+	time.Sleep(300 * time.Millisecond)
+	if !match("www.a.cn.") || match("www.b.cn.") {
+		t.Fatalf("expected old rules to be kept")
+	}
+
+	// a category file created later is noticed as well
+	write("extra", "domain:b.cn\n")
+	time.Sleep(300 * time.Millisecond)
+	if !match("www.b.cn.") {
+		t.Fatalf("expected rebuild after the include target was added")
+	}
+}
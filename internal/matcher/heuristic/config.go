@@ -0,0 +1,6 @@
+package matcher
+
+type config struct {
+	Threshold float64 `json:"threshold"`
+	MinLength int     `json:"min_length"`
+}
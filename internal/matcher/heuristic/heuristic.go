@@ -0,0 +1,204 @@
+package matcher
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/xxxsen/atlas/internal/matcher"
+	"github.com/xxxsen/common/utils"
+)
+
+const (
+	defaultThreshold = 0.5
+	defaultMinLength = 8
+	minTokenLength   = 16
+)
+
+// feature weights, they sum up to 1 so the score stays within [0, 1].
+const (
+	weightEntropy   = 0.20
+	weightConsonant = 0.30
+	weightDigit     = 0.10
+	weightToken     = 0.20
+	weightLength    = 0.10
+	weightLabels    = 0.10
+)
+
+type heuristicMatcher struct {
+	name      string
+	threshold float64
+	minLength int
+}
+
+func (h *heuristicMatcher) Name() string {
+	return h.name
+}
+
+func (h *heuristicMatcher) Type() string {
+	return "heuristic"
+}
+
+func (h *heuristicMatcher) Match(ctx context.Context, req *dns.Msg) (bool, error) {
+	name := strings.ToLower(matcher.NormalizeDomain(req.Question[0].Name))
+	score := h.score(name)
+	if score < h.threshold {
+		return false, nil
+	}
+	matcher.ReportEvidence(ctx, h.name, h.Type(), "score="+strconv.FormatFloat(score, 'f', 2, 64))
+	return true, nil
+}
+
+// score rates how likely name is generated (DGA) or carries tunnelled data.
+// Every label except the top level one is scored on its own and the most
+// suspicious label wins, then the shape of the whole name is added.
+func (h *heuristicMatcher) score(name string) float64 {
+	labels := strings.Split(name, ".")
+	if len(labels) > 1 {
+		labels = labels[:len(labels)-1]
+	}
+	best := 0.0
+	maxLabel := 0
+	for _, label := range labels {
+		if len(label) > maxLabel {
+			maxLabel = len(label)
+		}
+		if len(label) < h.minLength {
+			continue
+		}
+		best = math.Max(best, labelScore(label))
+	}
+	if best == 0 {
+		return 0
+	}
+	score := best
+	score += weightLength * clamp(float64(maxLabel-12)/40)
+	score += weightLabels * clamp(float64(len(labels)-3)/6)
+	return score
+}
+
+func labelScore(label string) float64 {
+	digits, run, maxRun := 0, 0, 0
+	for i := 0; i < len(label); i++ {
+		c := label[i]
+		if c >= '0' && c <= '9' {
+			digits++
+		}
+		switch {
+		case c == '-' || strings.IndexByte("aeiouy", c) >= 0:
+			run = 0
+		default:
+			run++
+			if run > maxRun {
+				maxRun = run
+			}
+		}
+	}
+	// entropy normalised by the highest value a label of this length can reach
+	ent := entropy(label) / math.Log2(math.Min(float64(len(label)), 36))
+	score := weightEntropy * clamp((ent-0.8)/0.2)
+	score += weightConsonant * clamp(float64(maxRun-3)/5)
+	score += weightDigit * clamp(float64(digits)/float64(len(label))/0.3)
+	if isEncodedToken(label) {
+		score += weightToken
+	}
+	return score
+}
+
+// entropy returns the Shannon entropy of s in bits per character.
+func entropy(s string) float64 {
+	var counts [256]int
+	for i := 0; i < len(s); i++ {
+		counts[s[i]]++
+	}
+	n := float64(len(s))
+	rs := 0.0
+	for _, c := range counts {
+		if c == 0 {
+			continue
+		}
+		p := float64(c) / n
+		rs -= p * math.Log2(p)
+	}
+	return rs
+}
+
+// isEncodedToken reports labels that look like hex or base32 encoded data.
+func isEncodedToken(label string) bool {
+	if len(label) < minTokenLength {
+		return false
+	}
+	hex, base32, digits := true, true, 0
+	for i := 0; i < len(label); i++ {
+		c := label[i]
+		isDigit := c >= '0' && c <= '9'
+		if isDigit {
+			digits++
+		}
+		if !isDigit && (c < 'a' || c > 'f') {
+			hex = false
+		}
+		if !(c >= '2' && c <= '7') && (c < 'a' || c > 'z') {
+			base32 = false
+		}
+	}
+	// plain words also fit the base32 alphabet, require some digits as well
+	return hex || (base32 && digits > 0)
+}
+
+func clamp(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
+
+func newHeuristicMatcher(name string, threshold float64, minLength int) (matcher.IDNSMatcher, error) {
+	if threshold == 0 {
+		threshold = defaultThreshold
+	}
+	if threshold < 0 || threshold > 1 {
+		return nil, fmt.Errorf("heuristic matcher threshold should be within (0, 1], got:%v", threshold)
+	}
+	if minLength == 0 {
+		minLength = defaultMinLength
+	}
+	if minLength < 2 {
+		// the entropy of a single character label is normalised by log2(1) == 0
+		return nil, fmt.Errorf("heuristic matcher min_length should be at least 2, got:%d", minLength)
+	}
+	return &heuristicMatcher{name: name, threshold: threshold, minLength: minLength}, nil
+}
+
+func createHeuristicMatcher(name string, args interface{}) (matcher.IDNSMatcher, error) {
+	c := &config{}
+	if err := utils.ConvStructJson(args, c); err != nil {
+		return nil, err
+	}
+	return newHeuristicMatcher(name, c.Threshold, c.MinLength)
+}
+
+func init() {
+	matcher.Register("heuristic", createHeuristicMatcher)
+	matcher.RegisterInline("heuristic", func(args []string) (interface{}, error) {
+		data := map[string]interface{}{}
+		if len(args) > 2 {
+			return nil, fmt.Errorf("heuristic inline call accepts threshold and min_length only")
+		}
+		if len(args) > 0 {
+			threshold, err := strconv.ParseFloat(args[0], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid threshold:%s", args[0])
+			}
+			data["threshold"] = threshold
+		}
+		if len(args) > 1 {
+			minLength, err := strconv.Atoi(args[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid min_length:%s", args[1])
+			}
+			data["min_length"] = minLength
+		}
+		return data, nil
+	})
+}
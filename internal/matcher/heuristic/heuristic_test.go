@@ -0,0 +1,66 @@
+package matcher
+
+import (
+	"context"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestHeuristicMatcher(t *testing.T) {
+	m, err := newHeuristicMatcher("test", 0, 0)
+	if err != nil {
+		t.Fatalf("newHeuristicMatcher error: %v", err)
+	}
+
+	tests := []struct {
+		domain     string
+		suspicious bool
+	}{
+		{"www.google.com.", false},
+		{"mail.example.org.", false},
+		{"images.cdn.wikipedia.org.", false},
+		{"login.microsoftonline.com.", false},
+		{"r3---sn-ab5l6nzr.googlevideo.com.", false},
+		{"d1a2b3c4.cloudfront.net.", false},
+		{"xjw8qk2z9vbn4t7m.com.", true},
+		{"q3k9zx7v1bw8.biz.", true},
+		{"a1b2c3d4e5f60718293a4b5c6d7e8f90.t.example.com.", true},
+		{"mfrggzdfmztwq2lk.nbswy3dp.tunnel.example.net.", true},
+	}
+	for _, tc := range tests {
+		req := new(dns.Msg)
+		req.SetQuestion(tc.domain, dns.TypeA)
+		ok, err := m.Match(context.Background(), req)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.domain, err)
+		}
+		if ok != tc.suspicious {
+			t.Errorf("%s: expected %v, got %v (score %.2f)", tc.domain, tc.suspicious, ok,
+				m.(*heuristicMatcher).score(tc.domain[:len(tc.domain)-1]))
+		}
+	}
+}
+
+func TestHeuristicMatcherInvalidThreshold(t *testing.T) {
+	if _, err := createHeuristicMatcher("bad", map[string]interface{}{"threshold": 1.5}); err == nil {
+		t.Fatalf("expected error for threshold above 1")
+	}
+}
+
+func TestHeuristicMatcherInvalidMinLength(t *testing.T) {
+	for _, minLength := range []int{-1, 1} {
+		if _, err := createHeuristicMatcher("bad", map[string]interface{}{"min_length": minLength}); err == nil {
+			t.Fatalf("expected error for min_length %d", minLength)
+		}
+	}
+	m, err := createHeuristicMatcher("short", map[string]interface{}{"min_length": 2})
+	if err != nil {
+		t.Fatalf("createHeuristicMatcher error: %v", err)
+	}
+	req := new(dns.Msg)
+	req.SetQuestion("a.b.example.com.", dns.TypeA)
+	if ok, err := m.Match(context.Background(), req); err != nil || ok {
+		t.Fatalf("expected short labels not to match, got %v, %v", ok, err)
+	}
+}
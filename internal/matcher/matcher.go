@@ -15,12 +15,35 @@ type IDNSMatcher interface {
 
 type Factory func(name string, args interface{}) (IDNSMatcher, error)
 
+// InlineParser converts the arguments of an inline call inside a rule
+// expression, such as qtype(AAAA,HTTPS), into the args the type's Factory expects.
+type InlineParser func(args []string) (interface{}, error)
+
 var m = make(map[string]Factory)
+var inline = make(map[string]InlineParser)
 
 func Register(typ string, fac Factory) {
 	m[typ] = fac
 }
 
+// RegisterInline enables the inline call syntax for a registered matcher type.
+func RegisterInline(typ string, p InlineParser) {
+	inline[typ] = p
+}
+
+// MakeInlineMatcher creates an anonymous matcher from an inline call.
+func MakeInlineMatcher(typ string, name string, args []string) (IDNSMatcher, error) {
+	p, ok := inline[typ]
+	if !ok {
+		return nil, fmt.Errorf("matcher type:%s does not support inline call", typ)
+	}
+	data, err := p(args)
+	if err != nil {
+		return nil, fmt.Errorf("parse inline matcher args failed, name:%s, err:%w", name, err)
+	}
+	return MakeMatcher(typ, name, data)
+}
+
 func MakeMatcher(typ string, name string, args interface{}) (IDNSMatcher, error) {
 	cr, ok := m[typ]
 	if !ok {
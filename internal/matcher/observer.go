@@ -0,0 +1,37 @@
+package matcher
+
+import (
+	"context"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// Observer sees every query before the rules are evaluated. Matchers that
+// keep statistics register one, so their counts do not depend on whether an
+// expression or an earlier rule skipped them.
+type Observer interface {
+	Observe(ctx context.Context, req *dns.Msg)
+}
+
+var (
+	observerMu sync.RWMutex
+	observers  []Observer
+)
+
+// RegisterObserver adds o to the observers called by Observe.
+func RegisterObserver(o Observer) {
+	observerMu.Lock()
+	defer observerMu.Unlock()
+	observers = append(observers, o)
+}
+
+// Observe passes a query to all registered observers, the server calls it
+// once for every query it receives.
+func Observe(ctx context.Context, req *dns.Msg) {
+	observerMu.RLock()
+	defer observerMu.RUnlock()
+	for _, o := range observers {
+		o.Observe(ctx, req)
+	}
+}
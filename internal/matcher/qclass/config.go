@@ -1,5 +1,5 @@
 package matcher
 
 type config struct {
-	Classes []uint16 `json:"classes"`
+	Classes []interface{} `json:"classes"`
 }
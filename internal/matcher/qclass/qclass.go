@@ -8,9 +8,11 @@ import (
 	"github.com/xxxsen/common/utils"
 )
 
+var classNames = &matcher.CodeNames{Kind: "qclass", Names: dns.StringToClass, Generic: "CLASS"}
+
 type qclassMatcher struct {
 	name    string
-	classes map[uint16]struct{}
+	classes *matcher.CodeSet
 }
 
 func (q *qclassMatcher) Name() string {
@@ -23,19 +25,20 @@ func (q *qclassMatcher) Type() string {
 
 func (q *qclassMatcher) Match(ctx context.Context, req *dns.Msg) (bool, error) {
 	for _, item := range req.Question {
-		if _, ok := q.classes[item.Qclass]; ok {
+		if q.classes.Contains(item.Qclass) {
+			matcher.ReportEvidence(ctx, q.name, q.Type(), dns.Class(item.Qclass).String())
 			return true, nil
 		}
 	}
 	return false, nil
 }
 
-func newQClassMatcher(name string, classes []uint16) (matcher.IDNSMatcher, error) {
-	m := make(map[uint16]struct{}, len(classes))
-	for _, c := range classes {
-		m[c] = struct{}{}
+func newQClassMatcher(name string, classes []string) (matcher.IDNSMatcher, error) {
+	set, err := matcher.ParseCodeSet(classes, classNames)
+	if err != nil {
+		return nil, err
 	}
-	return &qclassMatcher{name: name, classes: m}, nil
+	return &qclassMatcher{name: name, classes: set}, nil
 }
 
 func createQClassMatcher(name string, args interface{}) (matcher.IDNSMatcher, error) {
@@ -43,9 +46,16 @@ func createQClassMatcher(name string, args interface{}) (matcher.IDNSMatcher, er
 	if err := utils.ConvStructJson(args, cfg); err != nil {
 		return nil, err
 	}
-	return newQClassMatcher(name, cfg.Classes)
+	specs, err := matcher.CodeSpecs(cfg.Classes)
+	if err != nil {
+		return nil, err
+	}
+	return newQClassMatcher(name, specs)
 }
 
 func init() {
 	matcher.Register("qclass", createQClassMatcher)
+	matcher.RegisterInline("qclass", func(args []string) (interface{}, error) {
+		return map[string]interface{}{"classes": args}, nil
+	})
 }
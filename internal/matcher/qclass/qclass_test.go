@@ -8,7 +8,7 @@ import (
 )
 
 func TestQClassMatcher(t *testing.T) {
-	m, err := newQClassMatcher("test", []uint16{dns.ClassINET})
+	m, err := newQClassMatcher("test", []string{"IN"})
 	if err != nil {
 		t.Fatalf("newQClassMatcher error: %v", err)
 	}
@@ -33,3 +33,28 @@ func TestQClassMatcher(t *testing.T) {
 		t.Fatalf("expected no match for ClassCHAOS")
 	}
 }
+
+func TestQClassMatcherNames(t *testing.T) {
+	m, err := createQClassMatcher("test", map[string]interface{}{"classes": []interface{}{"ch", 4}})
+	if err != nil {
+		t.Fatalf("create matcher error: %v", err)
+	}
+	req := new(dns.Msg)
+	req.SetQuestion("version.bind.", dns.TypeTXT)
+	for _, tt := range []struct {
+		class uint16
+		match bool
+	}{{dns.ClassCHAOS, true}, {dns.ClassHESIOD, true}, {dns.ClassINET, false}} {
+		req.Question[0].Qclass = tt.class
+		match, err := m.Match(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Match error: %v", err)
+		}
+		if match != tt.match {
+			t.Fatalf("expected %v for class %d, got %v", tt.match, tt.class, match)
+		}
+	}
+	if _, err := createQClassMatcher("test", map[string]interface{}{"classes": []interface{}{"CHAOS"}}); err == nil {
+		t.Fatalf("expected error for unknown class")
+	}
+}
@@ -1,5 +1,5 @@
 package matcher
 
 type config struct {
-	Types []uint16 `json:"types"`
+	Types []interface{} `json:"types"`
 }
@@ -8,9 +8,11 @@ import (
 	"github.com/xxxsen/common/utils"
 )
 
+var typeNames = &matcher.CodeNames{Kind: "qtype", Names: dns.StringToType, Generic: "TYPE"}
+
 type qtypeMatcher struct {
 	name string
-	typs map[uint16]struct{}
+	typs *matcher.CodeSet
 }
 
 func (q *qtypeMatcher) Name() string {
@@ -23,19 +25,20 @@ func (q *qtypeMatcher) Type() string {
 
 func (q *qtypeMatcher) Match(ctx context.Context, req *dns.Msg) (bool, error) {
 	for _, item := range req.Question {
-		if _, ok := q.typs[item.Qtype]; ok {
+		if q.typs.Contains(item.Qtype) {
+			matcher.ReportEvidence(ctx, q.name, q.Type(), dns.Type(item.Qtype).String())
 			return true, nil
 		}
 	}
 	return false, nil
 }
 
-func newQTypeMatcher(name string, typs []uint16) (matcher.IDNSMatcher, error) {
-	t := make(map[uint16]struct{}, len(typs))
-	for _, item := range typs {
-		t[item] = struct{}{}
+func newQTypeMatcher(name string, typs []string) (matcher.IDNSMatcher, error) {
+	set, err := matcher.ParseCodeSet(typs, typeNames)
+	if err != nil {
+		return nil, err
 	}
-	return &qtypeMatcher{typs: t}, nil
+	return &qtypeMatcher{name: name, typs: set}, nil
 }
 
 func createQTypeMatcher(name string, args interface{}) (matcher.IDNSMatcher, error) {
@@ -43,9 +46,16 @@ func createQTypeMatcher(name string, args interface{}) (matcher.IDNSMatcher, err
 	if err := utils.ConvStructJson(args, c); err != nil {
 		return nil, err
 	}
-	return newQTypeMatcher(name, c.Types)
+	specs, err := matcher.CodeSpecs(c.Types)
+	if err != nil {
+		return nil, err
+	}
+	return newQTypeMatcher(name, specs)
 }
 
 func init() {
 	matcher.Register("qtype", createQTypeMatcher)
+	matcher.RegisterInline("qtype", func(args []string) (interface{}, error) {
+		return map[string]interface{}{"types": args}, nil
+	})
 }
@@ -5,10 +5,11 @@ import (
 	"testing"
 
 	"github.com/miekg/dns"
+	"github.com/xxxsen/atlas/internal/matcher"
 )
 
 func TestQTypeMatcher(t *testing.T) {
-	m, err := newQTypeMatcher("test", []uint16{dns.TypeA, dns.TypeAAAA})
+	m, err := newQTypeMatcher("test", []string{"A", "AAAA"})
 	if err != nil {
 		t.Fatalf("newQTypeMatcher error: %v", err)
 	}
@@ -32,3 +33,61 @@ func TestQTypeMatcher(t *testing.T) {
 		t.Fatalf("expected no match for TypeMX")
 	}
 }
+
+func TestQTypeMatcherSpecs(t *testing.T) {
+	tests := []struct {
+		specs []interface{}
+		qtype uint16
+		match bool
+	}{
+		{[]interface{}{28}, dns.TypeAAAA, true},
+		{[]interface{}{"aaaa", "HTTPS"}, dns.TypeHTTPS, true},
+		{[]interface{}{"SVCB-HTTPS"}, dns.TypeSVCB, true},
+		{[]interface{}{"SVCB-HTTPS"}, dns.TypeA, false},
+		{[]interface{}{"NSAP-PTR"}, dns.TypeNSAPPTR, true},
+		{[]interface{}{"TYPE65534"}, 65534, true},
+		{[]interface{}{"!A"}, dns.TypeAAAA, true},
+		{[]interface{}{"!A"}, dns.TypeA, false},
+		{[]interface{}{"1-100", "!MX"}, dns.TypeTXT, true},
+		{[]interface{}{"1-100", "!MX"}, dns.TypeMX, false},
+	}
+	for _, tt := range tests {
+		m, err := createQTypeMatcher("test", map[string]interface{}{"types": tt.specs})
+		if err != nil {
+			t.Fatalf("%v: create matcher error: %v", tt.specs, err)
+		}
+		req := new(dns.Msg)
+		req.SetQuestion("example.com.", tt.qtype)
+		match, err := m.Match(context.Background(), req)
+		if err != nil {
+			t.Fatalf("%v: Match error: %v", tt.specs, err)
+		}
+		if match != tt.match {
+			t.Fatalf("%v: expected %v for %d, got %v", tt.specs, tt.match, tt.qtype, match)
+		}
+	}
+}
+
+func TestQTypeMatcherInvalidSpecs(t *testing.T) {
+	for _, specs := range [][]interface{}{{"AAAAA"}, {"!"}, {"MX-A"}, {"70000"}, {}, {1.5}} {
+		if _, err := createQTypeMatcher("test", map[string]interface{}{"types": specs}); err == nil {
+			t.Fatalf("%v: expected error", specs)
+		}
+	}
+}
+
+func TestQTypeMatcherInline(t *testing.T) {
+	m, err := matcher.BuildExpressionMatcher("qtype(AAAA, HTTPS)", nil)
+	if err != nil {
+		t.Fatalf("build expression error: %v", err)
+	}
+	req := new(dns.Msg)
+	req.SetQuestion("example.com.", dns.TypeHTTPS)
+	match, err := m.Match(context.Background(), req)
+	if err != nil || !match {
+		t.Fatalf("expected match for HTTPS, got %v, %v", match, err)
+	}
+	if _, err := matcher.BuildExpressionMatcher("qtype(AAAAA)", nil); err == nil {
+		t.Fatalf("expected error for unknown qtype")
+	}
+}
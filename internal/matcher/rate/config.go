@@ -0,0 +1,9 @@
+package matcher
+
+type config struct {
+	Window       int64   `json:"window"`
+	Threshold    float64 `json:"threshold"`
+	Key          string  `json:"key"`
+	SuffixLabels int     `json:"suffix_labels"`
+	Size         int     `json:"size"`
+}
@@ -0,0 +1,261 @@
+package matcher
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/miekg/dns"
+	"github.com/xxxsen/atlas/internal/clientip"
+	"github.com/xxxsen/atlas/internal/matcher"
+	"github.com/xxxsen/common/utils"
+)
+
+const (
+	keyClient = "client"
+	keySuffix = "suffix"
+
+	defaultWindow       = 10 // seconds
+	defaultSuffixLabels = 2
+	defaultSize         = 10000
+)
+
+// window is a sliding window counter, the count of the previous fixed window
+// is weighted by how much of it still overlaps the sliding window.
+type window struct {
+	start int64 // start of the current fixed window in nanoseconds
+	prev  float64
+	cur   float64
+}
+
+// add counts a query and returns the rate within the sliding window.
+func (w *window) add(now int64, size int64) float64 {
+	w.roll(now, size)
+	w.cur++
+	return w.rate(now, size)
+}
+
+// peek returns the rate within the sliding window without counting.
+func (w *window) peek(now int64, size int64) float64 {
+	w.roll(now, size)
+	return w.rate(now, size)
+}
+
+func (w *window) roll(now int64, size int64) {
+	switch elapsed := now - w.start; {
+	case elapsed >= 2*size:
+		w.start = now - now%size
+		w.prev, w.cur = 0, 0
+	case elapsed >= size:
+		w.start += size
+		w.prev, w.cur = w.cur, 0
+	}
+}
+
+func (w *window) rate(now int64, size int64) float64 {
+	overlap := 1 - float64(now-w.start)/float64(size)
+	return w.prev*overlap + w.cur
+}
+
+// counterKey is the part of a rate config that decides what is counted, rate
+// matchers that only differ in threshold share one counter.
+type counterKey struct {
+	window       int64
+	key          string
+	suffixLabels int
+	size         int
+}
+
+// counter counts every query the server receives per client, or per client
+// and domain suffix. It is a matcher.Observer, so the counts do not depend on
+// whether a rule actually evaluated a rate matcher.
+type counter struct {
+	counterKey
+	now func() time.Time
+
+	mu      sync.Mutex
+	windows *lru.Cache[string, *window]
+}
+
+var (
+	countersMu sync.Mutex
+	counters   = make(map[counterKey]*counter)
+)
+
+// getCounter returns the counter shared by all rate matchers with key k.
+func getCounter(k counterKey) (*counter, error) {
+	countersMu.Lock()
+	defer countersMu.Unlock()
+	if c, ok := counters[k]; ok {
+		return c, nil
+	}
+	windows, err := lru.New[string, *window](k.size)
+	if err != nil {
+		return nil, err
+	}
+	c := &counter{counterKey: k, now: time.Now, windows: windows}
+	counters[k] = c
+	matcher.RegisterObserver(c)
+	return c, nil
+}
+
+func (c *counter) Observe(ctx context.Context, req *dns.Msg) {
+	if key, ok := c.keyOf(ctx, req); ok {
+		c.add(key)
+	}
+}
+
+func (c *counter) keyOf(ctx context.Context, req *dns.Msg) (string, bool) {
+	addr, ok := clientip.From(ctx)
+	if !ok || len(req.Question) == 0 {
+		return "", false
+	}
+	key := addr.String()
+	if c.key == keySuffix {
+		key += "|" + domainSuffix(strings.ToLower(matcher.NormalizeDomain(req.Question[0].Name)), c.suffixLabels)
+	}
+	return key, true
+}
+
+func (c *counter) add(key string) float64 {
+	now := c.now().UnixNano()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w, ok := c.windows.Get(key)
+	if !ok {
+		w = &window{start: now - now%c.window}
+		c.windows.Add(key, w)
+	}
+	return w.add(now, c.window)
+}
+
+func (c *counter) rate(key string) float64 {
+	now := c.now().UnixNano()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w, ok := c.windows.Get(key)
+	if !ok {
+		return 0
+	}
+	return w.peek(now, c.window)
+}
+
+// rateMatcher matches once the rate its counter saw within the window exceeds
+// threshold.
+type rateMatcher struct {
+	name      string
+	threshold float64
+	counter   *counter
+}
+
+func (r *rateMatcher) Name() string {
+	return r.name
+}
+
+func (r *rateMatcher) Type() string {
+	return "rate"
+}
+
+func (r *rateMatcher) Match(ctx context.Context, req *dns.Msg) (bool, error) {
+	key, ok := r.counter.keyOf(ctx, req)
+	if !ok {
+		return false, nil
+	}
+	count := r.counter.rate(key)
+	if count <= r.threshold {
+		return false, nil
+	}
+	matcher.ReportEvidence(ctx, r.name, r.Type(), key+" rate="+strconv.FormatFloat(count, 'f', 1, 64))
+	return true, nil
+}
+
+// domainSuffix keeps the last n labels of name.
+func domainSuffix(name string, n int) string {
+	end := len(name)
+	for i := len(name) - 1; i >= 0; i-- {
+		if name[i] != '.' {
+			continue
+		}
+		n--
+		if n == 0 {
+			return name[i+1 : end]
+		}
+	}
+	return name
+}
+
+func newRateMatcher(name string, c *config) (*rateMatcher, error) {
+	if c.Threshold <= 0 {
+		return nil, fmt.Errorf("rate threshold should be greater than 0")
+	}
+	if c.Window < 0 || c.SuffixLabels < 0 || c.Size < 0 {
+		return nil, fmt.Errorf("rate window, suffix_labels and size should not be negative")
+	}
+	key := strings.ToLower(strings.TrimSpace(c.Key))
+	switch key {
+	case "":
+		key = keyClient
+	case keyClient, keySuffix:
+	default:
+		return nil, fmt.Errorf("invalid rate key:%s, should be one of client/suffix", c.Key)
+	}
+	windowSec := c.Window
+	if windowSec == 0 {
+		windowSec = defaultWindow
+	}
+	suffixLabels := c.SuffixLabels
+	if suffixLabels == 0 {
+		suffixLabels = defaultSuffixLabels
+	}
+	size := c.Size
+	if size == 0 {
+		size = defaultSize
+	}
+	counter, err := getCounter(counterKey{
+		window:       int64(time.Duration(windowSec) * time.Second),
+		key:          key,
+		suffixLabels: suffixLabels,
+		size:         size,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rateMatcher{name: name, threshold: c.Threshold, counter: counter}, nil
+}
+
+func createRateMatcher(name string, args interface{}) (matcher.IDNSMatcher, error) {
+	c := &config{}
+	if err := utils.ConvStructJson(args, c); err != nil {
+		return nil, err
+	}
+	return newRateMatcher(name, c)
+}
+
+func init() {
+	matcher.Register("rate", createRateMatcher)
+	matcher.RegisterInline("rate", func(args []string) (interface{}, error) {
+		if len(args) == 0 || len(args) > 3 {
+			return nil, fmt.Errorf("rate inline call accepts threshold, window and key")
+		}
+		threshold, err := strconv.ParseFloat(args[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid threshold:%s", args[0])
+		}
+		data := map[string]interface{}{"threshold": threshold}
+		if len(args) > 1 {
+			window, err := strconv.ParseInt(args[1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid window:%s", args[1])
+			}
+			data["window"] = window
+		}
+		if len(args) > 2 {
+			data["key"] = args[2]
+		}
+		return data, nil
+	})
+}
@@ -0,0 +1,184 @@
+package matcher
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/xxxsen/atlas/internal/clientip"
+	"github.com/xxxsen/atlas/internal/matcher"
+	_ "github.com/xxxsen/atlas/internal/matcher/qtype"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	return f.now
+}
+
+// resetCounters drops the counters of earlier tests, so that every test
+// starts with empty windows.
+func resetCounters() {
+	countersMu.Lock()
+	defer countersMu.Unlock()
+	counters = make(map[counterKey]*counter)
+}
+
+func newTestMatcher(t *testing.T, c *config) (*rateMatcher, *fakeClock) {
+	resetCounters()
+	m, err := newRateMatcher("abuse", c)
+	if err != nil {
+		t.Fatalf("newRateMatcher error: %v", err)
+	}
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	m.counter.now = clock.Now
+	return m, clock
+}
+
+// query sends a query through the observers the way the server does, then
+// evaluates m on it.
+func query(t *testing.T, m matcher.IDNSMatcher, client string, name string) bool {
+	return queryType(t, m, client, name, dns.TypeA)
+}
+
+func queryType(t *testing.T, m matcher.IDNSMatcher, client string, name string, qtype uint16) bool {
+	ctx := clientip.With(context.Background(), netip.MustParseAddr(client))
+	req := new(dns.Msg)
+	req.SetQuestion(dns.Fqdn(name), qtype)
+	matcher.Observe(ctx, req)
+	ok, err := m.Match(ctx, req)
+	if err != nil {
+		t.Fatalf("match error: %v", err)
+	}
+	return ok
+}
+
+func TestRateMatcherClient(t *testing.T) {
+	m, clock := newTestMatcher(t, &config{Window: 10, Threshold: 5})
+	for i := 0; i < 5; i++ {
+		if query(t, m, "10.0.0.1", "example.com") {
+			t.Fatalf("query %d should be within the limit", i+1)
+		}
+	}
+	if !query(t, m, "10.0.0.1", "other.org") {
+		t.Fatalf("6th query should exceed the limit")
+	}
+	if query(t, m, "10.0.0.2", "example.com") {
+		t.Fatalf("other clients should not be affected")
+	}
+	clock.now = clock.now.Add(25 * time.Second)
+	if query(t, m, "10.0.0.1", "example.com") {
+		t.Fatalf("rate should reset once the window passed")
+	}
+}
+
+func TestRateMatcherSlidingWindow(t *testing.T) {
+	m, clock := newTestMatcher(t, &config{Window: 10, Threshold: 10})
+	for i := 0; i < 10; i++ {
+		query(t, m, "10.0.0.1", "example.com")
+	}
+	// half of the previous window still overlaps, so about 5 queries are counted
+	clock.now = clock.now.Add(15 * time.Second)
+	for i := 0; i < 5; i++ {
+		if query(t, m, "10.0.0.1", "example.com") {
+			t.Fatalf("query %d should be within the limit", i+1)
+		}
+	}
+	if !query(t, m, "10.0.0.1", "example.com") {
+		t.Fatalf("previous window should still be counted")
+	}
+}
+
+func TestRateMatcherSuffix(t *testing.T) {
+	m, _ := newTestMatcher(t, &config{Threshold: 2, Key: "suffix"})
+	query(t, m, "10.0.0.1", "a.loop.example")
+	query(t, m, "10.0.0.1", "b.loop.example")
+	if query(t, m, "10.0.0.1", "www.example.com") {
+		t.Fatalf("other suffixes should be counted separately")
+	}
+	if !query(t, m, "10.0.0.1", "c.loop.example") {
+		t.Fatalf("3rd query to the same suffix should exceed the limit")
+	}
+}
+
+func TestRateMatcherBehindFailingAnd(t *testing.T) {
+	resetCounters()
+	m, err := matcher.BuildExpressionMatcher("qtype(AAAA) && rate(3)", nil)
+	if err != nil {
+		t.Fatalf("build expression error: %v", err)
+	}
+	// the rate term is never evaluated for these queries, they are counted anyway
+	for i := 0; i < 3; i++ {
+		if query(t, m, "10.0.0.1", "example.com") {
+			t.Fatalf("A query %d should not match", i+1)
+		}
+	}
+	if !queryType(t, m, "10.0.0.1", "example.com", dns.TypeAAAA) {
+		t.Fatalf("4th query should exceed the limit")
+	}
+}
+
+func TestRateMatcherSharedCounter(t *testing.T) {
+	resetCounters()
+	low, err := matcher.BuildExpressionMatcher("rate(2)", nil)
+	if err != nil {
+		t.Fatalf("build expression error: %v", err)
+	}
+	high, err := matcher.BuildExpressionMatcher("rate(3)", nil)
+	if err != nil {
+		t.Fatalf("build expression error: %v", err)
+	}
+	// both inline copies count every query once, so each sees the same rate
+	ctx := clientip.With(context.Background(), netip.MustParseAddr("10.0.0.1"))
+	req := new(dns.Msg)
+	req.SetQuestion("example.com.", dns.TypeA)
+	for i := 1; i <= 4; i++ {
+		matcher.Observe(ctx, req)
+		lowOK, _ := low.Match(ctx, req)
+		highOK, _ := high.Match(ctx, req)
+		if lowOK != (i > 2) || highOK != (i > 3) {
+			t.Fatalf("query %d: unexpected result rate(2)=%v rate(3)=%v", i, lowOK, highOK)
+		}
+	}
+}
+
+func TestRateMatcherNoClient(t *testing.T) {
+	m, _ := newTestMatcher(t, &config{Threshold: 1})
+	req := new(dns.Msg)
+	req.SetQuestion("example.com.", dns.TypeA)
+	for i := 0; i < 3; i++ {
+		if ok, _ := m.Match(context.Background(), req); ok {
+			t.Fatalf("expected no match without client address")
+		}
+	}
+}
+
+func TestRateMatcherInvalid(t *testing.T) {
+	for _, c := range []*config{{}, {Threshold: 1, Key: "qname"}, {Threshold: 1, Window: -1}} {
+		if _, err := newRateMatcher("bad", c); err == nil {
+			t.Fatalf("%+v: expected error", c)
+		}
+	}
+}
+
+func TestDomainSuffix(t *testing.T) {
+	tests := []struct {
+		name string
+		n    int
+		want string
+	}{
+		{"a.b.example.com", 2, "example.com"},
+		{"example.com", 2, "example.com"},
+		{"com", 2, "com"},
+		{"a.b.example.com", 3, "b.example.com"},
+	}
+	for _, tt := range tests {
+		if got := domainSuffix(tt.name, tt.n); got != tt.want {
+			t.Fatalf("domainSuffix(%s, %d) = %s, want %s", tt.name, tt.n, got, tt.want)
+		}
+	}
+}
@@ -1,8 +1,13 @@
 package register
 
 import (
+	_ "github.com/xxxsen/atlas/internal/matcher/client"
 	_ "github.com/xxxsen/atlas/internal/matcher/domain"
+	_ "github.com/xxxsen/atlas/internal/matcher/etld1"
 	_ "github.com/xxxsen/atlas/internal/matcher/geosite"
+	_ "github.com/xxxsen/atlas/internal/matcher/heuristic"
 	_ "github.com/xxxsen/atlas/internal/matcher/qclass"
 	_ "github.com/xxxsen/atlas/internal/matcher/qtype"
+	_ "github.com/xxxsen/atlas/internal/matcher/rate"
+	_ "github.com/xxxsen/atlas/internal/matcher/sample"
 )
@@ -0,0 +1,70 @@
+package matcher
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/miekg/dns"
+	"github.com/xxxsen/atlas/internal/watcher"
+	"github.com/xxxsen/common/logutil"
+	"go.uber.org/zap"
+)
+
+// BuildFunc creates a fresh matcher instance from its backing files.
+type BuildFunc func() (IDNSMatcher, error)
+
+type reloadableMatcher struct {
+	name  string
+	typ   string
+	build BuildFunc
+	mu    sync.Mutex
+	cur   atomic.Value
+}
+
+type matcherHolder struct {
+	m IDNSMatcher
+}
+
+// NewReloadableMatcher builds a matcher and rebuilds it whenever one of the files changes.
+// A failed rebuild keeps the previous matcher in place.
+func NewReloadableMatcher(name string, typ string, files []string, build BuildFunc) (IDNSMatcher, error) {
+	inst, err := build()
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return inst, nil
+	}
+	r := &reloadableMatcher{name: name, typ: typ, build: build}
+	r.cur.Store(matcherHolder{m: inst})
+	if err := watcher.Watch(files, r.reload); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *reloadableMatcher) Name() string {
+	return r.name
+}
+
+func (r *reloadableMatcher) Type() string {
+	return r.typ
+}
+
+func (r *reloadableMatcher) Match(ctx context.Context, req *dns.Msg) (bool, error) {
+	return r.cur.Load().(matcherHolder).m.Match(ctx, req)
+}
+
+func (r *reloadableMatcher) reload() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	logger := logutil.GetLogger(context.Background()).With(zap.String("matcher", r.name), zap.String("type", r.typ))
+	inst, err := r.build()
+	if err != nil {
+		logger.Error("reload matcher failed, keep previous version", zap.Error(err))
+		return
+	}
+	r.cur.Store(matcherHolder{m: inst})
+	logger.Info("reload matcher succ")
+}
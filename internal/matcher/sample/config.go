@@ -0,0 +1,7 @@
+package matcher
+
+type config struct {
+	Percent float64 `json:"percent"`
+	Key     string  `json:"key"`
+	Seed    string  `json:"seed"`
+}
@@ -0,0 +1,132 @@
+package matcher
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/xxxsen/atlas/internal/clientip"
+	"github.com/xxxsen/atlas/internal/matcher"
+	"github.com/xxxsen/common/utils"
+)
+
+const (
+	keyQName  = "qname"
+	keyClient = "client"
+	keyBoth   = "both"
+
+	// buckets gives the sample percent a resolution of 0.01%.
+	buckets = 10000
+)
+
+// sampleMatcher selects a stable fraction of traffic. The selection key is
+// hashed into one of 10000 buckets and buckets below the percent match, so
+// raising percent with the same seed keeps every key selected before.
+type sampleMatcher struct {
+	name  string
+	key   string
+	seed  string
+	limit uint64
+}
+
+func (s *sampleMatcher) Name() string {
+	return s.name
+}
+
+func (s *sampleMatcher) Type() string {
+	return "sample"
+}
+
+func (s *sampleMatcher) Match(ctx context.Context, req *dns.Msg) (bool, error) {
+	if s.limit == 0 {
+		return false, nil
+	}
+	key, ok := s.selectKey(ctx, req)
+	if !ok {
+		return false, nil
+	}
+	bucket := s.bucket(key)
+	if bucket >= s.limit {
+		return false, nil
+	}
+	matcher.ReportEvidence(ctx, s.name, s.Type(), fmt.Sprintf("%s bucket=%d/%d", key, bucket, buckets))
+	return true, nil
+}
+
+func (s *sampleMatcher) selectKey(ctx context.Context, req *dns.Msg) (string, bool) {
+	var qname string
+	if s.key != keyClient {
+		qname = strings.ToLower(matcher.NormalizeDomain(req.Question[0].Name))
+		if s.key == keyQName {
+			return qname, true
+		}
+	}
+	addr, ok := clientip.From(ctx)
+	if !ok {
+		return "", false
+	}
+	if s.key == keyClient {
+		return addr.String(), true
+	}
+	return addr.String() + "|" + qname, true
+}
+
+func (s *sampleMatcher) bucket(key string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s.seed))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(key))
+	return h.Sum64() % buckets
+}
+
+func newSampleMatcher(name string, c *config) (matcher.IDNSMatcher, error) {
+	if c.Percent < 0 || c.Percent > 100 {
+		return nil, fmt.Errorf("invalid sample percent:%v, should be within [0, 100]", c.Percent)
+	}
+	key := strings.ToLower(strings.TrimSpace(c.Key))
+	switch key {
+	case "":
+		key = keyQName
+	case keyQName, keyClient, keyBoth:
+	default:
+		return nil, fmt.Errorf("invalid sample key:%s, should be one of qname/client/both", c.Key)
+	}
+	return &sampleMatcher{
+		name:  name,
+		key:   key,
+		seed:  c.Seed,
+		limit: uint64(c.Percent*buckets/100 + 0.5),
+	}, nil
+}
+
+func createSampleMatcher(name string, args interface{}) (matcher.IDNSMatcher, error) {
+	c := &config{}
+	if err := utils.ConvStructJson(args, c); err != nil {
+		return nil, err
+	}
+	return newSampleMatcher(name, c)
+}
+
+func init() {
+	matcher.Register("sample", createSampleMatcher)
+	matcher.RegisterInline("sample", func(args []string) (interface{}, error) {
+		if len(args) == 0 || len(args) > 3 {
+			return nil, fmt.Errorf("sample inline call accepts percent, key and seed")
+		}
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(args[0], "%"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid percent:%s", args[0])
+		}
+		data := map[string]interface{}{"percent": percent}
+		if len(args) > 1 {
+			data["key"] = args[1]
+		}
+		if len(args) > 2 {
+			data["seed"] = args[2]
+		}
+		return data, nil
+	})
+}
@@ -0,0 +1,103 @@
+package matcher
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/xxxsen/atlas/internal/clientip"
+	"github.com/xxxsen/atlas/internal/matcher"
+)
+
+func mustSample(t *testing.T, args map[string]interface{}) matcher.IDNSMatcher {
+	m, err := createSampleMatcher("canary", args)
+	if err != nil {
+		t.Fatalf("create sample matcher error: %v", err)
+	}
+	return m
+}
+
+func matchName(t *testing.T, ctx context.Context, m matcher.IDNSMatcher, name string) bool {
+	req := new(dns.Msg)
+	req.SetQuestion(dns.Fqdn(name), dns.TypeA)
+	ok, err := m.Match(ctx, req)
+	if err != nil {
+		t.Fatalf("match error: %v", err)
+	}
+	return ok
+}
+
+func TestSampleMatcherFraction(t *testing.T) {
+	m := mustSample(t, map[string]interface{}{"percent": 10})
+	hits := 0
+	for i := 0; i < 10000; i++ {
+		if matchName(t, context.Background(), m, fmt.Sprintf("host%d.example.com", i)) {
+			hits++
+		}
+	}
+	if hits < 850 || hits > 1150 {
+		t.Fatalf("expected about 1000 hits, got %d", hits)
+	}
+}
+
+func TestSampleMatcherStableRampUp(t *testing.T) {
+	small := mustSample(t, map[string]interface{}{"percent": 5, "seed": "v2"})
+	large := mustSample(t, map[string]interface{}{"percent": 20, "seed": "v2"})
+	for i := 0; i < 2000; i++ {
+		name := fmt.Sprintf("host%d.example.com", i)
+		first := matchName(t, context.Background(), small, name)
+		if first != matchName(t, context.Background(), small, name) {
+			t.Fatalf("%s: selection is not deterministic", name)
+		}
+		if first && !matchName(t, context.Background(), large, name) {
+			t.Fatalf("%s: selected at 5%% but dropped at 20%%", name)
+		}
+	}
+}
+
+func TestSampleMatcherClientKey(t *testing.T) {
+	all := mustSample(t, map[string]interface{}{"percent": 100, "key": "client"})
+	if matchName(t, context.Background(), all, "example.com") {
+		t.Fatalf("expected no match without client address")
+	}
+	ctx := clientip.With(context.Background(), netip.MustParseAddr("10.0.0.1"))
+	if !matchName(t, ctx, all, "example.com") {
+		t.Fatalf("expected match at 100%%")
+	}
+	half := mustSample(t, map[string]interface{}{"percent": 50, "key": "client"})
+	first := matchName(t, ctx, half, "a.example.com")
+	for _, name := range []string{"b.example.com", "c.example.org", "d.example.net"} {
+		if matchName(t, ctx, half, name) != first {
+			t.Fatalf("%s: client keyed selection should not depend on qname", name)
+		}
+	}
+	none := mustSample(t, map[string]interface{}{"percent": 0, "key": "both"})
+	if matchName(t, ctx, none, "example.com") {
+		t.Fatalf("expected no match at 0%%")
+	}
+}
+
+func TestSampleMatcherInvalid(t *testing.T) {
+	for _, args := range []map[string]interface{}{
+		{"percent": 101},
+		{"percent": -1},
+		{"percent": 5, "key": "domain"},
+	} {
+		if _, err := createSampleMatcher("bad", args); err == nil {
+			t.Fatalf("%v: expected error", args)
+		}
+	}
+}
+
+func TestSampleMatcherInline(t *testing.T) {
+	m, err := matcher.BuildExpressionMatcher("sample(100%, client)", nil)
+	if err != nil {
+		t.Fatalf("build expression error: %v", err)
+	}
+	ctx := clientip.With(context.Background(), netip.MustParseAddr("10.0.0.1"))
+	if !matchName(t, ctx, m, "example.com") {
+		t.Fatalf("expected match at 100%%")
+	}
+}
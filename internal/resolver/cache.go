@@ -38,7 +38,7 @@ func init() {
 
 // ConfigureCache sets the global cache options that will be used when wrapping resolvers.
 func ConfigureCache(opt CacheOptions) {
-	if opt.Size < 0 {
+	if opt.Size <= 0 {
 		opt.Size = 1000
 	}
 	if opt.Interval == 0 {
@@ -64,7 +64,7 @@ func (c cacheResolver) Name() string {
 
 func (c cacheResolver) Query(ctx context.Context, req *dns.Msg) (*dns.Msg, error) {
 	v, ok := globalCacheManager.Load().(*cacheManager)
-	if !ok {
+	if !ok || cacheBypassed(ctx) {
 		return c.next.Query(ctx, req)
 	}
 	return v.Query(ctx, c.next, req)
@@ -74,6 +74,44 @@ type cacheResolver struct {
 	next IDNSResolver
 }
 
+type cacheBypassKey struct{}
+
+// WithCacheBypass makes queries under ctx skip the cache, they neither read
+// cached responses nor store their own.
+func WithCacheBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheBypassKey{}, true)
+}
+
+func cacheBypassed(ctx context.Context) bool {
+	v, _ := ctx.Value(cacheBypassKey{}).(bool)
+	return v
+}
+
+// CacheStatus tells whether a query was answered from the cache.
+type CacheStatus struct {
+	hit atomic.Bool
+}
+
+type cacheStatusKey struct{}
+
+// WithCacheStatus attaches a CacheStatus to ctx, it is marked when a query
+// under ctx is served from the cache, stale lazy entries included.
+func WithCacheStatus(ctx context.Context) (context.Context, *CacheStatus) {
+	st := &CacheStatus{}
+	return context.WithValue(ctx, cacheStatusKey{}, st), st
+}
+
+// Hit reports whether a cached response was served.
+func (s *CacheStatus) Hit() bool {
+	return s.hit.Load()
+}
+
+func markCacheHit(ctx context.Context) {
+	if st, ok := ctx.Value(cacheStatusKey{}).(*CacheStatus); ok {
+		st.hit.Store(true)
+	}
+}
+
 type cacheManager struct {
 	ch       chan bool
 	wg       sync.WaitGroup
@@ -120,18 +158,25 @@ func (c *cacheManager) Close() {
 }
 
 func (c *cacheManager) Query(ctx context.Context, qr IDNSResolver, req *dns.Msg) (*dns.Msg, error) {
-	key := c.buildCacheKey(req)
+	key := c.buildCacheKey(qr.Name(), req)
+	if rw := responseRewriteName(ctx); rw != "" && key != "" {
+		key += "|" + rw
+	}
 	msg, expired, found := c.get(ctx, key)
 	if found {
 		msg.Id = req.Id
 		msg.Question = append([]dns.Question(nil), req.Question...)
 		if !expired {
 			logutil.GetLogger(ctx).Debug("read dns response from cache")
+			applyResponseRewrite(ctx, nil)
+			markCacheHit(ctx)
 			return msg, nil
 		}
 		if c.cfg.Lazy {
 			logutil.GetLogger(ctx).Debug("use expire dns response from cache, start refresh it")
 			c.scheduleRefresh(ctx, qr, key, req.Copy())
+			applyResponseRewrite(ctx, nil)
+			markCacheHit(ctx)
 			return msg, nil
 		}
 		c.remove(key)
@@ -141,6 +186,7 @@ func (c *cacheManager) Query(ctx context.Context, qr IDNSResolver, req *dns.Msg)
 	if err != nil {
 		return nil, err
 	}
+	applyResponseRewrite(ctx, resp)
 	c.store(key, resp)
 	return resp, nil
 }
@@ -208,7 +254,7 @@ func (c *cacheManager) scheduleRefresh(oldctx context.Context, qr IDNSResolver,
 	c.inflight[key] = struct{}{}
 	c.mu.Unlock()
 	tid, _ := trace.GetTraceId(oldctx)
-	ctx := trace.WithTraceId(context.Background(), tid)
+	ctx := withResponseRewriteFrom(trace.WithTraceId(context.Background(), tid), oldctx)
 	go func() {
 		defer func() {
 			c.mu.Lock()
@@ -223,6 +269,7 @@ func (c *cacheManager) scheduleRefresh(oldctx context.Context, qr IDNSResolver,
 			return
 		}
 		logutil.GetLogger(ctx).Debug("lazy cache update succ", zap.String("key", key), zap.String("resolver", qr.Name()))
+		applyResponseRewrite(ctx, resp)
 		c.store(key, resp)
 	}()
 }
@@ -360,7 +407,9 @@ func (c *cacheManager) loadFromFile() error {
 	return nil
 }
 
-func (c *cacheManager) buildCacheKey(req *dns.Msg) string {
+// buildCacheKey keys responses by the upstream as well, resolvers wrapped by
+// different actions may give different answers for the same question.
+func (c *cacheManager) buildCacheKey(upstream string, req *dns.Msg) string {
 	if req == nil || len(req.Question) == 0 {
 		return ""
 	}
@@ -369,7 +418,7 @@ func (c *cacheManager) buildCacheKey(req *dns.Msg) string {
 	if domain == "" {
 		return ""
 	}
-	return fmt.Sprintf("%s|%d|%d", domain, q.Qtype, q.Qclass)
+	return fmt.Sprintf("%s|%s|%d|%d", upstream, domain, q.Qtype, q.Qclass)
 }
 
 func (c *cacheManager) extractTTL(msg *dns.Msg) (uint32, bool) {
@@ -112,3 +112,101 @@ func TestCacheResolverLazyRefresh(t *testing.T) {
 		t.Fatalf("expected refresh to trigger, base count=%d", base.count)
 	}
 }
+
+func TestCacheResolverResponseRewrite(t *testing.T) {
+	ConfigureCache(CacheOptions{Size: 10})
+	defer ConfigureCache(CacheOptions{})
+
+	base := &mockResolver{msg: newResponse(1)}
+	wrapped := TryEnableResolverCache(base)
+	setTTL := func(resp *dns.Msg) {
+		for _, rr := range resp.Answer {
+			rr.Header().Ttl = 300
+		}
+	}
+
+	ctx, rw := WithResponseRewrite(context.Background(), "ttl", setTTL)
+	resp, err := wrapped.Query(ctx, newRequest())
+	if err != nil {
+		t.Fatalf("first query error: %v", err)
+	}
+	if !rw.Handled() || resp.Answer[0].Header().Ttl != 300 {
+		t.Fatalf("expected rewritten response, handled=%v ttl=%d", rw.Handled(), resp.Answer[0].Header().Ttl)
+	}
+
+	// the cached entry lives as long as the rewritten ttl
+	time.Sleep(1100 * time.Millisecond)
+	ctx, rw = WithResponseRewrite(context.Background(), "ttl", setTTL)
+	resp, err = wrapped.Query(ctx, newRequest())
+	if err != nil {
+		t.Fatalf("second query error: %v", err)
+	}
+	if base.count != 1 || !rw.Handled() {
+		t.Fatalf("expected cached response, base count=%d handled=%v", base.count, rw.Handled())
+	}
+	if ttl := resp.Answer[0].Header().Ttl; ttl < 297 || ttl > 299 {
+		t.Fatalf("unexpected cached ttl %d", ttl)
+	}
+}
+
+func TestCacheResolverResponseRewriteKey(t *testing.T) {
+	ConfigureCache(CacheOptions{Size: 10})
+	defer ConfigureCache(CacheOptions{})
+
+	base := &mockResolver{msg: newResponse(30)}
+	wrapped := TryEnableResolverCache(base)
+	setTTL := func(resp *dns.Msg) {
+		for _, rr := range resp.Answer {
+			rr.Header().Ttl = 300
+		}
+	}
+
+	if _, err := wrapped.Query(context.Background(), newRequest()); err != nil {
+		t.Fatalf("plain query error: %v", err)
+	}
+	// a plain cached entry is not served as if it had been rewritten
+	ctx, rw := WithResponseRewrite(context.Background(), "ttl", setTTL)
+	resp, err := wrapped.Query(ctx, newRequest())
+	if err != nil {
+		t.Fatalf("rewrite query error: %v", err)
+	}
+	if base.count != 2 || !rw.Handled() || resp.Answer[0].Header().Ttl != 300 {
+		t.Fatalf("expected rewritten upstream response, base count=%d handled=%v ttl=%d", base.count, rw.Handled(), resp.Answer[0].Header().Ttl)
+	}
+	// and a rewritten entry does not leak to plain queries
+	resp, err = wrapped.Query(context.Background(), newRequest())
+	if err != nil {
+		t.Fatalf("plain query error: %v", err)
+	}
+	if base.count != 2 || resp.Answer[0].Header().Ttl > 30 {
+		t.Fatalf("expected plain cached response, base count=%d ttl=%d", base.count, resp.Answer[0].Header().Ttl)
+	}
+	ctx, rw = WithResponseRewrite(context.Background(), "ttl", setTTL)
+	resp, err = wrapped.Query(ctx, newRequest())
+	if err != nil {
+		t.Fatalf("rewrite query error: %v", err)
+	}
+	if base.count != 2 || !rw.Handled() || resp.Answer[0].Header().Ttl < 299 {
+		t.Fatalf("expected rewritten cached response, base count=%d handled=%v ttl=%d", base.count, rw.Handled(), resp.Answer[0].Header().Ttl)
+	}
+}
+
+func TestCacheResolverBypass(t *testing.T) {
+	ConfigureCache(CacheOptions{Size: 10})
+	defer ConfigureCache(CacheOptions{})
+
+	base := &mockResolver{msg: newResponse(30)}
+	wrapped := TryEnableResolverCache(base)
+	ctx := WithCacheBypass(context.Background())
+	for i := 0; i < 2; i++ {
+		if _, err := wrapped.Query(ctx, newRequest()); err != nil {
+			t.Fatalf("bypass query error: %v", err)
+		}
+	}
+	if _, err := wrapped.Query(context.Background(), newRequest()); err != nil {
+		t.Fatalf("query error: %v", err)
+	}
+	if base.count != 3 {
+		t.Fatalf("bypassed queries should neither read nor fill the cache, base count=%d", base.count)
+	}
+}
@@ -0,0 +1,74 @@
+package resolver
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+
+	"github.com/miekg/dns"
+)
+
+// ResponseRewriter changes a response in place.
+type ResponseRewriter func(resp *dns.Msg)
+
+// ResponseRewrite is a rewriter attached to a request context, the cache
+// applies it before a response is stored so cached entries carry the
+// rewritten values. Rewritten responses are cached apart from the plain ones,
+// keyed by the rewriter names.
+type ResponseRewrite struct {
+	name    string
+	fn      ResponseRewriter
+	parent  *ResponseRewrite
+	handled atomic.Bool
+}
+
+type responseRewriteKey struct{}
+
+// WithResponseRewrite attaches fn to ctx, rewriters attached by outer callers
+// run after fn. name identifies the rewrite in cache keys, so it must be
+// unique among rewriters, such as the name of the action attaching it.
+func WithResponseRewrite(ctx context.Context, name string, fn ResponseRewriter) (context.Context, *ResponseRewrite) {
+	parent, _ := ctx.Value(responseRewriteKey{}).(*ResponseRewrite)
+	rw := &ResponseRewrite{name: name, fn: fn, parent: parent}
+	return context.WithValue(ctx, responseRewriteKey{}, rw), rw
+}
+
+// Handled reports whether the response was already rewritten by the cache,
+// either when it was stored or because it was served from the cache.
+func (r *ResponseRewrite) Handled() bool {
+	return r.handled.Load()
+}
+
+func responseRewriteFrom(ctx context.Context) *ResponseRewrite {
+	rw, _ := ctx.Value(responseRewriteKey{}).(*ResponseRewrite)
+	return rw
+}
+
+func withResponseRewriteFrom(ctx context.Context, from context.Context) context.Context {
+	if rw := responseRewriteFrom(from); rw != nil {
+		return context.WithValue(ctx, responseRewriteKey{}, rw)
+	}
+	return ctx
+}
+
+// responseRewriteName returns the names of the rewriters of ctx in the order
+// they run, empty when there are none.
+func responseRewriteName(ctx context.Context) string {
+	var names []string
+	for rw := responseRewriteFrom(ctx); rw != nil; rw = rw.parent {
+		names = append(names, rw.name)
+	}
+	return strings.Join(names, ",")
+}
+
+// applyResponseRewrite runs the rewriters of ctx on resp, resp may be nil when
+// the response came from an entry cached under the same rewriters and only
+// has to be marked as handled.
+func applyResponseRewrite(ctx context.Context, resp *dns.Msg) {
+	for rw := responseRewriteFrom(ctx); rw != nil; rw = rw.parent {
+		if resp != nil {
+			rw.fn(resp)
+		}
+		rw.handled.Store(true)
+	}
+}
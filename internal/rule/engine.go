@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/miekg/dns"
+	"github.com/xxxsen/atlas/internal/matcher"
 	"github.com/xxxsen/common/logutil"
 	"go.uber.org/zap"
 )
@@ -26,7 +27,7 @@ func (d *defaultEngine) Execute(ctx context.Context, req *dns.Msg) (*dns.Msg, er
 		if !ok {
 			continue
 		}
-		logutil.GetLogger(ctx).Debug("match rule", zap.String("rule_remark", r.Name()))
+		logutil.GetLogger(ctx).Debug("match rule", zap.String("rule_remark", r.Name()), zap.Strings("evidence", matcher.EvidenceStrings(ctx)))
 		res, err := r.Perform(ctx, req)
 		if err != nil {
 			logutil.GetLogger(ctx).Error("perform rule failed", zap.Error(err))
@@ -9,6 +9,9 @@ import (
 	"time"
 
 	"github.com/miekg/dns"
+	"github.com/xxxsen/atlas/internal/clientip"
+	"github.com/xxxsen/atlas/internal/idn"
+	"github.com/xxxsen/atlas/internal/matcher"
 	"github.com/xxxsen/common/logutil"
 	"github.com/xxxsen/common/trace"
 	"go.uber.org/zap"
@@ -86,13 +89,25 @@ func (s *dnsServer) shutdown() {
 func (s *dnsServer) handleDNS(ctx context.Context, w dns.ResponseWriter, req *dns.Msg) {
 	tid := atomic.AddUint64(&s.tid, 1)
 	ctx = trace.WithTraceId(ctx, strconv.FormatUint(tid, 10))
+	ctx = matcher.WithEvidence(ctx)
+	client, hasClient := clientip.FromNetAddr(w.RemoteAddr())
+	if hasClient {
+		ctx = clientip.With(ctx, client)
+	}
 	logger := logutil.GetLogger(ctx)
 	if req.Opcode != dns.OpcodeQuery || len(req.Question) == 0 {
 		logger.Error("recv invalid dns request, skip next")
 		return
 	}
 	logger = logger.With(zap.String("domain", req.Question[0].Name), zap.Uint16("qtype", req.Question[0].Qtype))
+	if idn.HasPunycode(req.Question[0].Name) {
+		logger = logger.With(zap.String("domain_unicode", idn.ToUnicode(req.Question[0].Name)))
+	}
+	if hasClient {
+		logger = logger.With(zap.String("client", client.String()))
+	}
 	logger.Debug("recv request, handle it")
+	matcher.Observe(ctx, req)
 	start := time.Now()
 	resp, err := s.processRequest(ctx, req)
 	cost := time.Since(start)
@@ -112,7 +127,8 @@ func (s *dnsServer) handleDNS(ctx context.Context, w dns.ResponseWriter, req *dn
 		logger.Error("write response to client failed", zap.Error(err))
 		return
 	}
-	logger.Info("handle dns request finish", zap.Bool("succ", succ), zap.String("ips", s.summariseIPs(resp)))
+	logger.Info("handle dns request finish", zap.Bool("succ", succ), zap.String("ips", s.summariseIPs(resp)),
+		zap.Strings("evidence", matcher.EvidenceStrings(ctx)))
 }
 
 func (s *dnsServer) summariseIPs(msg *dns.Msg) string {
@@ -0,0 +1,244 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/xxxsen/common/logutil"
+	"go.uber.org/zap"
+)
+
+// Options controls the behaviour of the global file watcher.
+type Options struct {
+	Enable   bool
+	Debounce time.Duration
+}
+
+const defaultDebounce = 500 * time.Millisecond
+
+// IFileWatcher notifies callbacks when watched files change.
+type IFileWatcher interface {
+	Watch(paths []string, fn func()) error
+	Close() error
+}
+
+var globalWatcher atomic.Value
+
+func init() {
+	globalWatcher.Store(holder{w: nopWatcher{}})
+}
+
+type holder struct {
+	w IFileWatcher
+}
+
+// Configure replaces the global watcher, closing the previous one.
+func Configure(opt Options) error {
+	var w IFileWatcher = nopWatcher{}
+	if opt.Enable {
+		if opt.Debounce <= 0 {
+			opt.Debounce = defaultDebounce
+		}
+		fw, err := New(opt.Debounce)
+		if err != nil {
+			return err
+		}
+		w = fw
+	}
+	old := globalWatcher.Swap(holder{w: w}).(holder)
+	return old.w.Close()
+}
+
+// Watch registers fn on the global watcher, it is a no-op when watching is disabled.
+func Watch(paths []string, fn func()) error {
+	return globalWatcher.Load().(holder).w.Watch(paths, fn)
+}
+
+// Close stops the global watcher.
+func Close() error {
+	old := globalWatcher.Swap(holder{w: nopWatcher{}}).(holder)
+	return old.w.Close()
+}
+
+type nopWatcher struct{}
+
+func (nopWatcher) Watch([]string, func()) error { return nil }
+func (nopWatcher) Close() error                 { return nil }
+
+type subscriber struct {
+	fn      func()
+	running *sync.WaitGroup
+	mu      sync.Mutex
+	timer   *time.Timer
+	stopped bool
+}
+
+type fileWatcher struct {
+	debounce time.Duration
+	fw       *fsnotify.Watcher
+	mu       sync.Mutex
+	dirs     map[string]struct{}
+	subs     map[string][]*subscriber
+	dirSubs  map[string][]*subscriber
+	wg       sync.WaitGroup
+	running  sync.WaitGroup
+}
+
+// New creates an inotify based watcher that debounces events per callback.
+func New(debounce time.Duration) (IFileWatcher, error) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("create fsnotify watcher: %w", err)
+	}
+	w := &fileWatcher{
+		debounce: debounce,
+		fw:       fw,
+		dirs:     make(map[string]struct{}),
+		subs:     make(map[string][]*subscriber),
+		dirSubs:  make(map[string][]*subscriber),
+	}
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.loop()
+	}()
+	return w, nil
+}
+
+// Watch watches the parent directory of each path, so files replaced by rename are still tracked.
+// A directory path is watched itself, any file created, changed or removed
+// directly inside it triggers fn.
+func (w *fileWatcher) Watch(paths []string, fn func()) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	sub := &subscriber{fn: fn, running: &w.running}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, p := range paths {
+		abs, err := filepath.Abs(filepath.Clean(p))
+		if err != nil {
+			return fmt.Errorf("resolve watch path %s: %w", p, err)
+		}
+		if st, err := os.Stat(abs); err == nil && st.IsDir() {
+			if err := w.addDir(abs); err != nil {
+				return err
+			}
+			w.dirSubs[abs] = append(w.dirSubs[abs], sub)
+			continue
+		}
+		if err := w.addDir(filepath.Dir(abs)); err != nil {
+			return err
+		}
+		w.subs[abs] = append(w.subs[abs], sub)
+	}
+	return nil
+}
+
+func (w *fileWatcher) addDir(dir string) error {
+	if _, ok := w.dirs[dir]; ok {
+		return nil
+	}
+	if err := w.fw.Add(dir); err != nil {
+		return fmt.Errorf("watch dir %s: %w", dir, err)
+	}
+	w.dirs[dir] = struct{}{}
+	return nil
+}
+
+// Close stops watching, cancels pending debounced callbacks and waits for the
+// running ones, no callback runs after Close returns.
+func (w *fileWatcher) Close() error {
+	err := w.fw.Close()
+	w.wg.Wait()
+	w.mu.Lock()
+	for _, subs := range w.subs {
+		for _, sub := range subs {
+			sub.stop()
+		}
+	}
+	for _, subs := range w.dirSubs {
+		for _, sub := range subs {
+			sub.stop()
+		}
+	}
+	w.mu.Unlock()
+	w.running.Wait()
+	return err
+}
+
+func (w *fileWatcher) loop() {
+	logger := logutil.GetLogger(context.Background())
+	for {
+		select {
+		case ev, ok := <-w.fw.Events:
+			if !ok {
+				return
+			}
+			if ev.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) == 0 {
+				continue
+			}
+			w.notify(ev.Name)
+		case err, ok := <-w.fw.Errors:
+			if !ok {
+				return
+			}
+			logger.Error("file watcher error", zap.Error(err))
+		}
+	}
+}
+
+func (w *fileWatcher) notify(name string) {
+	name = filepath.Clean(name)
+	w.mu.Lock()
+	subs := append([]*subscriber(nil), w.subs[name]...)
+	if !strings.HasPrefix(filepath.Base(name), ".") {
+		subs = append(subs, w.dirSubs[filepath.Dir(name)]...)
+	}
+	w.mu.Unlock()
+	for _, sub := range subs {
+		sub.schedule(w.debounce)
+	}
+}
+
+func (s *subscriber) schedule(delay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return
+	}
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.timer = time.AfterFunc(delay, s.run)
+}
+
+// run calls fn unless the subscriber was stopped, Close waits for it through
+// running.
+func (s *subscriber) run() {
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return
+	}
+	s.running.Add(1)
+	s.mu.Unlock()
+	defer s.running.Done()
+	s.fn()
+}
+
+func (s *subscriber) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopped = true
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+}
@@ -0,0 +1,166 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWatcherDebounceAndRename(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "list.txt")
+	if err := os.WriteFile(path, []byte("a"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	w, err := New(100 * time.Millisecond)
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	defer w.Close()
+
+	var calls int32
+	if err := w.Watch([]string{path}, func() { atomic.AddInt32(&calls, 1) }); err != nil {
+		t.Fatalf("Watch error: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := os.WriteFile(path, []byte("b"), 0o644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+	time.Sleep(400 * time.Millisecond)
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("expected 1 debounced call, got %d", got)
+	}
+
+	tmp := filepath.Join(dir, "list.txt.tmp")
+	if err := os.WriteFile(tmp, []byte("c"), 0o644); err != nil {
+		t.Fatalf("write temp file: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatalf("rename file: %v", err)
+	}
+	time.Sleep(400 * time.Millisecond)
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Fatalf("expected call after rename, got %d", got)
+	}
+}
+
+func TestWatcherIgnoresOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "list.txt")
+	if err := os.WriteFile(path, []byte("a"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	w, err := New(50 * time.Millisecond)
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	defer w.Close()
+
+	var calls int32
+	if err := w.Watch([]string{path}, func() { atomic.AddInt32(&calls, 1) }); err != nil {
+		t.Fatalf("Watch error: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "other.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if got := atomic.LoadInt32(&calls); got != 0 {
+		t.Fatalf("expected no call, got %d", got)
+	}
+}
+
+func TestWatcherCloseCancelsPending(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "list.txt")
+	if err := os.WriteFile(path, []byte("a"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	w, err := New(200 * time.Millisecond)
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+
+	var calls int32
+	if err := w.Watch([]string{path}, func() { atomic.AddInt32(&calls, 1) }); err != nil {
+		t.Fatalf("Watch error: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("b"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	// let the event arrive and start the debounce timer, then close before it fires
+	time.Sleep(50 * time.Millisecond)
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+	time.Sleep(400 * time.Millisecond)
+	if got := atomic.LoadInt32(&calls); got != 0 {
+		t.Fatalf("expected no call after close, got %d", got)
+	}
+}
+
+func TestWatcherCloseWaitsRunning(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "list.txt")
+	if err := os.WriteFile(path, []byte("a"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	w, err := New(10 * time.Millisecond)
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+
+	started := make(chan struct{})
+	var done int32
+	if err := w.Watch([]string{path}, func() {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		atomic.StoreInt32(&done, 1)
+	}); err != nil {
+		t.Fatalf("Watch error: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("b"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatalf("callback not started")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+	if atomic.LoadInt32(&done) != 1 {
+		t.Fatalf("expected Close to wait for the running callback")
+	}
+}
+
+func TestWatcherDirectory(t *testing.T) {
+	dir := t.TempDir()
+	w, err := New(50 * time.Millisecond)
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	defer w.Close()
+
+	var calls int32
+	if err := w.Watch([]string{dir}, func() { atomic.AddInt32(&calls, 1) }); err != nil {
+		t.Fatalf("Watch error: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".swp"), []byte("x"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if got := atomic.LoadInt32(&calls); got != 0 {
+		t.Fatalf("expected hidden files ignored, got %d", got)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "new-category"), []byte("x"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("expected call for a new file, got %d", got)
+	}
+}